@@ -0,0 +1,57 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/muhammadolammi/jobmatchapi/internal/eventbus"
+	"github.com/muhammadolammi/jobmatchapi/internal/handlers"
+	"github.com/streadway/amqp"
+)
+
+// ConnectEventBuses builds the analysis and session updates buses from the configured drivers. Call it
+// after ConnectDB, the postgres backend needs the database. It stops the server when a bus can't be
+// built, the outbox would otherwise mark jobs published on a bus nobody reads.
+func ConnectEventBuses(ctx context.Context, cfg *handlers.Config) {
+	drivers := []string{cfg.AnalysisEventBus, cfg.SessionUpdatesEventBus}
+	if slices.Contains(drivers, "pubsub") {
+		if err := connectPubSub(ctx, cfg); err != nil {
+			log.Fatal("error connecting the pubsub event bus. err: ", err)
+		}
+	}
+	if cfg.AnalysisEventBus == "memory" {
+		log.Println("⚠️  using the in-memory analysis bus, only a worker in this process gets the jobs")
+	}
+	cfg.AnalysisBus = newEventBus(cfg, cfg.AnalysisEventBus)
+	if cfg.SessionUpdatesEventBus == cfg.AnalysisEventBus {
+		cfg.SessionUpdatesBus = cfg.AnalysisBus
+	} else {
+		cfg.SessionUpdatesBus = newEventBus(cfg, cfg.SessionUpdatesEventBus)
+	}
+	log.Printf("✅ Event buses ready, analysis: %s, session updates: %s", cfg.AnalysisEventBus, cfg.SessionUpdatesEventBus)
+}
+
+func newEventBus(cfg *handlers.Config, driver string) eventbus.Bus {
+	bus, err := eventbus.New(eventbus.Config{
+		Driver: driver,
+		// rabbitmq connects in the background, the bus waits for it and publishing fails until then
+		RabbitConn: func() *amqp.Connection {
+			return cfg.RabbitConn
+		},
+		PubSubClient: func() (*pubsub.Client, error) {
+			if cfg.PubSubClient == nil {
+				return nil, fmt.Errorf("pubsub is not connected")
+			}
+			return cfg.PubSubClient, nil
+		},
+		DB:    cfg.DB,
+		DBURL: cfg.DBURL,
+	})
+	if err != nil {
+		log.Fatal("error creating event bus. err: ", err)
+	}
+	return bus
+}
@@ -47,19 +47,17 @@ func ConnectRabbit(ctx context.Context, cfg *handlers.Config) {
 		return
 	}
 }
-func ConnectPubSub(ctx context.Context, cfg *handlers.Config) {
-	if cfg.ENV == "development" {
-		log.Println("⚠️  Skipping Pub/Sub connection in development environment")
-		return
-	}
+
+// connectPubSub creates the pub/sub client, in development too when a bus is configured to use it.
+func connectPubSub(ctx context.Context, cfg *handlers.Config) error {
 	client, err := pubsub.NewClient(ctx, cfg.ProjectId)
 	if err != nil {
-		log.Println("❌ Failed to initialize Pub/Sub client:", err)
-		return
+		return err
 	}
 	cfg.PubSubClient = client
 
 	log.Println("✅ Pub/Sub client initialized")
+	return nil
 }
 
 func ConnectDB(ctx context.Context, cfg *handlers.Config) {
@@ -97,6 +95,7 @@ func ConnectDB(ctx context.Context, cfg *handlers.Config) {
 		db.SetMaxIdleConns(2)
 		db.SetConnMaxLifetime(5 * time.Minute)
 
+		cfg.DBConn = db
 		cfg.DB = database.New(db)
 		log.Println("✅ Postgres connected")
 		return
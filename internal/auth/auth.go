@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"context"
+	"net"
 	"net/http"
 	"time"
 
@@ -10,16 +10,16 @@ import (
 	"github.com/muhammadolammi/jobmatchapi/internal/database"
 )
 
-func MakeJwtTokenString(signgingKey []byte, userId, tokenName string, tokenExpiration int) (string, error) {
+func MakeJwtTokenString(keys *KeySet, userId, tokenName string, tokenExpiration int) (string, error) {
 	claims := jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
 		Issuer:    userId,
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(tokenExpiration) * time.Minute)),
 		Subject:   tokenName,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(signgingKey)
+	tokenString, err := keys.Sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -28,63 +28,213 @@ func MakeJwtTokenString(signgingKey []byte, userId, tokenName string, tokenExpir
 
 }
 
-func UpdateRefreshToken(signgingKey []byte, userId uuid.UUID, expirationTime int, w http.ResponseWriter, DB *database.Queries) error {
-	// create new jwt refresh token
-	jwtRefreshTokenString, err := MakeJwtTokenString(signgingKey, userId.String(), "refresh_token", expirationTime)
-	if err != nil {
-		return err
-	}
-	expiresAt := time.Now().UTC().Add(time.Duration(expirationTime) * time.Minute)
-	//  save to http cookie
+// RefreshTokenCookieName is the one cookie name used to set, read and clear refresh tokens.
+const RefreshTokenCookieName = "refresh_token"
+
+func SetRefreshTokenCookie(w http.ResponseWriter, value string, expiresAt time.Time) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "refreshtoken",
-		Value:    jwtRefreshTokenString,
+		Name:     RefreshTokenCookieName,
+		Value:    value,
+		Path:     "/api",
 		Expires:  expiresAt,
 		HttpOnly: true,
 		Secure:   getSecureMode(),
 		SameSite: http.SameSiteStrictMode,
 	})
-	// save refresh to db
-	err = DB.UpdateRefreshToken(context.Background(), database.UpdateRefreshTokenParams{
-		ExpiresAt: expiresAt,
-		Token:     jwtRefreshTokenString,
-		UserID:    userId,
+}
+
+func ClearRefreshTokenCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshTokenCookieName,
+		Value:    "",
+		Path:     "/api",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   getSecureMode(),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// OAuthStateCookieName binds an oidc login to the browser that started it, so a callback link
+// can't be replayed in someone else's browser.
+const OAuthStateCookieName = "oauth_state"
+
+func SetOAuthStateCookie(w http.ResponseWriter, value string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OAuthStateCookieName,
+		Value:    value,
+		Path:     "/api/oauth",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   getSecureMode(),
+		// lax, the provider redirects back with a cross site top level navigation
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func ClearOAuthStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OAuthStateCookieName,
+		Value:    "",
+		Path:     "/api/oauth",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   getSecureMode(),
+		SameSite: http.SameSiteLaxMode,
 	})
+}
 
+// ClientIP returns the caller ip. chi's RealIP middleware has already replaced RemoteAddr
+// with the forwarded ip when there is one.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return err
+		return r.RemoteAddr
 	}
-
-	return nil
+	return host
 }
-func CreateRefreshToken(signgingKey []byte, userId uuid.UUID, expirationTime int, w http.ResponseWriter, DB *database.Queries) error {
+
+// CreateRefreshToken starts a new device session (token family) for the user.
+func CreateRefreshToken(keys *KeySet, userId uuid.UUID, expirationTime int, w http.ResponseWriter, r *http.Request, DB *database.Queries) error {
 
 	// create new jwt refresh token
-	jwtRefreshTokenString, err := MakeJwtTokenString(signgingKey, userId.String(), "refresh_token", expirationTime)
+	jwtRefreshTokenString, err := MakeJwtTokenString(keys, userId.String(), "refresh_token", expirationTime)
 	if err != nil {
 		return err
 	}
 
-	expiresAt := time.Now().UTC().Add(time.Duration(expirationTime) * time.Minute)
-	//  save to http cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    jwtRefreshTokenString,
-		Expires:  expiresAt,
-		HttpOnly: true,
-		Secure:   getSecureMode(),
-		SameSite: http.SameSiteStrictMode,
-	})
-	// save refresh to db
-	_, err = DB.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
-		ExpiresAt: expiresAt,
-		Token:     jwtRefreshTokenString,
-		UserID:    userId,
+	now := time.Now().UTC()
+	expiresAt := now.Add(time.Duration(expirationTime) * time.Minute)
+	// save refresh to db, only the hash is stored
+	_, err = DB.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		ExpiresAt:       expiresAt,
+		TokenHash:       HashToken(jwtRefreshTokenString),
+		UserID:          userId,
+		UserAgent:       r.UserAgent(),
+		IpAddress:       ClientIP(r),
+		FamilyID:        uuid.New(),
+		FamilyCreatedAt: now,
 	})
-
 	if err != nil {
 		return err
 	}
+	//  save to http cookie
+	SetRefreshTokenCookie(w, jwtRefreshTokenString, expiresAt)
 
 	return nil
 }
+
+// RotateRefreshToken saves a child of the current token in the same family and returns it.
+// The caller marks the parent as rotated (in the same transaction) and sets the cookie.
+func RotateRefreshToken(keys *KeySet, current database.RefreshToken, expirationTime int, r *http.Request, DB *database.Queries) (string, time.Time, error) {
+	jwtRefreshTokenString, err := MakeJwtTokenString(keys, current.UserID.String(), "refresh_token", expirationTime)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().UTC().Add(time.Duration(expirationTime) * time.Minute)
+	_, err = DB.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		ExpiresAt:       expiresAt,
+		TokenHash:       HashToken(jwtRefreshTokenString),
+		UserID:          current.UserID,
+		UserAgent:       r.UserAgent(),
+		IpAddress:       ClientIP(r),
+		FamilyID:        current.FamilyID,
+		FamilyCreatedAt: current.FamilyCreatedAt,
+		ParentID:        uuid.NullUUID{UUID: current.ID, Valid: true},
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return jwtRefreshTokenString, expiresAt, nil
+}
+
+type EmailVerificationClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// MakeEmailVerificationToken signs a token tied to both the user and the email it was sent to,
+// so a link stops working if the email on the account changes.
+func MakeEmailVerificationToken(keys *KeySet, userId uuid.UUID, email string, tokenExpiration int) (string, error) {
+	claims := EmailVerificationClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    userId.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(tokenExpiration) * time.Minute)),
+			Subject:   "email_verification",
+		},
+	}
+	return keys.Sign(claims)
+}
+
+func ParseEmailVerificationToken(keys *KeySet, tokenString string) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+	_, err := keys.Parse(tokenString, claims, jwt.WithSubject("email_verification"))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// OIDCSignupClaims carry a verified provider identity to the complete signup step,
+// where the user adds the role and profile details the provider doesn't know.
+type OIDCSignupClaims struct {
+	Provider        string `json:"provider"`
+	ProviderSubject string `json:"provider_sub"`
+	Email           string `json:"email"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	jwt.RegisteredClaims
+}
+
+func MakeOIDCSignupToken(keys *KeySet, claims OIDCSignupClaims, tokenExpiration int) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(tokenExpiration) * time.Minute)),
+		Subject:   "oidc_signup",
+	}
+	return keys.Sign(claims)
+}
+
+func ParseOIDCSignupToken(keys *KeySet, tokenString string) (*OIDCSignupClaims, error) {
+	claims := &OIDCSignupClaims{}
+	_, err := keys.Parse(tokenString, claims, jwt.WithSubject("oidc_signup"))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// AccessClaims are the claims of an access token. Act is only set on impersonation tokens and
+// names the admin acting as the user (RFC 8693 actor claim).
+type AccessClaims struct {
+	Act *Actor `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// MakeImpersonationToken signs an access token for the target user that carries the admin in act.
+func MakeImpersonationToken(keys *KeySet, targetUserId, adminId uuid.UUID, tokenExpiration int) (string, *AccessClaims, error) {
+	claims := &AccessClaims{
+		Act: &Actor{Subject: adminId.String()},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    targetUserId.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(tokenExpiration) * time.Minute)),
+			Subject:   "access_token",
+		},
+	}
+	tokenString, err := keys.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, claims, nil
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEmailVerificationToken(t *testing.T) {
+	keys := NewHMACKeySet([]byte("secret"))
+	userID := uuid.New()
+	token, err := MakeEmailVerificationToken(keys, userID, "ada@example.com", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseEmailVerificationToken(keys, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Issuer != userID.String() || claims.Email != "ada@example.com" {
+		t.Errorf("claims = %s %s, want %s ada@example.com", claims.Issuer, claims.Email, userID)
+	}
+
+	expired, _ := MakeEmailVerificationToken(keys, userID, "ada@example.com", -1)
+	access, _ := MakeJwtTokenString(keys, userID.String(), "access_token", 60)
+	otherKeys, _ := MakeEmailVerificationToken(NewHMACKeySet([]byte("other")), userID, "ada@example.com", 60)
+	tests := map[string]string{
+		"expired":          expired,
+		"access token":     access,
+		"signed elsewhere": otherKeys,
+		"garbage":          "not-a-token",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseEmailVerificationToken(keys, token); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestOIDCSignupToken(t *testing.T) {
+	keys := NewHMACKeySet([]byte("secret"))
+	in := OIDCSignupClaims{
+		Provider:        "google",
+		ProviderSubject: "1234567890",
+		Email:           "ada@example.com",
+		FirstName:       "Ada",
+		LastName:        "Lovelace",
+	}
+	token, err := MakeOIDCSignupToken(keys, in, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseOIDCSignupToken(keys, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Provider != in.Provider || claims.ProviderSubject != in.ProviderSubject || claims.Email != in.Email ||
+		claims.FirstName != in.FirstName || claims.LastName != in.LastName {
+		t.Errorf("claims = %+v, want %+v", claims, in)
+	}
+	if claims.ID == "" {
+		t.Error("token has no jti")
+	}
+
+	expired, _ := MakeOIDCSignupToken(keys, in, -1)
+	verification, _ := MakeEmailVerificationToken(keys, uuid.New(), "ada@example.com", 10)
+	otherKeys, _ := MakeOIDCSignupToken(NewHMACKeySet([]byte("other")), in, 10)
+	tests := map[string]string{
+		"expired":            expired,
+		"verification token": verification,
+		"signed elsewhere":   otherKeys,
+		"garbage":            "not-a-token",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseOIDCSignupToken(keys, token); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestImpersonationToken(t *testing.T) {
+	keys := NewHMACKeySet([]byte("secret"))
+	target := uuid.New()
+	admin := uuid.New()
+	token, made, err := MakeImpersonationToken(keys, target, admin, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &AccessClaims{}
+	if _, err := keys.Parse(token, claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "access_token" || claims.Issuer != target.String() {
+		t.Errorf("token is %s for %s, want access_token for %s", claims.Subject, claims.Issuer, target)
+	}
+	if claims.Act == nil || claims.Act.Subject != admin.String() {
+		t.Errorf("act = %+v, want admin %s", claims.Act, admin)
+	}
+	if claims.ID == "" || claims.ID != made.ID {
+		t.Errorf("jti = %q, want %q from the returned claims", claims.ID, made.ID)
+	}
+	if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != 15*time.Minute {
+		t.Errorf("lifetime = %v, want 15m", got)
+	}
+
+	// a normal login token must not look like an impersonation
+	access, err := MakeJwtTokenString(keys, target.String(), "access_token", 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims = &AccessClaims{}
+	if _, err := keys.Parse(access, claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Act != nil {
+		t.Errorf("login token has act %+v", claims.Act)
+	}
+}
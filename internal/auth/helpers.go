@@ -1,6 +1,12 @@
 package auth
 
-import "os"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+)
 
 func getSecureMode() bool {
 	enviroment := os.Getenv("ENV")
@@ -10,3 +16,18 @@ func getSecureMode() bool {
 	}
 	return securemode
 }
+
+// GenerateRandomToken returns a url safe random token made from n random bytes.
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex sha256 of a token. Only this hash is stored in the db.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
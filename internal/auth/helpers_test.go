@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestHashToken(t *testing.T) {
+	// sha256 of "abc"
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Errorf("HashToken(abc) = %s, want %s", got, want)
+	}
+	if HashToken("token-a") == HashToken("token-b") {
+		t.Error("different tokens hash the same")
+	}
+}
+
+func TestGenerateRandomToken(t *testing.T) {
+	seen := map[string]bool{}
+	for range 100 {
+		token, err := GenerateRandomToken(32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			t.Fatalf("token %q isn't url safe base64: %v", token, err)
+		}
+		if len(raw) != 32 {
+			t.Errorf("token has %d random bytes, want 32", len(raw))
+		}
+		if seen[token] {
+			t.Fatalf("token %q generated twice", token)
+		}
+		seen[token] = true
+	}
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is one asymmetric key identified by its kid. PrivateKey is only set on the active signing key.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// KeySet signs every token we issue and picks the verification key by the kid header.
+// Without an asymmetric signing key it falls back to HS256 with the shared JWT_KEY secret.
+type KeySet struct {
+	signing      *Key
+	verification map[string]*Key
+	hmacSecret   []byte
+	acceptHMAC   bool
+}
+
+type KeySetConfig struct {
+	Algorithm     string // HS256, RS256 or EdDSA
+	KeyID         string
+	PrivateKeyPEM []byte
+	// directory of <kid>.pem public keys that are still accepted, e.g. the previous signing key during rotation
+	VerifyKeysDir string
+	HMACSecret    []byte
+	// keep accepting HS256 tokens signed with HMACSecret after switching to asymmetric signing
+	AcceptHMAC bool
+}
+
+func NewHMACKeySet(secret []byte) *KeySet {
+	return &KeySet{
+		verification: map[string]*Key{},
+		hmacSecret:   secret,
+		acceptHMAC:   true,
+	}
+}
+
+func NewKeySet(cfg KeySetConfig) (*KeySet, error) {
+	alg := strings.ToUpper(cfg.Algorithm)
+	if alg == "" || alg == "HS256" {
+		return NewHMACKeySet(cfg.HMACSecret), nil
+	}
+	if cfg.KeyID == "" {
+		return nil, errors.New("a key id is needed for asymmetric signing")
+	}
+	if len(cfg.PrivateKeyPEM) == 0 {
+		return nil, errors.New("a private key is needed for asymmetric signing")
+	}
+	signing, err := parsePrivateKey(alg, cfg.KeyID, cfg.PrivateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	ks := &KeySet{
+		signing:      signing,
+		verification: map[string]*Key{signing.ID: signing},
+		hmacSecret:   cfg.HMACSecret,
+		acceptHMAC:   cfg.AcceptHMAC && len(cfg.HMACSecret) > 0,
+	}
+	if cfg.VerifyKeysDir != "" {
+		files, err := filepath.Glob(filepath.Join(cfg.VerifyKeysDir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+			if kid == signing.ID {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("error reading verification key %s. err: %v", file, err)
+			}
+			key, err := parsePublicKey(kid, data)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing verification key %s. err: %v", file, err)
+			}
+			ks.verification[kid] = key
+		}
+	}
+	return ks, nil
+}
+
+func parsePrivateKey(alg, kid string, data []byte) (*Key, error) {
+	switch alg {
+	case "RS256":
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing rsa private key. err: %v", err)
+		}
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, PrivateKey: priv, PublicKey: &priv.PublicKey}, nil
+	case "EDDSA":
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing ed25519 private key. err: %v", err)
+		}
+		edPriv := priv.(ed25519.PrivateKey)
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, PrivateKey: edPriv, PublicKey: edPriv.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm: %s", alg)
+	}
+}
+
+func parsePublicKey(kid string, data []byte) (*Key, error) {
+	if pub, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, PublicKey: pub}, nil
+	}
+	pub, err := jwt.ParseEdPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, errors.New("key is neither an rsa nor an ed25519 public key")
+	}
+	return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, PublicKey: pub}, nil
+}
+
+// Sign signs claims with the active key, adding its kid to the header.
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if k.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.hmacSecret)
+	}
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signing.PrivateKey)
+}
+
+// Keyfunc is passed to jwt.Parse. The key must match both the kid and the token algorithm,
+// so an attacker can't make us verify an HS256 token with a public key.
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if !k.acceptHMAC {
+			return nil, errors.New("hs256 tokens are not accepted")
+		}
+		return k.hmacSecret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	if key.Method.Alg() != token.Method.Alg() {
+		return nil, fmt.Errorf("key %s can't verify %s tokens", kid, token.Method.Alg())
+	}
+	return key.PublicKey, nil
+}
+
+// ValidMethods is passed to jwt.WithValidMethods.
+func (k *KeySet) ValidMethods() []string {
+	methods := map[string]bool{}
+	if k.acceptHMAC {
+		methods[jwt.SigningMethodHS256.Alg()] = true
+	}
+	for _, key := range k.verification {
+		methods[key.Method.Alg()] = true
+	}
+	valid := []string{}
+	for method := range methods {
+		valid = append(valid, method)
+	}
+	sort.Strings(valid)
+	return valid
+}
+
+// Parse verifies a token and fills claims.
+func (k *KeySet) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods(k.ValidMethods()))
+	return jwt.ParseWithClaims(tokenString, claims, k.Keyfunc, opts...)
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public verification keys. The HS256 secret is never published.
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	kids := []string{}
+	for kid := range k.verification {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	for _, kid := range kids {
+		key := k.verification[kid]
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return jwks
+}
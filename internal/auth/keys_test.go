@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func rsaKeyPEM(t *testing.T) (private, public []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeKeyPair(t, key, &key.PublicKey)
+}
+
+func edKeyPEM(t *testing.T) (private, public []byte) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeKeyPair(t, key, pub)
+}
+
+func encodeKeyPair(t *testing.T, private, public any) ([]byte, []byte) {
+	t.Helper()
+	privDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestKeySetSignAndParse(t *testing.T) {
+	rsaPriv, _ := rsaKeyPEM(t)
+	edPriv, _ := edKeyPEM(t)
+	tests := []struct {
+		name    string
+		cfg     KeySetConfig
+		wantAlg string
+	}{
+		{"hs256", KeySetConfig{HMACSecret: []byte("secret")}, "HS256"},
+		{"rs256", KeySetConfig{Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyPEM: rsaPriv}, "RS256"},
+		{"eddsa", KeySetConfig{Algorithm: "EdDSA", KeyID: "ed-1", PrivateKeyPEM: edPriv}, "EdDSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := NewKeySet(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed, err := ks.Sign(testClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := jwt.RegisteredClaims{}
+			token, err := ks.Parse(signed, &claims)
+			if err != nil {
+				t.Fatalf("parsing our own token: %v", err)
+			}
+			if token.Method.Alg() != tt.wantAlg {
+				t.Errorf("signed with %s, want %s", token.Method.Alg(), tt.wantAlg)
+			}
+			if kid, _ := token.Header["kid"].(string); kid != tt.cfg.KeyID {
+				t.Errorf("kid = %q, want %q", kid, tt.cfg.KeyID)
+			}
+			if claims.Subject != "user" {
+				t.Errorf("subject = %q", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestNewKeySetErrors(t *testing.T) {
+	rsaPriv, _ := rsaKeyPEM(t)
+	tests := []struct {
+		name string
+		cfg  KeySetConfig
+	}{
+		{"missing kid", KeySetConfig{Algorithm: "RS256", PrivateKeyPEM: rsaPriv}},
+		{"missing private key", KeySetConfig{Algorithm: "RS256", KeyID: "rsa-1"}},
+		{"unsupported algorithm", KeySetConfig{Algorithm: "ES256", KeyID: "ec-1", PrivateKeyPEM: rsaPriv}},
+		{"wrong key type", KeySetConfig{Algorithm: "EdDSA", KeyID: "ed-1", PrivateKeyPEM: rsaPriv}},
+		{"not a pem", KeySetConfig{Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyPEM: []byte("nope")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeySet(tt.cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// After a rotation tokens signed with the previous key keep verifying through VerifyKeysDir.
+func TestKeySetRotation(t *testing.T) {
+	oldPriv, oldPub := rsaKeyPEM(t)
+	newPriv, _ := edKeyPEM(t)
+	oldKeys, err := NewKeySet(KeySetConfig{Algorithm: "RS256", KeyID: "old", PrivateKeyPEM: oldPriv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := oldKeys.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "old.pem"), oldPub, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewKeySet(KeySetConfig{Algorithm: "EdDSA", KeyID: "new", PrivateKeyPEM: newPriv, VerifyKeysDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotated.Parse(oldToken, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token of the previous key rejected: %v", err)
+	}
+	newToken, _ := rotated.Sign(testClaims())
+	if _, err := oldKeys.Parse(newToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("the old key set accepted a key it doesn't know")
+	}
+	jwks := rotated.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "new" || jwks.Keys[1].Kid != "old" {
+		t.Errorf("jwks = %+v, want the new and old keys", jwks.Keys)
+	}
+	if jwks.Keys[0].Kty != "OKP" || jwks.Keys[1].Kty != "RSA" {
+		t.Errorf("jwks key types = %s, %s", jwks.Keys[0].Kty, jwks.Keys[1].Kty)
+	}
+}
+
+func TestKeySetRejects(t *testing.T) {
+	rsaPriv, rsaPub := rsaKeyPEM(t)
+	ks, err := NewKeySet(KeySetConfig{Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyPEM: rsaPriv, HMACSecret: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs256 := func(key []byte, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	otherPriv, _ := rsaKeyPEM(t)
+	other, _ := NewKeySet(KeySetConfig{Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyPEM: otherPriv})
+	forged, _ := other.Sign(testClaims())
+	unknownKid, _ := NewKeySet(KeySetConfig{Algorithm: "RS256", KeyID: "rsa-2", PrivateKeyPEM: rsaPriv})
+	unknown, _ := unknownKid.Sign(testClaims())
+
+	tests := map[string]string{
+		// HS256 isn't accepted unless AcceptHMAC is set
+		"hs256 with the shared secret": hs256([]byte("secret"), ""),
+		// the classic confusion attack, the public key used as an hmac secret
+		"hs256 with the public key": hs256(rsaPub, "rsa-1"),
+		"signed by another key":     forged,
+		"unknown kid":               unknown,
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ks.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestKeySetAcceptHMACDuringMigration(t *testing.T) {
+	rsaPriv, _ := rsaKeyPEM(t)
+	ks, err := NewKeySet(KeySetConfig{Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyPEM: rsaPriv, HMACSecret: []byte("secret"), AcceptHMAC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, _ := NewHMACKeySet([]byte("secret")).Sign(testClaims())
+	if _, err := ks.Parse(legacy, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("hs256 token rejected while AcceptHMAC is set: %v", err)
+	}
+	if got := ks.ValidMethods(); len(got) != 2 || got[0] != "HS256" || got[1] != "RS256" {
+		t.Errorf("ValidMethods() = %v", got)
+	}
+	for _, key := range ks.JWKS().Keys {
+		if key.Kty == "oct" {
+			t.Error("the hmac secret is published in the jwks")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+)
+
+// RevocationStore lets access tokens die before their expiry.
+// Single tokens are revoked by jti, all of a user's tokens by an issued-before cutoff.
+type RevocationStore interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error
+	IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error)
+}
+
+// MemoryRevocationStore only works for a single instance. Use it locally and in tests.
+type MemoryRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time    // jti -> token expiry
+	users  map[uuid.UUID]time.Time // user -> revoked before
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens: map[string]time.Time{},
+		users:  map[uuid.UUID]time.Time{},
+	}
+}
+
+func (s *MemoryRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for id, exp := range s.tokens {
+		if exp.Before(now) {
+			delete(s.tokens, id)
+		}
+	}
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before = before.UTC().Truncate(time.Second)
+	if current, ok := s.users[userID]; !ok || before.After(current) {
+		s.users[userID] = before
+	}
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.tokens[jti]; ok && jti != "" {
+		return true, nil
+	}
+	if before, ok := s.users[userID]; ok && issuedAt.Before(before) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// PostgresRevocationStore shares revocations across all instances.
+type PostgresRevocationStore struct {
+	DB *database.Queries
+}
+
+func NewPostgresRevocationStore(db *database.Queries) *PostgresRevocationStore {
+	return &PostgresRevocationStore{DB: db}
+}
+
+func (s *PostgresRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	err := s.DB.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{
+		Jti:       jti,
+		ExpiresAt: expiresAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	// expired tokens don't need to be tracked anymore
+	return s.DB.DeleteExpiredRevokedAccessTokens(ctx)
+}
+
+func (s *PostgresRevocationStore) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	return s.DB.RevokeUserAccessTokens(ctx, database.RevokeUserAccessTokensParams{
+		UserID: userID,
+		// iat has second precision
+		RevokedBefore: before.UTC().Truncate(time.Second),
+	})
+}
+
+func (s *PostgresRevocationStore) IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	return s.DB.IsAccessTokenRevoked(ctx, database.IsAccessTokenRevokedParams{
+		Jti:      jti,
+		UserID:   userID,
+		IssuedAt: issuedAt.UTC(),
+	})
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore()
+	now := time.Now().UTC()
+	user := uuid.New()
+	other := uuid.New()
+
+	if err := store.RevokeToken(ctx, "revoked-jti", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeUserTokens(ctx, user, now); err != nil {
+		t.Fatal(err)
+	}
+	// an older cutoff must not move the existing one back
+	if err := store.RevokeUserTokens(ctx, user, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		jti      string
+		userID   uuid.UUID
+		issuedAt time.Time
+		want     bool
+	}{
+		{"revoked jti", "revoked-jti", other, now, true},
+		{"other jti", "other-jti", other, now, false},
+		{"empty jti", "", other, now, false},
+		{"issued before user cutoff", "other-jti", user, now.Add(-time.Minute), true},
+		{"issued at user cutoff", "other-jti", user, now.Truncate(time.Second), false},
+		{"issued after user cutoff", "other-jti", user, now.Add(time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.IsRevoked(ctx, tt.jti, tt.userID, tt.issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsRevoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRevocationStoreDropsExpiredTokens(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore()
+	now := time.Now().UTC()
+	if err := store.RevokeToken(ctx, "expired", now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeToken(ctx, "live", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.tokens["expired"]; ok {
+		t.Error("expired token still tracked")
+	}
+	if _, ok := store.tokens["live"]; !ok {
+		t.Error("live token not tracked")
+	}
+}
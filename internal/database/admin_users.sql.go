@@ -0,0 +1,218 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: admin_users.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const adminCountUsers = `-- name: AdminCountUsers :one
+SELECT COUNT(*)
+FROM users
+LEFT JOIN subscriptions ON subscriptions.user_id = users.id
+LEFT JOIN plans ON plans.id = subscriptions.plan_id
+LEFT JOIN job_seeker_profiles ON job_seeker_profiles.user_id = users.id
+LEFT JOIN employer_profiles ON employer_profiles.user_id = users.id
+WHERE
+  ($1::text IS NULL
+    OR users.email ILIKE '%' || $1 || '%'
+    OR job_seeker_profiles.first_name || ' ' || job_seeker_profiles.last_name ILIKE '%' || $1 || '%'
+    OR employer_profiles.company_name ILIKE '%' || $1 || '%')
+  AND ($2::text IS NULL OR users.role = $2)
+  AND ($3::text IS NULL OR COALESCE(plans.name, 'free') = $3)
+  AND ($4::timestamp IS NULL OR users.created_at >= $4)
+  AND ($5::timestamp IS NULL OR users.created_at < $5)
+  AND ($6::boolean IS NULL OR (users.suspended_at IS NOT NULL) = $6)
+`
+
+type AdminCountUsersParams struct {
+	Search      sql.NullString
+	Role        sql.NullString
+	Plan        sql.NullString
+	CreatedFrom sql.NullTime
+	CreatedTo   sql.NullTime
+	Suspended   sql.NullBool
+}
+
+func (q *Queries) AdminCountUsers(ctx context.Context, arg AdminCountUsersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, adminCountUsers,
+		arg.Search,
+		arg.Role,
+		arg.Plan,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Suspended,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const adminListUsers = `-- name: AdminListUsers :many
+SELECT
+  users.id, users.email, users.role, users.password, users.created_at, users.email_verified_at, users.totp_secret, users.totp_enabled_at, users.totp_last_used_step, users.suspended_at, users.suspended_reason,
+  COALESCE(plans.name, 'free')::text AS plan_name,
+  COALESCE(subscriptions.status, 'free')::text AS subscription_status
+FROM users
+LEFT JOIN subscriptions ON subscriptions.user_id = users.id
+LEFT JOIN plans ON plans.id = subscriptions.plan_id
+LEFT JOIN job_seeker_profiles ON job_seeker_profiles.user_id = users.id
+LEFT JOIN employer_profiles ON employer_profiles.user_id = users.id
+WHERE
+  ($1::text IS NULL
+    OR users.email ILIKE '%' || $1 || '%'
+    OR job_seeker_profiles.first_name || ' ' || job_seeker_profiles.last_name ILIKE '%' || $1 || '%'
+    OR employer_profiles.company_name ILIKE '%' || $1 || '%')
+  AND ($2::text IS NULL OR users.role = $2)
+  AND ($3::text IS NULL OR COALESCE(plans.name, 'free') = $3)
+  AND ($4::timestamp IS NULL OR users.created_at >= $4)
+  AND ($5::timestamp IS NULL OR users.created_at < $5)
+  AND ($6::boolean IS NULL OR (users.suspended_at IS NOT NULL) = $6)
+ORDER BY users.created_at DESC, users.id
+LIMIT $8 OFFSET $7
+`
+
+type AdminListUsersParams struct {
+	Search      sql.NullString
+	Role        sql.NullString
+	Plan        sql.NullString
+	CreatedFrom sql.NullTime
+	CreatedTo   sql.NullTime
+	Suspended   sql.NullBool
+	PageOffset  int32
+	PageSize    int32
+}
+
+type AdminListUsersRow struct {
+	User               User
+	PlanName           string
+	SubscriptionStatus string
+}
+
+// users without a subscription are on the free plan
+func (q *Queries) AdminListUsers(ctx context.Context, arg AdminListUsersParams) ([]AdminListUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, adminListUsers,
+		arg.Search,
+		arg.Role,
+		arg.Plan,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Suspended,
+		arg.PageOffset,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AdminListUsersRow
+	for rows.Next() {
+		var i AdminListUsersRow
+		if err := rows.Scan(
+			&i.User.ID,
+			&i.User.Email,
+			&i.User.Role,
+			&i.User.Password,
+			&i.User.CreatedAt,
+			&i.User.EmailVerifiedAt,
+			&i.User.TotpSecret,
+			&i.User.TotpEnabledAt,
+			&i.User.TotpLastUsedStep,
+			&i.User.SuspendedAt,
+			&i.User.SuspendedReason,
+			&i.PlanName,
+			&i.SubscriptionStatus,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countUserSessions = `-- name: CountUserSessions :one
+SELECT COUNT(*) FROM sessions
+WHERE user_id = $1::uuid
+`
+
+func (q *Queries) CountUserSessions(ctx context.Context, dollar_1 uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserSessions, dollar_1)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET 
+  suspended_at = NOW(),
+  suspended_reason = $1
+WHERE id = $2
+`
+
+type SuspendUserParams struct {
+	SuspendedReason sql.NullString
+	ID              uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedReason, arg.ID)
+	return err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :exec
+UPDATE users
+SET 
+  suspended_at = NULL,
+  suspended_reason = NULL
+WHERE id = $1
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	return err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET 
+  role = $1
+WHERE id = $2
+RETURNING id, email, role, password, created_at, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, suspended_at, suspended_reason
+`
+
+type UpdateUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.Role, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Role,
+		&i.Password,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+	)
+	return i, err
+}
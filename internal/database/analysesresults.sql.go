@@ -8,10 +8,30 @@ package database
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const analysesResultsWrittenSince = `-- name: AnalysesResultsWrittenSince :one
+SELECT EXISTS (
+    SELECT 1 FROM analyses_results
+    WHERE session_id = $1 AND updated_at >= $2
+)
+`
+
+type AnalysesResultsWrittenSinceParams struct {
+	SessionID uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) AnalysesResultsWrittenSince(ctx context.Context, arg AnalysesResultsWrittenSinceParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, analysesResultsWrittenSince, arg.SessionID, arg.UpdatedAt)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const createOrUpdateAnalysesResults = `-- name: CreateOrUpdateAnalysesResults :exec
 INSERT INTO analyses_results (
 results, session_id)
@@ -19,6 +39,7 @@ VALUES ( $1, $2)
 ON CONFLICT (session_id)
 DO UPDATE SET
     results = EXCLUDED.results,
+    stale = FALSE,
     updated_at = CURRENT_TIMESTAMP
 `
 
@@ -33,7 +54,7 @@ func (q *Queries) CreateOrUpdateAnalysesResults(ctx context.Context, arg CreateO
 }
 
 const getAnalysesResultsBySession = `-- name: GetAnalysesResultsBySession :one
-SELECT id, session_id, results, created_at, updated_at FROM analyses_results WHERE session_id=$1
+SELECT id, session_id, results, created_at, updated_at, stale FROM analyses_results WHERE session_id=$1
 `
 
 func (q *Queries) GetAnalysesResultsBySession(ctx context.Context, sessionID uuid.UUID) (AnalysesResult, error) {
@@ -45,6 +66,16 @@ func (q *Queries) GetAnalysesResultsBySession(ctx context.Context, sessionID uui
 		&i.Results,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Stale,
 	)
 	return i, err
 }
+
+const markAnalysesResultsStale = `-- name: MarkAnalysesResultsStale :exec
+UPDATE analyses_results SET stale = TRUE WHERE session_id = $1
+`
+
+func (q *Queries) MarkAnalysesResultsStale(ctx context.Context, sessionID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markAnalysesResultsStale, sessionID)
+	return err
+}
@@ -0,0 +1,253 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: api_clients.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createAPIClient = `-- name: CreateAPIClient :one
+INSERT INTO api_clients (
+name, key_hash, key_prefix, allowed_origins )
+VALUES ( $1, $2, $3, $4 )
+RETURNING id, name, key_hash, key_prefix, previous_key_hash, previous_key_expires_at, allowed_origins, status, last_used_at, revoked_at, created_at, updated_at
+`
+
+type CreateAPIClientParams struct {
+	Name           string
+	KeyHash        string
+	KeyPrefix      string
+	AllowedOrigins []string
+}
+
+func (q *Queries) CreateAPIClient(ctx context.Context, arg CreateAPIClientParams) (ApiClient, error) {
+	row := q.db.QueryRowContext(ctx, createAPIClient,
+		arg.Name,
+		arg.KeyHash,
+		arg.KeyPrefix,
+		pq.Array(arg.AllowedOrigins),
+	)
+	var i ApiClient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.KeyHash,
+		&i.KeyPrefix,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		pq.Array(&i.AllowedOrigins),
+		&i.Status,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getAPIClient = `-- name: GetAPIClient :one
+SELECT id, name, key_hash, key_prefix, previous_key_hash, previous_key_expires_at, allowed_origins, status, last_used_at, revoked_at, created_at, updated_at FROM api_clients WHERE id = $1
+`
+
+func (q *Queries) GetAPIClient(ctx context.Context, id uuid.UUID) (ApiClient, error) {
+	row := q.db.QueryRowContext(ctx, getAPIClient, id)
+	var i ApiClient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.KeyHash,
+		&i.KeyPrefix,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		pq.Array(&i.AllowedOrigins),
+		&i.Status,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getAPIClients = `-- name: GetAPIClients :many
+SELECT id, name, key_hash, key_prefix, previous_key_hash, previous_key_expires_at, allowed_origins, status, last_used_at, revoked_at, created_at, updated_at FROM api_clients
+ORDER BY created_at
+`
+
+func (q *Queries) GetAPIClients(ctx context.Context) ([]ApiClient, error) {
+	rows, err := q.db.QueryContext(ctx, getAPIClients)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiClient
+	for rows.Next() {
+		var i ApiClient
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.KeyHash,
+			&i.KeyPrefix,
+			&i.PreviousKeyHash,
+			&i.PreviousKeyExpiresAt,
+			pq.Array(&i.AllowedOrigins),
+			&i.Status,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getActiveAPIClientByKeyHash = `-- name: GetActiveAPIClientByKeyHash :one
+SELECT id, name, key_hash, key_prefix, previous_key_hash, previous_key_expires_at, allowed_origins, status, last_used_at, revoked_at, created_at, updated_at FROM api_clients
+WHERE status = 'active'
+  AND (key_hash = $1 OR (previous_key_hash = $1 AND previous_key_expires_at > NOW()))
+`
+
+func (q *Queries) GetActiveAPIClientByKeyHash(ctx context.Context, keyHash string) (ApiClient, error) {
+	row := q.db.QueryRowContext(ctx, getActiveAPIClientByKeyHash, keyHash)
+	var i ApiClient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.KeyHash,
+		&i.KeyPrefix,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		pq.Array(&i.AllowedOrigins),
+		&i.Status,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const revokeAPIClient = `-- name: RevokeAPIClient :execrows
+UPDATE api_clients
+SET
+  status = 'revoked',
+  previous_key_hash = NULL,
+  previous_key_expires_at = NULL,
+  revoked_at = NOW(),
+  updated_at = NOW()
+WHERE id = $1 AND status = 'active'
+`
+
+func (q *Queries) RevokeAPIClient(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAPIClient, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateAPIClientKey = `-- name: RotateAPIClientKey :one
+UPDATE api_clients
+SET
+  previous_key_hash = key_hash,
+  previous_key_expires_at = $1,
+  key_hash = $2,
+  key_prefix = $3,
+  updated_at = NOW()
+WHERE id = $4 AND status = 'active'
+RETURNING id, name, key_hash, key_prefix, previous_key_hash, previous_key_expires_at, allowed_origins, status, last_used_at, revoked_at, created_at, updated_at
+`
+
+type RotateAPIClientKeyParams struct {
+	PreviousKeyExpiresAt sql.NullTime
+	KeyHash              string
+	KeyPrefix            string
+	ID                   uuid.UUID
+}
+
+func (q *Queries) RotateAPIClientKey(ctx context.Context, arg RotateAPIClientKeyParams) (ApiClient, error) {
+	row := q.db.QueryRowContext(ctx, rotateAPIClientKey,
+		arg.PreviousKeyExpiresAt,
+		arg.KeyHash,
+		arg.KeyPrefix,
+		arg.ID,
+	)
+	var i ApiClient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.KeyHash,
+		&i.KeyPrefix,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		pq.Array(&i.AllowedOrigins),
+		&i.Status,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const touchAPIClient = `-- name: TouchAPIClient :exec
+UPDATE api_clients
+SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+`
+
+// written at most once a minute per client so busy clients don't turn every request into a write
+func (q *Queries) TouchAPIClient(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchAPIClient, id)
+	return err
+}
+
+const updateAPIClientOrigins = `-- name: UpdateAPIClientOrigins :one
+UPDATE api_clients
+SET
+  allowed_origins = $1,
+  updated_at = NOW()
+WHERE id = $2
+RETURNING id, name, key_hash, key_prefix, previous_key_hash, previous_key_expires_at, allowed_origins, status, last_used_at, revoked_at, created_at, updated_at
+`
+
+type UpdateAPIClientOriginsParams struct {
+	AllowedOrigins []string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateAPIClientOrigins(ctx context.Context, arg UpdateAPIClientOriginsParams) (ApiClient, error) {
+	row := q.db.QueryRowContext(ctx, updateAPIClientOrigins, pq.Array(arg.AllowedOrigins), arg.ID)
+	var i ApiClient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.KeyHash,
+		&i.KeyPrefix,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		pq.Array(&i.AllowedOrigins),
+		&i.Status,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
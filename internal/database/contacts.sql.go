@@ -47,6 +47,16 @@ func (q *Queries) CreateContactMessage(ctx context.Context, arg CreateContactMes
 	return i, err
 }
 
+const deleteContactMessagesByEmail = `-- name: DeleteContactMessagesByEmail :exec
+DELETE FROM contact_messages
+WHERE email = $1
+`
+
+func (q *Queries) DeleteContactMessagesByEmail(ctx context.Context, email string) error {
+	_, err := q.db.ExecContext(ctx, deleteContactMessagesByEmail, email)
+	return err
+}
+
 const getContactDepartments = `-- name: GetContactDepartments :many
 SELECT id, name FROM contact_departments
 `
@@ -74,6 +84,43 @@ func (q *Queries) GetContactDepartments(ctx context.Context) ([]ContactDepartmen
 	return items, nil
 }
 
+const getContactMessagesByEmail = `-- name: GetContactMessagesByEmail :many
+SELECT id, first_name, last_name, email, contact_department_id, message, created_at FROM contact_messages
+WHERE email = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetContactMessagesByEmail(ctx context.Context, email string) ([]ContactMessage, error) {
+	rows, err := q.db.QueryContext(ctx, getContactMessagesByEmail, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ContactMessage
+	for rows.Next() {
+		var i ContactMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Email,
+			&i.ContactDepartmentID,
+			&i.Message,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getEmailLast24HourContactMessages = `-- name: GetEmailLast24HourContactMessages :one
 SELECT COUNT(*)
 FROM contact_messages
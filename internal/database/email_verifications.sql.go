@@ -0,0 +1,51 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: email_verifications.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createEmailVerificationSend = `-- name: CreateEmailVerificationSend :exec
+INSERT INTO email_verification_sends (
+user_id )
+VALUES ( $1 )
+`
+
+func (q *Queries) CreateEmailVerificationSend(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, createEmailVerificationSend, userID)
+	return err
+}
+
+const getUserLastHourEmailVerificationSends = `-- name: GetUserLastHourEmailVerificationSends :one
+SELECT COUNT(*)
+FROM email_verification_sends
+WHERE user_id = $1
+AND created_at >= NOW() - INTERVAL '1 hour'
+`
+
+func (q *Queries) GetUserLastHourEmailVerificationSends(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getUserLastHourEmailVerificationSends, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getUserLastMinuteEmailVerificationSends = `-- name: GetUserLastMinuteEmailVerificationSends :one
+SELECT COUNT(*)
+FROM email_verification_sends
+WHERE user_id = $1
+AND created_at >= NOW() - INTERVAL '1 minute'
+`
+
+func (q *Queries) GetUserLastMinuteEmailVerificationSends(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getUserLastMinuteEmailVerificationSends, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
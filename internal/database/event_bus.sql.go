@@ -0,0 +1,56 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: event_bus.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimEventBusMessage = `-- name: ClaimEventBusMessage :execrows
+INSERT INTO event_bus_claims (message_id, consumer_group)
+VALUES ($1, $2)
+ON CONFLICT (message_id, consumer_group) DO NOTHING
+`
+
+type ClaimEventBusMessageParams struct {
+	MessageID     uuid.UUID
+	ConsumerGroup string
+}
+
+func (q *Queries) ClaimEventBusMessage(ctx context.Context, arg ClaimEventBusMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimEventBusMessage, arg.MessageID, arg.ConsumerGroup)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteEventBusClaimsBefore = `-- name: DeleteEventBusClaimsBefore :exec
+DELETE FROM event_bus_claims
+WHERE claimed_at < $1
+`
+
+func (q *Queries) DeleteEventBusClaimsBefore(ctx context.Context, claimedAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteEventBusClaimsBefore, claimedAt)
+	return err
+}
+
+const publishNotification = `-- name: PublishNotification :exec
+SELECT pg_notify($1::text, $2::text)
+`
+
+type PublishNotificationParams struct {
+	Channel string
+	Payload string
+}
+
+func (q *Queries) PublishNotification(ctx context.Context, arg PublishNotificationParams) error {
+	_, err := q.db.ExecContext(ctx, publishNotification, arg.Channel, arg.Payload)
+	return err
+}
@@ -0,0 +1,95 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: impersonation_audit.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createImpersonationAuditEntry = `-- name: CreateImpersonationAuditEntry :exec
+INSERT INTO impersonation_audit_log (
+admin_id, target_user_id, token_id, method, path, status_code, reason, ip_address, user_agent )
+VALUES ( $1, $2, $3, $4, $5, $6, $7, $8, $9 )
+`
+
+type CreateImpersonationAuditEntryParams struct {
+	AdminID      uuid.UUID
+	TargetUserID uuid.UUID
+	TokenID      string
+	Method       string
+	Path         string
+	StatusCode   int32
+	Reason       sql.NullString
+	IpAddress    string
+	UserAgent    string
+}
+
+func (q *Queries) CreateImpersonationAuditEntry(ctx context.Context, arg CreateImpersonationAuditEntryParams) error {
+	_, err := q.db.ExecContext(ctx, createImpersonationAuditEntry,
+		arg.AdminID,
+		arg.TargetUserID,
+		arg.TokenID,
+		arg.Method,
+		arg.Path,
+		arg.StatusCode,
+		arg.Reason,
+		arg.IpAddress,
+		arg.UserAgent,
+	)
+	return err
+}
+
+const getImpersonationAuditLog = `-- name: GetImpersonationAuditLog :many
+SELECT id, admin_id, target_user_id, token_id, method, path, status_code, reason, ip_address, user_agent, created_at FROM impersonation_audit_log
+WHERE ($1::uuid IS NULL OR admin_id = $1)
+  AND ($2::uuid IS NULL OR target_user_id = $2)
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type GetImpersonationAuditLogParams struct {
+	AdminID      uuid.NullUUID
+	TargetUserID uuid.NullUUID
+	PageSize     int32
+}
+
+func (q *Queries) GetImpersonationAuditLog(ctx context.Context, arg GetImpersonationAuditLogParams) ([]ImpersonationAuditLog, error) {
+	rows, err := q.db.QueryContext(ctx, getImpersonationAuditLog, arg.AdminID, arg.TargetUserID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ImpersonationAuditLog
+	for rows.Next() {
+		var i ImpersonationAuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.AdminID,
+			&i.TargetUserID,
+			&i.TokenID,
+			&i.Method,
+			&i.Path,
+			&i.StatusCode,
+			&i.Reason,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
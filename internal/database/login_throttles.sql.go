@@ -0,0 +1,150 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: login_throttles.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const clearLoginThrottle = `-- name: ClearLoginThrottle :execrows
+DELETE FROM login_throttles
+WHERE scope = $1 AND key = $2
+`
+
+type ClearLoginThrottleParams struct {
+	Scope string
+	Key   string
+}
+
+func (q *Queries) ClearLoginThrottle(ctx context.Context, arg ClearLoginThrottleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, clearLoginThrottle, arg.Scope, arg.Key)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteStaleLoginThrottles = `-- name: DeleteStaleLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < NOW())
+`
+
+func (q *Queries) DeleteStaleLoginThrottles(ctx context.Context, lastFailedAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteStaleLoginThrottles, lastFailedAt)
+	return err
+}
+
+const getLockedLoginThrottles = `-- name: GetLockedLoginThrottles :many
+SELECT scope, key, failed_attempts, last_failed_at, locked_until FROM login_throttles
+WHERE locked_until > NOW()
+ORDER BY locked_until DESC
+`
+
+func (q *Queries) GetLockedLoginThrottles(ctx context.Context) ([]LoginThrottle, error) {
+	rows, err := q.db.QueryContext(ctx, getLockedLoginThrottles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LoginThrottle
+	for rows.Next() {
+		var i LoginThrottle
+		if err := rows.Scan(
+			&i.Scope,
+			&i.Key,
+			&i.FailedAttempts,
+			&i.LastFailedAt,
+			&i.LockedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLoginThrottle = `-- name: GetLoginThrottle :one
+SELECT scope, key, failed_attempts, last_failed_at, locked_until FROM login_throttles
+WHERE scope = $1 AND key = $2
+`
+
+type GetLoginThrottleParams struct {
+	Scope string
+	Key   string
+}
+
+func (q *Queries) GetLoginThrottle(ctx context.Context, arg GetLoginThrottleParams) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, getLoginThrottle, arg.Scope, arg.Key)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Scope,
+		&i.Key,
+		&i.FailedAttempts,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const lockLoginThrottle = `-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+SET 
+  locked_until = $3
+WHERE scope = $1 AND key = $2
+`
+
+type LockLoginThrottleParams struct {
+	Scope       string
+	Key         string
+	LockedUntil sql.NullTime
+}
+
+func (q *Queries) LockLoginThrottle(ctx context.Context, arg LockLoginThrottleParams) error {
+	_, err := q.db.ExecContext(ctx, lockLoginThrottle, arg.Scope, arg.Key, arg.LockedUntil)
+	return err
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (
+scope, key, failed_attempts, last_failed_at )
+VALUES ( $1, $2, 1, NOW() )
+ON CONFLICT (scope, key) DO UPDATE
+SET 
+  failed_attempts = CASE
+    WHEN login_throttles.last_failed_at < $3 THEN 1
+    ELSE login_throttles.failed_attempts + 1
+  END,
+  last_failed_at = NOW()
+RETURNING scope, key, failed_attempts, last_failed_at, locked_until
+`
+
+type RecordLoginFailureParams struct {
+	Scope       string
+	Key         string
+	WindowStart time.Time
+}
+
+// failures older than the window start a new count
+func (q *Queries) RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, recordLoginFailure, arg.Scope, arg.Key, arg.WindowStart)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Scope,
+		&i.Key,
+		&i.FailedAttempts,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
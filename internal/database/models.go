@@ -18,6 +18,22 @@ type AnalysesResult struct {
 	Results   json.RawMessage
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Stale     bool
+}
+
+type ApiClient struct {
+	ID                   uuid.UUID
+	Name                 string
+	KeyHash              string
+	KeyPrefix            string
+	PreviousKeyHash      sql.NullString
+	PreviousKeyExpiresAt sql.NullTime
+	AllowedOrigins       []string
+	Status               string
+	LastUsedAt           sql.NullTime
+	RevokedAt            sql.NullTime
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
 }
 
 type ContactDepartment struct {
@@ -35,6 +51,12 @@ type ContactMessage struct {
 	CreatedAt           sql.NullTime
 }
 
+type EmailVerificationSend struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
 type EmployerProfile struct {
 	ID              uuid.UUID
 	CompanyName     string
@@ -44,6 +66,26 @@ type EmployerProfile struct {
 	UserID          uuid.UUID
 }
 
+type EventBusClaim struct {
+	MessageID     uuid.UUID
+	ConsumerGroup string
+	ClaimedAt     time.Time
+}
+
+type ImpersonationAuditLog struct {
+	ID           uuid.UUID
+	AdminID      uuid.UUID
+	TargetUserID uuid.UUID
+	TokenID      string
+	Method       string
+	Path         string
+	StatusCode   int32
+	Reason       sql.NullString
+	IpAddress    string
+	UserAgent    string
+	CreatedAt    time.Time
+}
+
 type JobSeekerProfile struct {
 	ID        uuid.UUID
 	FirstName string
@@ -52,6 +94,87 @@ type JobSeekerProfile struct {
 	UserID    uuid.UUID
 }
 
+type LoginThrottle struct {
+	Scope          string
+	Key            string
+	FailedAttempts int32
+	LastFailedAt   time.Time
+	LockedUntil    sql.NullTime
+}
+
+type OauthState struct {
+	ID           uuid.UUID
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+type Organization struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedBy uuid.NullUUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type OrganizationInvitation struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	Email          string
+	Role           string
+	TokenHash      string
+	InvitedBy      uuid.NullUUID
+	ExpiresAt      time.Time
+	AcceptedAt     sql.NullTime
+	CreatedAt      time.Time
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           string
+	CreatedAt      time.Time
+}
+
+type Outbox struct {
+	ID          int64
+	Topic       string
+	MessageKey  string
+	Payload     string
+	Attempts    int32
+	LastError   sql.NullString
+	AvailableAt time.Time
+	PublishedAt sql.NullTime
+	DeadAt      sql.NullTime
+	CreatedAt   time.Time
+}
+
+type PasswordResetToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type PersonalAccessToken struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Name        string
+	TokenHash   string
+	TokenPrefix string
+	Scopes      []string
+	ExpiresAt   sql.NullTime
+	LastUsedAt  sql.NullTime
+	LastUsedIp  sql.NullString
+	RevokedAt   sql.NullTime
+	CreatedAt   time.Time
+}
+
 type Plan struct {
 	ID               uuid.UUID
 	Name             string
@@ -74,11 +197,18 @@ type Profession struct {
 }
 
 type RefreshToken struct {
-	ID        uuid.UUID
-	UserID    uuid.UUID
-	Token     string
-	ExpiresAt time.Time
-	CreatedAt time.Time
+	ID              uuid.UUID
+	UserID          uuid.UUID
+	TokenHash       string
+	ExpiresAt       time.Time
+	CreatedAt       time.Time
+	UserAgent       string
+	IpAddress       string
+	LastUsedAt      time.Time
+	FamilyID        uuid.UUID
+	FamilyCreatedAt time.Time
+	ParentID        uuid.NullUUID
+	RotatedAt       sql.NullTime
 }
 
 type Resume struct {
@@ -94,14 +224,51 @@ type Resume struct {
 	SessionID        uuid.UUID
 }
 
+type RevokedAccessToken struct {
+	Jti       string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type SecurityEvent struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	EventType string
+	IpAddress string
+	UserAgent string
+	Details   sql.NullString
+	CreatedAt time.Time
+}
+
 type Session struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	Name           string
-	UserID         uuid.UUID
+	UserID         uuid.NullUUID
 	Status         string
 	JobTitle       string
 	JobDescription string
+	OrganizationID uuid.NullUUID
+	ArchivedAt     sql.NullTime
+	UpdatedAt      time.Time
+	SearchVector   interface{}
+}
+
+type SessionEvent struct {
+	ID        int64
+	SessionID uuid.UUID
+	Payload   string
+	CreatedAt time.Time
+}
+
+type SessionStatusHistory struct {
+	ID         uuid.UUID
+	SessionID  uuid.UUID
+	FromStatus sql.NullString
+	ToStatus   string
+	Reason     string
+	ChangedBy  uuid.NullUUID
+	CreatedAt  time.Time
 }
 
 type Subscription struct {
@@ -127,14 +294,28 @@ type SubscriptionHistory struct {
 	CreatedAt      time.Time
 }
 
-type User struct {
+type TwoFactorRecoveryCode struct {
 	ID        uuid.UUID
-	Email     string
-	Role      string
-	Password  string
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
 	CreatedAt time.Time
 }
 
+type User struct {
+	ID               uuid.UUID
+	Email            string
+	Role             string
+	Password         string
+	CreatedAt        time.Time
+	EmailVerifiedAt  sql.NullTime
+	TotpSecret       sql.NullString
+	TotpEnabledAt    sql.NullTime
+	TotpLastUsedStep int64
+	SuspendedAt      sql.NullTime
+	SuspendedReason  sql.NullString
+}
+
 type UserDailyUsage struct {
 	ID         uuid.UUID
 	UserID     uuid.UUID
@@ -143,6 +324,16 @@ type UserDailyUsage struct {
 	LastUsedAt time.Time
 }
 
+type UserIdentity struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Provider    string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
 type UserProfession struct {
 	ID           uuid.UUID
 	UserID       uuid.UUID
@@ -150,3 +341,9 @@ type UserProfession struct {
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
+
+type UserTokenRevocation struct {
+	UserID        uuid.UUID
+	RevokedBefore time.Time
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,491 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: organizations.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const acceptOrganizationInvitation = `-- name: AcceptOrganizationInvitation :execrows
+UPDATE organization_invitations
+SET 
+  accepted_at = NOW()
+WHERE id = $1 AND accepted_at IS NULL
+`
+
+func (q *Queries) AcceptOrganizationInvitation(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acceptOrganizationInvitation, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countOrganizationMembers = `-- name: CountOrganizationMembers :one
+SELECT COUNT(*) FROM organization_members
+WHERE organization_id = $1
+`
+
+func (q *Queries) CountOrganizationMembers(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOrganizationMembers, organizationID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countOrganizationOwners = `-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members
+WHERE organization_id = $1 AND role = 'owner'
+`
+
+func (q *Queries) CountOrganizationOwners(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOrganizationOwners, organizationID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createOrganization = `-- name: CreateOrganization :one
+INSERT INTO organizations (
+name, created_by )
+VALUES ( $1, $2 )
+RETURNING id, name, created_by, created_at, updated_at
+`
+
+type CreateOrganizationParams struct {
+	Name      string
+	CreatedBy uuid.NullUUID
+}
+
+func (q *Queries) CreateOrganization(ctx context.Context, arg CreateOrganizationParams) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, createOrganization, arg.Name, arg.CreatedBy)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createOrganizationInvitation = `-- name: CreateOrganizationInvitation :one
+INSERT INTO organization_invitations (
+organization_id, email, role, token_hash, invited_by, expires_at )
+VALUES ( $1, $2, $3, $4, $5, $6 )
+RETURNING id, organization_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at
+`
+
+type CreateOrganizationInvitationParams struct {
+	OrganizationID uuid.UUID
+	Email          string
+	Role           string
+	TokenHash      string
+	InvitedBy      uuid.NullUUID
+	ExpiresAt      time.Time
+}
+
+func (q *Queries) CreateOrganizationInvitation(ctx context.Context, arg CreateOrganizationInvitationParams) (OrganizationInvitation, error) {
+	row := q.db.QueryRowContext(ctx, createOrganizationInvitation,
+		arg.OrganizationID,
+		arg.Email,
+		arg.Role,
+		arg.TokenHash,
+		arg.InvitedBy,
+		arg.ExpiresAt,
+	)
+	var i OrganizationInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.Email,
+		&i.Role,
+		&i.TokenHash,
+		&i.InvitedBy,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createOrganizationMember = `-- name: CreateOrganizationMember :one
+INSERT INTO organization_members (
+organization_id, user_id, role )
+VALUES ( $1, $2, $3 )
+RETURNING organization_id, user_id, role, created_at
+`
+
+type CreateOrganizationMemberParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           string
+}
+
+func (q *Queries) CreateOrganizationMember(ctx context.Context, arg CreateOrganizationMemberParams) (OrganizationMember, error) {
+	row := q.db.QueryRowContext(ctx, createOrganizationMember, arg.OrganizationID, arg.UserID, arg.Role)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.OrganizationID,
+		&i.UserID,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteExpiredOrganizationInvitations = `-- name: DeleteExpiredOrganizationInvitations :exec
+DELETE FROM organization_invitations
+WHERE organization_id = $1 AND accepted_at IS NULL AND expires_at < NOW()
+`
+
+func (q *Queries) DeleteExpiredOrganizationInvitations(ctx context.Context, organizationID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredOrganizationInvitations, organizationID)
+	return err
+}
+
+const deleteOrganization = `-- name: DeleteOrganization :exec
+DELETE FROM organizations
+WHERE id = $1
+`
+
+func (q *Queries) DeleteOrganization(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteOrganization, id)
+	return err
+}
+
+const deleteOrganizationInvitation = `-- name: DeleteOrganizationInvitation :execrows
+DELETE FROM organization_invitations
+WHERE id = $1 AND organization_id = $2 AND accepted_at IS NULL
+`
+
+type DeleteOrganizationInvitationParams struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+}
+
+func (q *Queries) DeleteOrganizationInvitation(ctx context.Context, arg DeleteOrganizationInvitationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOrganizationInvitation, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOrganizationMember = `-- name: DeleteOrganizationMember :execrows
+DELETE FROM organization_members
+WHERE organization_id = $1 AND user_id = $2
+`
+
+type DeleteOrganizationMemberParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) DeleteOrganizationMember(ctx context.Context, arg DeleteOrganizationMemberParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOrganizationMember, arg.OrganizationID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteSoleMemberOrganizations = `-- name: DeleteSoleMemberOrganizations :exec
+DELETE FROM organizations
+WHERE organizations.id IN (
+    SELECT members.organization_id FROM organization_members AS members
+    WHERE members.user_id = $1
+)
+AND NOT EXISTS (
+    SELECT 1 FROM organization_members AS others
+    WHERE others.organization_id = organizations.id AND others.user_id <> $1
+)
+`
+
+// organizations where the user is the only member go with the account
+func (q *Queries) DeleteSoleMemberOrganizations(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteSoleMemberOrganizations, userID)
+	return err
+}
+
+const getOrganization = `-- name: GetOrganization :one
+SELECT id, name, created_by, created_at, updated_at FROM organizations WHERE id = $1
+`
+
+func (q *Queries) GetOrganization(ctx context.Context, id uuid.UUID) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, getOrganization, id)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getOrganizationInvitationByHash = `-- name: GetOrganizationInvitationByHash :one
+SELECT id, organization_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at FROM organization_invitations
+WHERE token_hash = $1
+`
+
+func (q *Queries) GetOrganizationInvitationByHash(ctx context.Context, tokenHash string) (OrganizationInvitation, error) {
+	row := q.db.QueryRowContext(ctx, getOrganizationInvitationByHash, tokenHash)
+	var i OrganizationInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.Email,
+		&i.Role,
+		&i.TokenHash,
+		&i.InvitedBy,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOrganizationMember = `-- name: GetOrganizationMember :one
+SELECT organization_id, user_id, role, created_at FROM organization_members
+WHERE organization_id = $1 AND user_id = $2
+`
+
+type GetOrganizationMemberParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) GetOrganizationMember(ctx context.Context, arg GetOrganizationMemberParams) (OrganizationMember, error) {
+	row := q.db.QueryRowContext(ctx, getOrganizationMember, arg.OrganizationID, arg.UserID)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.OrganizationID,
+		&i.UserID,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOrganizationMembers = `-- name: GetOrganizationMembers :many
+SELECT organization_members.organization_id, organization_members.user_id, organization_members.role, organization_members.created_at, users.email
+FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.organization_id = $1
+ORDER BY organization_members.created_at
+`
+
+type GetOrganizationMembersRow struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           string
+	CreatedAt      time.Time
+	Email          string
+}
+
+func (q *Queries) GetOrganizationMembers(ctx context.Context, organizationID uuid.UUID) ([]GetOrganizationMembersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOrganizationMembers, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOrganizationMembersRow
+	for rows.Next() {
+		var i GetOrganizationMembersRow
+		if err := rows.Scan(
+			&i.OrganizationID,
+			&i.UserID,
+			&i.Role,
+			&i.CreatedAt,
+			&i.Email,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPendingOrganizationInvitations = `-- name: GetPendingOrganizationInvitations :many
+SELECT id, organization_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at FROM organization_invitations
+WHERE organization_id = $1 AND accepted_at IS NULL
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetPendingOrganizationInvitations(ctx context.Context, organizationID uuid.UUID) ([]OrganizationInvitation, error) {
+	rows, err := q.db.QueryContext(ctx, getPendingOrganizationInvitations, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrganizationInvitation
+	for rows.Next() {
+		var i OrganizationInvitation
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganizationID,
+			&i.Email,
+			&i.Role,
+			&i.TokenHash,
+			&i.InvitedBy,
+			&i.ExpiresAt,
+			&i.AcceptedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserOrganizations = `-- name: GetUserOrganizations :many
+SELECT organizations.id, organizations.name, organizations.created_by, organizations.created_at, organizations.updated_at, organization_members.role AS member_role
+FROM organizations
+JOIN organization_members ON organization_members.organization_id = organizations.id
+WHERE organization_members.user_id = $1
+ORDER BY organizations.created_at
+`
+
+type GetUserOrganizationsRow struct {
+	ID         uuid.UUID
+	Name       string
+	CreatedBy  uuid.NullUUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	MemberRole string
+}
+
+func (q *Queries) GetUserOrganizations(ctx context.Context, userID uuid.UUID) ([]GetUserOrganizationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserOrganizations, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserOrganizationsRow
+	for rows.Next() {
+		var i GetUserOrganizationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.MemberRole,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockOrganizationOwners = `-- name: LockOrganizationOwners :many
+SELECT user_id FROM organization_members
+WHERE organization_id = $1 AND role = 'owner'
+FOR UPDATE
+`
+
+// locks the owner rows so concurrent demotions and removals see each other's changes
+func (q *Queries) LockOrganizationOwners(ctx context.Context, organizationID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, lockOrganizationOwners, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateOrganizationMemberRole = `-- name: UpdateOrganizationMemberRole :one
+UPDATE organization_members
+SET 
+  role = $1
+WHERE organization_id = $2 AND user_id = $3
+RETURNING organization_id, user_id, role, created_at
+`
+
+type UpdateOrganizationMemberRoleParams struct {
+	Role           string
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) UpdateOrganizationMemberRole(ctx context.Context, arg UpdateOrganizationMemberRoleParams) (OrganizationMember, error) {
+	row := q.db.QueryRowContext(ctx, updateOrganizationMemberRole, arg.Role, arg.OrganizationID, arg.UserID)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.OrganizationID,
+		&i.UserID,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateOrganizationName = `-- name: UpdateOrganizationName :one
+UPDATE organizations
+SET 
+  name = $1,
+  updated_at = NOW()
+WHERE id = $2
+RETURNING id, name, created_by, created_at, updated_at
+`
+
+type UpdateOrganizationNameParams struct {
+	Name string
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateOrganizationName(ctx context.Context, arg UpdateOrganizationNameParams) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, updateOrganizationName, arg.Name, arg.ID)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
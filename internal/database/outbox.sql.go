@@ -0,0 +1,182 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outbox.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const claimOutboxMessages = `-- name: ClaimOutboxMessages :many
+UPDATE outbox
+SET available_at = $1
+WHERE id IN (
+    SELECT id FROM outbox
+    WHERE published_at IS NULL AND dead_at IS NULL AND available_at <= NOW()
+    ORDER BY id
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, topic, message_key, payload, attempts, last_error, available_at, published_at, dead_at, created_at
+`
+
+type ClaimOutboxMessagesParams struct {
+	LeasedUntil time.Time
+	BatchSize   int32
+}
+
+// leases the due messages to this relay by pushing available_at past the lease, other relays skip them
+// until it runs out. A relay that dies mid batch leaves its messages to be retried after the lease.
+func (q *Queries) ClaimOutboxMessages(ctx context.Context, arg ClaimOutboxMessagesParams) ([]Outbox, error) {
+	rows, err := q.db.QueryContext(ctx, claimOutboxMessages, arg.LeasedUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.Topic,
+			&i.MessageKey,
+			&i.Payload,
+			&i.Attempts,
+			&i.LastError,
+			&i.AvailableAt,
+			&i.PublishedAt,
+			&i.DeadAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createOutboxMessage = `-- name: CreateOutboxMessage :one
+INSERT INTO outbox (topic, message_key, payload)
+VALUES ($1, $2, $3)
+RETURNING id, topic, message_key, payload, attempts, last_error, available_at, published_at, dead_at, created_at
+`
+
+type CreateOutboxMessageParams struct {
+	Topic      string
+	MessageKey string
+	Payload    string
+}
+
+func (q *Queries) CreateOutboxMessage(ctx context.Context, arg CreateOutboxMessageParams) (Outbox, error) {
+	row := q.db.QueryRowContext(ctx, createOutboxMessage, arg.Topic, arg.MessageKey, arg.Payload)
+	var i Outbox
+	err := row.Scan(
+		&i.ID,
+		&i.Topic,
+		&i.MessageKey,
+		&i.Payload,
+		&i.Attempts,
+		&i.LastError,
+		&i.AvailableAt,
+		&i.PublishedAt,
+		&i.DeadAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deletePublishedOutboxMessagesBefore = `-- name: DeletePublishedOutboxMessagesBefore :exec
+DELETE FROM outbox
+WHERE published_at < $1
+`
+
+func (q *Queries) DeletePublishedOutboxMessagesBefore(ctx context.Context, publishedAt sql.NullTime) error {
+	_, err := q.db.ExecContext(ctx, deletePublishedOutboxMessagesBefore, publishedAt)
+	return err
+}
+
+const getOutboxStats = `-- name: GetOutboxStats :one
+SELECT
+    COUNT(*) FILTER (WHERE published_at IS NULL AND dead_at IS NULL) AS pending,
+    COUNT(*) FILTER (WHERE dead_at IS NOT NULL) AS dead,
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at) FILTER (WHERE published_at IS NULL AND dead_at IS NULL)), 0)::float8 AS lag_seconds
+FROM outbox
+`
+
+type GetOutboxStatsRow struct {
+	Pending    int64
+	Dead       int64
+	LagSeconds float64
+}
+
+// lag is how long the oldest undelivered message has been waiting
+func (q *Queries) GetOutboxStats(ctx context.Context) (GetOutboxStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getOutboxStats)
+	var i GetOutboxStatsRow
+	err := row.Scan(&i.Pending, &i.Dead, &i.LagSeconds)
+	return i, err
+}
+
+const markOutboxMessageFailed = `-- name: MarkOutboxMessageFailed :exec
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = $1,
+    available_at = $2,
+    dead_at = CASE WHEN $3::boolean THEN NOW() ELSE NULL END
+WHERE id = $4
+`
+
+type MarkOutboxMessageFailedParams struct {
+	LastError   sql.NullString
+	AvailableAt time.Time
+	Dead        bool
+	ID          int64
+}
+
+func (q *Queries) MarkOutboxMessageFailed(ctx context.Context, arg MarkOutboxMessageFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markOutboxMessageFailed,
+		arg.LastError,
+		arg.AvailableAt,
+		arg.Dead,
+		arg.ID,
+	)
+	return err
+}
+
+const markOutboxMessagePublished = `-- name: MarkOutboxMessagePublished :exec
+UPDATE outbox
+SET published_at = NOW(), attempts = attempts + 1
+WHERE id = $1
+`
+
+func (q *Queries) MarkOutboxMessagePublished(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markOutboxMessagePublished, id)
+	return err
+}
+
+const skipOutboxMessage = `-- name: SkipOutboxMessage :exec
+UPDATE outbox
+SET published_at = NOW(), last_error = $2
+WHERE id = $1
+`
+
+type SkipOutboxMessageParams struct {
+	ID        int64
+	LastError sql.NullString
+}
+
+// marks a message that isn't needed anymore as done without publishing it
+func (q *Queries) SkipOutboxMessage(ctx context.Context, arg SkipOutboxMessageParams) error {
+	_, err := q.db.ExecContext(ctx, skipOutboxMessage, arg.ID, arg.LastError)
+	return err
+}
@@ -0,0 +1,167 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: profiles.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const deleteUserProfessions = `-- name: DeleteUserProfessions :exec
+DELETE FROM user_professions
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserProfessions(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUserProfessions, userID)
+	return err
+}
+
+const employerCompanyNameTaken = `-- name: EmployerCompanyNameTaken :one
+SELECT EXISTS (
+    SELECT 1 FROM employer_profiles
+    WHERE LOWER(company_name) = LOWER($1) AND user_id <> $2
+)
+`
+
+type EmployerCompanyNameTakenParams struct {
+	CompanyName string
+	UserID      uuid.UUID
+}
+
+func (q *Queries) EmployerCompanyNameTaken(ctx context.Context, arg EmployerCompanyNameTakenParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, employerCompanyNameTaken, arg.CompanyName, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const employerCompanyWebsiteTaken = `-- name: EmployerCompanyWebsiteTaken :one
+SELECT EXISTS (
+    SELECT 1 FROM employer_profiles
+    WHERE LOWER(company_website) = LOWER($1) AND user_id <> $2
+)
+`
+
+type EmployerCompanyWebsiteTakenParams struct {
+	CompanyWebsite string
+	UserID         uuid.UUID
+}
+
+func (q *Queries) EmployerCompanyWebsiteTaken(ctx context.Context, arg EmployerCompanyWebsiteTakenParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, employerCompanyWebsiteTaken, arg.CompanyWebsite, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const getUserProfessionDetails = `-- name: GetUserProfessionDetails :many
+SELECT professions.id, professions.name, professions.created_at, professions.updated_at FROM professions
+JOIN user_professions ON user_professions.profession_id = professions.id
+WHERE user_professions.user_id = $1
+ORDER BY professions.name
+`
+
+func (q *Queries) GetUserProfessionDetails(ctx context.Context, userID uuid.UUID) ([]Profession, error) {
+	rows, err := q.db.QueryContext(ctx, getUserProfessionDetails, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Profession
+	for rows.Next() {
+		var i Profession
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertEmployerProfile = `-- name: UpsertEmployerProfile :one
+INSERT INTO employer_profiles (
+user_id, company_name, company_website, company_size, company_industry )
+VALUES ( $1, $2, $3, $4, $5 )
+ON CONFLICT (user_id) DO UPDATE
+SET
+  company_name = EXCLUDED.company_name,
+  company_website = EXCLUDED.company_website,
+  company_size = EXCLUDED.company_size,
+  company_industry = EXCLUDED.company_industry
+RETURNING id, company_name, company_website, company_size, company_industry, user_id
+`
+
+type UpsertEmployerProfileParams struct {
+	UserID          uuid.UUID
+	CompanyName     string
+	CompanyWebsite  string
+	CompanySize     int32
+	CompanyIndustry string
+}
+
+// users created through oidc or by an admin may not have a profile yet
+func (q *Queries) UpsertEmployerProfile(ctx context.Context, arg UpsertEmployerProfileParams) (EmployerProfile, error) {
+	row := q.db.QueryRowContext(ctx, upsertEmployerProfile,
+		arg.UserID,
+		arg.CompanyName,
+		arg.CompanyWebsite,
+		arg.CompanySize,
+		arg.CompanyIndustry,
+	)
+	var i EmployerProfile
+	err := row.Scan(
+		&i.ID,
+		&i.CompanyName,
+		&i.CompanyWebsite,
+		&i.CompanySize,
+		&i.CompanyIndustry,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const upsertJobSeekerProfile = `-- name: UpsertJobSeekerProfile :one
+INSERT INTO job_seeker_profiles (
+user_id, first_name, last_name )
+VALUES ( $1, $2, $3 )
+ON CONFLICT (user_id) DO UPDATE
+SET
+  first_name = EXCLUDED.first_name,
+  last_name = EXCLUDED.last_name
+RETURNING id, first_name, last_name, resume_url, user_id
+`
+
+type UpsertJobSeekerProfileParams struct {
+	UserID    uuid.UUID
+	FirstName string
+	LastName  string
+}
+
+func (q *Queries) UpsertJobSeekerProfile(ctx context.Context, arg UpsertJobSeekerProfileParams) (JobSeekerProfile, error) {
+	row := q.db.QueryRowContext(ctx, upsertJobSeekerProfile, arg.UserID, arg.FirstName, arg.LastName)
+	var i JobSeekerProfile
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.ResumeUrl,
+		&i.UserID,
+	)
+	return i, err
+}
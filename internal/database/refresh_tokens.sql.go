@@ -15,70 +15,179 @@ import (
 const createRefreshToken = `-- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (
 user_id, expires_at,
-token  )
-VALUES ( $1, $2, $3)
-RETURNING id, user_id, token, expires_at, created_at
+token_hash, user_agent, ip_address,
+family_id, family_created_at, parent_id  )
+VALUES ( $1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, user_id, token_hash, expires_at, created_at, user_agent, ip_address, last_used_at, family_id, family_created_at, parent_id, rotated_at
 `
 
 type CreateRefreshTokenParams struct {
-	UserID    uuid.UUID
-	ExpiresAt time.Time
-	Token     string
+	UserID          uuid.UUID
+	ExpiresAt       time.Time
+	TokenHash       string
+	UserAgent       string
+	IpAddress       string
+	FamilyID        uuid.UUID
+	FamilyCreatedAt time.Time
+	ParentID        uuid.NullUUID
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.UserID, arg.ExpiresAt, arg.Token)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.TokenHash,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.FamilyID,
+		arg.FamilyCreatedAt,
+		arg.ParentID,
+	)
 	var i RefreshToken
 	err := row.Scan(
 		&i.ID,
 		&i.UserID,
-		&i.Token,
+		&i.TokenHash,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.FamilyID,
+		&i.FamilyCreatedAt,
+		&i.ParentID,
+		&i.RotatedAt,
 	)
 	return i, err
 }
 
-const deleteRefreshToken = `-- name: DeleteRefreshToken :exec
+const deleteExpiredUserRefreshTokens = `-- name: DeleteExpiredUserRefreshTokens :exec
 DELETE FROM refresh_tokens
-WHERE token=$1
+WHERE user_id=$1 AND expires_at < NOW()
 `
 
-func (q *Queries) DeleteRefreshToken(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, deleteRefreshToken, token)
+func (q *Queries) DeleteExpiredUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredUserRefreshTokens, userID)
 	return err
 }
 
-const refreshTokenExists = `-- name: RefreshTokenExists :one
-SELECT EXISTS (
-    SELECT 1
-    FROM refresh_tokens
-    WHERE token = $1
-)
+const deleteRefreshTokenFamily = `-- name: DeleteRefreshTokenFamily :exec
+DELETE FROM refresh_tokens
+WHERE family_id=$1
 `
 
-func (q *Queries) RefreshTokenExists(ctx context.Context, token string) (bool, error) {
-	row := q.db.QueryRowContext(ctx, refreshTokenExists, token)
-	var exists bool
-	err := row.Scan(&exists)
-	return exists, err
+func (q *Queries) DeleteRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRefreshTokenFamily, familyID)
+	return err
 }
 
-const updateRefreshToken = `-- name: UpdateRefreshToken :exec
-UPDATE refresh_tokens
-SET 
-  token = $1,
-  expires_at = $2
-WHERE user_id = $3
+const deleteUserRefreshTokenFamily = `-- name: DeleteUserRefreshTokenFamily :execrows
+DELETE FROM refresh_tokens
+WHERE family_id=$1 AND user_id=$2
 `
 
-type UpdateRefreshTokenParams struct {
-	Token     string
-	ExpiresAt time.Time
-	UserID    uuid.UUID
+type DeleteUserRefreshTokenFamilyParams struct {
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) DeleteUserRefreshTokenFamily(ctx context.Context, arg DeleteUserRefreshTokenFamilyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserRefreshTokenFamily, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (q *Queries) UpdateRefreshToken(ctx context.Context, arg UpdateRefreshTokenParams) error {
-	_, err := q.db.ExecContext(ctx, updateRefreshToken, arg.Token, arg.ExpiresAt, arg.UserID)
+const deleteUserRefreshTokens = `-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens
+WHERE user_id=$1
+`
+
+func (q *Queries) DeleteUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUserRefreshTokens, userID)
 	return err
 }
+
+const getRefreshTokenByHash = `-- name: GetRefreshTokenByHash :one
+SELECT id, user_id, token_hash, expires_at, created_at, user_agent, ip_address, last_used_at, family_id, family_created_at, parent_id, rotated_at FROM refresh_tokens
+WHERE token_hash = $1
+`
+
+func (q *Queries) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshTokenByHash, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.FamilyID,
+		&i.FamilyCreatedAt,
+		&i.ParentID,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const getUserRefreshTokens = `-- name: GetUserRefreshTokens :many
+SELECT id, user_id, token_hash, expires_at, created_at, user_agent, ip_address, last_used_at, family_id, family_created_at, parent_id, rotated_at FROM refresh_tokens
+WHERE user_id = $1 AND rotated_at IS NULL AND expires_at > NOW()
+ORDER BY last_used_at DESC
+`
+
+func (q *Queries) GetUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getUserRefreshTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TokenHash,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastUsedAt,
+			&i.FamilyID,
+			&i.FamilyCreatedAt,
+			&i.ParentID,
+			&i.RotatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markRefreshTokenRotated = `-- name: MarkRefreshTokenRotated :execrows
+UPDATE refresh_tokens
+SET 
+  rotated_at = NOW(),
+  last_used_at = NOW()
+WHERE id = $1 AND rotated_at IS NULL
+`
+
+func (q *Queries) MarkRefreshTokenRotated(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markRefreshTokenRotated, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
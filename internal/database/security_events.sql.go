@@ -0,0 +1,92 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: security_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createSecurityEvent = `-- name: CreateSecurityEvent :one
+INSERT INTO security_events (
+user_id, event_type, ip_address, user_agent, details )
+VALUES ( $1, $2, $3, $4, $5 )
+RETURNING id, user_id, event_type, ip_address, user_agent, details, created_at
+`
+
+type CreateSecurityEventParams struct {
+	UserID    uuid.UUID
+	EventType string
+	IpAddress string
+	UserAgent string
+	Details   sql.NullString
+}
+
+func (q *Queries) CreateSecurityEvent(ctx context.Context, arg CreateSecurityEventParams) (SecurityEvent, error) {
+	row := q.db.QueryRowContext(ctx, createSecurityEvent,
+		arg.UserID,
+		arg.EventType,
+		arg.IpAddress,
+		arg.UserAgent,
+		arg.Details,
+	)
+	var i SecurityEvent
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.EventType,
+		&i.IpAddress,
+		&i.UserAgent,
+		&i.Details,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUserSecurityEvents = `-- name: GetUserSecurityEvents :many
+SELECT id, user_id, event_type, ip_address, user_agent, details, created_at FROM security_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetUserSecurityEventsParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetUserSecurityEvents(ctx context.Context, arg GetUserSecurityEventsParams) ([]SecurityEvent, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSecurityEvents, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SecurityEvent
+	for rows.Next() {
+		var i SecurityEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.EventType,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.Details,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,76 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: session_events.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createSessionEvent = `-- name: CreateSessionEvent :one
+INSERT INTO session_events (session_id, payload)
+VALUES ($1, $2)
+RETURNING id, session_id, payload, created_at
+`
+
+type CreateSessionEventParams struct {
+	SessionID uuid.UUID
+	Payload   string
+}
+
+func (q *Queries) CreateSessionEvent(ctx context.Context, arg CreateSessionEventParams) (SessionEvent, error) {
+	row := q.db.QueryRowContext(ctx, createSessionEvent, arg.SessionID, arg.Payload)
+	var i SessionEvent
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Payload,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSessionEventsAfter = `-- name: GetSessionEventsAfter :many
+SELECT id, session_id, payload, created_at FROM session_events
+WHERE session_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type GetSessionEventsAfterParams struct {
+	SessionID uuid.UUID
+	AfterID   int64
+	PageSize  int32
+}
+
+func (q *Queries) GetSessionEventsAfter(ctx context.Context, arg GetSessionEventsAfterParams) ([]SessionEvent, error) {
+	rows, err := q.db.QueryContext(ctx, getSessionEventsAfter, arg.SessionID, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SessionEvent
+	for rows.Next() {
+		var i SessionEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Payload,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,151 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: session_status_history.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createSessionStatusHistory = `-- name: CreateSessionStatusHistory :exec
+INSERT INTO session_status_history (session_id, from_status, to_status, reason, changed_by)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type CreateSessionStatusHistoryParams struct {
+	SessionID  uuid.UUID
+	FromStatus sql.NullString
+	ToStatus   string
+	Reason     string
+	ChangedBy  uuid.NullUUID
+}
+
+func (q *Queries) CreateSessionStatusHistory(ctx context.Context, arg CreateSessionStatusHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, createSessionStatusHistory,
+		arg.SessionID,
+		arg.FromStatus,
+		arg.ToStatus,
+		arg.Reason,
+		arg.ChangedBy,
+	)
+	return err
+}
+
+const getLatestSessionStatusChangeTo = `-- name: GetLatestSessionStatusChangeTo :one
+SELECT id, session_id, from_status, to_status, reason, changed_by, created_at FROM session_status_history
+WHERE session_id = $1 AND to_status = $2
+ORDER BY created_at DESC, id DESC
+LIMIT 1
+`
+
+type GetLatestSessionStatusChangeToParams struct {
+	SessionID uuid.UUID
+	ToStatus  string
+}
+
+func (q *Queries) GetLatestSessionStatusChangeTo(ctx context.Context, arg GetLatestSessionStatusChangeToParams) (SessionStatusHistory, error) {
+	row := q.db.QueryRowContext(ctx, getLatestSessionStatusChangeTo, arg.SessionID, arg.ToStatus)
+	var i SessionStatusHistory
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.Reason,
+		&i.ChangedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSessionStatusHistory = `-- name: GetSessionStatusHistory :many
+SELECT id, session_id, from_status, to_status, reason, changed_by, created_at FROM session_status_history
+WHERE session_id = $1
+ORDER BY created_at, id
+`
+
+func (q *Queries) GetSessionStatusHistory(ctx context.Context, sessionID uuid.UUID) ([]SessionStatusHistory, error) {
+	rows, err := q.db.QueryContext(ctx, getSessionStatusHistory, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SessionStatusHistory
+	for rows.Next() {
+		var i SessionStatusHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.FromStatus,
+			&i.ToStatus,
+			&i.Reason,
+			&i.ChangedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setSessionStatusChangeContext = `-- name: SetSessionStatusChangeContext :exec
+SELECT
+    set_config('jobmatch.status_reason', $1::text, true),
+    set_config('jobmatch.status_changed_by', $2::text, true)
+`
+
+type SetSessionStatusChangeContextParams struct {
+	Reason    string
+	ChangedBy string
+}
+
+// read by the sessions_status_transition trigger for the history row, until the transaction ends
+func (q *Queries) SetSessionStatusChangeContext(ctx context.Context, arg SetSessionStatusChangeContextParams) error {
+	_, err := q.db.ExecContext(ctx, setSessionStatusChangeContext, arg.Reason, arg.ChangedBy)
+	return err
+}
+
+const transitionSessionStatus = `-- name: TransitionSessionStatus :one
+UPDATE sessions
+SET status = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2 AND status = $3
+RETURNING id, created_at, name, user_id, status, job_title, job_description, organization_id, archived_at, updated_at, search_vector
+`
+
+type TransitionSessionStatusParams struct {
+	ToStatus   string
+	ID         uuid.UUID
+	FromStatus string
+}
+
+// only moves the session if nothing changed its status since it was read
+func (q *Queries) TransitionSessionStatus(ctx context.Context, arg TransitionSessionStatusParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, transitionSessionStatus, arg.ToStatus, arg.ID, arg.FromStatus)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.UserID,
+		&i.Status,
+		&i.JobTitle,
+		&i.JobDescription,
+		&i.OrganizationID,
+		&i.ArchivedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
+	)
+	return i, err
+}
@@ -7,20 +7,123 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const countSessionsByStatus = `-- name: CountSessionsByStatus :many
+SELECT status, COUNT(*) AS count FROM sessions
+WHERE (
+    ($1::uuid IS NULL AND user_id = $2::uuid AND organization_id IS NULL)
+    OR organization_id = $1::uuid
+)
+AND ($3::boolean OR archived_at IS NULL)
+AND ($4::timestamp IS NULL OR created_at >= $4::timestamp)
+AND ($5::timestamp IS NULL OR created_at < $5::timestamp)
+AND ($6::text IS NULL OR job_title ILIKE '%' || $6::text || '%')
+AND ($7::text IS NULL
+    OR search_vector @@ websearch_to_tsquery('english', $7::text))
+GROUP BY status
+`
+
+type CountSessionsByStatusParams struct {
+	OrganizationID  uuid.NullUUID
+	UserID          uuid.UUID
+	IncludeArchived bool
+	CreatedFrom     sql.NullTime
+	CreatedTo       sql.NullTime
+	JobTitle        sql.NullString
+	Search          sql.NullString
+}
+
+type CountSessionsByStatusRow struct {
+	Status string
+	Count  int64
+}
+
+// ListSessions filters without status and the cursor, for the status tabs
+func (q *Queries) CountSessionsByStatus(ctx context.Context, arg CountSessionsByStatusParams) ([]CountSessionsByStatusRow, error) {
+	rows, err := q.db.QueryContext(ctx, countSessionsByStatus,
+		arg.OrganizationID,
+		arg.UserID,
+		arg.IncludeArchived,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.JobTitle,
+		arg.Search,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountSessionsByStatusRow
+	for rows.Next() {
+		var i CountSessionsByStatusRow
+		if err := rows.Scan(&i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createOrganizationSession = `-- name: CreateOrganizationSession :one
+INSERT INTO sessions (
+name, user_id, job_title, job_description, organization_id )
+VALUES ( $1, $2, $3, $4, $5 )
+RETURNING id, created_at, name, user_id, status, job_title, job_description, organization_id, archived_at, updated_at, search_vector
+`
+
+type CreateOrganizationSessionParams struct {
+	Name           string
+	UserID         uuid.NullUUID
+	JobTitle       string
+	JobDescription string
+	OrganizationID uuid.NullUUID
+}
+
+func (q *Queries) CreateOrganizationSession(ctx context.Context, arg CreateOrganizationSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, createOrganizationSession,
+		arg.Name,
+		arg.UserID,
+		arg.JobTitle,
+		arg.JobDescription,
+		arg.OrganizationID,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.UserID,
+		&i.Status,
+		&i.JobTitle,
+		&i.JobDescription,
+		&i.OrganizationID,
+		&i.ArchivedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
 const createSession = `-- name: CreateSession :one
 INSERT INTO sessions (
 name, user_id, job_title, job_description )
 VALUES ( $1, $2, $3,$4)
-RETURNING id, created_at, name, user_id, status, job_title, job_description
+RETURNING id, created_at, name, user_id, status, job_title, job_description, organization_id, archived_at, updated_at, search_vector
 `
 
 type CreateSessionParams struct {
 	Name           string
-	UserID         uuid.UUID
+	UserID         uuid.NullUUID
 	JobTitle       string
 	JobDescription string
 }
@@ -41,12 +144,25 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		&i.Status,
 		&i.JobTitle,
 		&i.JobDescription,
+		&i.OrganizationID,
+		&i.ArchivedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions WHERE id = $1
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteSession, id)
+	return err
+}
+
 const getSession = `-- name: GetSession :one
-SELECT id, created_at, name, user_id, status, job_title, job_description FROM sessions 
+SELECT id, created_at, name, user_id, status, job_title, job_description, organization_id, archived_at, updated_at, search_vector FROM sessions 
 WHERE id = $1
 `
 
@@ -61,18 +177,22 @@ func (q *Queries) GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 		&i.Status,
 		&i.JobTitle,
 		&i.JobDescription,
+		&i.OrganizationID,
+		&i.ArchivedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
 const getUserSessions = `-- name: GetUserSessions :many
-SELECT id, created_at, name, user_id, status, job_title, job_description FROM sessions 
-WHERE user_id = $1
+SELECT id, created_at, name, user_id, status, job_title, job_description, organization_id, archived_at, updated_at, search_vector FROM sessions 
+WHERE user_id = $1::uuid
 ORDER BY created_at DESC
 `
 
-func (q *Queries) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
-	rows, err := q.db.QueryContext(ctx, getUserSessions, userID)
+func (q *Queries) GetUserSessions(ctx context.Context, dollar_1 uuid.UUID) ([]Session, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSessions, dollar_1)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +208,10 @@ func (q *Queries) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]Sess
 			&i.Status,
 			&i.JobTitle,
 			&i.JobDescription,
+			&i.OrganizationID,
+			&i.ArchivedAt,
+			&i.UpdatedAt,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -102,18 +226,168 @@ func (q *Queries) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]Sess
 	return items, nil
 }
 
-const updateSessionStatus = `-- name: UpdateSessionStatus :exec
-UPDATE sessions 
-SET status=$1
-WHERE id=$2
+const listSessions = `-- name: ListSessions :many
+SELECT id, created_at, name, user_id, status, job_title, job_description, organization_id, archived_at, updated_at, search_vector FROM sessions
+WHERE (
+    ($1::uuid IS NULL AND user_id = $2::uuid AND organization_id IS NULL)
+    OR organization_id = $1::uuid
+)
+AND ($3::boolean OR archived_at IS NULL)
+AND ($4::text IS NULL OR status = $4::text)
+AND ($5::timestamp IS NULL OR created_at >= $5::timestamp)
+AND ($6::timestamp IS NULL OR created_at < $6::timestamp)
+AND ($7::text IS NULL OR job_title ILIKE '%' || $7::text || '%')
+AND ($8::text IS NULL
+    OR search_vector @@ websearch_to_tsquery('english', $8::text))
+AND ($9::uuid IS NULL
+    OR ($10::text = 'newest' AND (created_at, id) < ($11::timestamp, $9::uuid))
+    OR ($10::text = 'oldest' AND (created_at, id) > ($11::timestamp, $9::uuid))
+    OR ($10::text = 'name_asc' AND (name, id) > ($12::text, $9::uuid))
+    OR ($10::text = 'name_desc' AND (name, id) < ($12::text, $9::uuid))
+)
+ORDER BY
+    CASE WHEN $10::text = 'newest' THEN created_at END DESC,
+    CASE WHEN $10::text = 'oldest' THEN created_at END ASC,
+    CASE WHEN $10::text = 'name_asc' THEN name END ASC,
+    CASE WHEN $10::text = 'name_desc' THEN name END DESC,
+    CASE WHEN $10::text IN ('newest', 'name_desc') THEN id END DESC,
+    CASE WHEN $10::text IN ('oldest', 'name_asc') THEN id END ASC
+LIMIT $13
 `
 
-type UpdateSessionStatusParams struct {
-	Status string
-	ID     uuid.UUID
+type ListSessionsParams struct {
+	OrganizationID  uuid.NullUUID
+	UserID          uuid.UUID
+	IncludeArchived bool
+	Status          sql.NullString
+	CreatedFrom     sql.NullTime
+	CreatedTo       sql.NullTime
+	JobTitle        sql.NullString
+	Search          sql.NullString
+	CursorID        uuid.NullUUID
+	Sort            string
+	CursorCreatedAt sql.NullTime
+	CursorName      sql.NullString
+	PageSize        int32
 }
 
-func (q *Queries) UpdateSessionStatus(ctx context.Context, arg UpdateSessionStatusParams) error {
-	_, err := q.db.ExecContext(ctx, updateSessionStatus, arg.Status, arg.ID)
-	return err
+// the user's personal sessions, or an organization's when organization_id is set. Filters are optional,
+// the cursor is the sort value and id of the last session on the previous page.
+func (q *Queries) ListSessions(ctx context.Context, arg ListSessionsParams) ([]Session, error) {
+	rows, err := q.db.QueryContext(ctx, listSessions,
+		arg.OrganizationID,
+		arg.UserID,
+		arg.IncludeArchived,
+		arg.Status,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.JobTitle,
+		arg.Search,
+		arg.CursorID,
+		arg.Sort,
+		arg.CursorCreatedAt,
+		arg.CursorName,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Name,
+			&i.UserID,
+			&i.Status,
+			&i.JobTitle,
+			&i.JobDescription,
+			&i.OrganizationID,
+			&i.ArchivedAt,
+			&i.UpdatedAt,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setSessionArchived = `-- name: SetSessionArchived :one
+UPDATE sessions
+SET archived_at = CASE WHEN $1::boolean THEN COALESCE(archived_at, CURRENT_TIMESTAMP) ELSE NULL END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $2
+RETURNING id, created_at, name, user_id, status, job_title, job_description, organization_id, archived_at, updated_at, search_vector
+`
+
+type SetSessionArchivedParams struct {
+	Archived bool
+	ID       uuid.UUID
+}
+
+func (q *Queries) SetSessionArchived(ctx context.Context, arg SetSessionArchivedParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, setSessionArchived, arg.Archived, arg.ID)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.UserID,
+		&i.Status,
+		&i.JobTitle,
+		&i.JobDescription,
+		&i.OrganizationID,
+		&i.ArchivedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const updateSessionDetails = `-- name: UpdateSessionDetails :one
+UPDATE sessions
+SET name = $1, job_title = $2, job_description = $3, updated_at = CURRENT_TIMESTAMP
+WHERE id = $4
+RETURNING id, created_at, name, user_id, status, job_title, job_description, organization_id, archived_at, updated_at, search_vector
+`
+
+type UpdateSessionDetailsParams struct {
+	Name           string
+	JobTitle       string
+	JobDescription string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateSessionDetails(ctx context.Context, arg UpdateSessionDetailsParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, updateSessionDetails,
+		arg.Name,
+		arg.JobTitle,
+		arg.JobDescription,
+		arg.ID,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.UserID,
+		&i.Status,
+		&i.JobTitle,
+		&i.JobDescription,
+		&i.OrganizationID,
+		&i.ArchivedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
+	)
+	return i, err
 }
@@ -50,3 +50,41 @@ func (q *Queries) CreateSubscriptionHistory(ctx context.Context, arg CreateSubsc
 	)
 	return i, err
 }
+
+const getUserSubscriptionHistory = `-- name: GetUserSubscriptionHistory :many
+SELECT id, subscription_id, user_id, event_type, old_value, new_value, event_source, created_at FROM subscription_history
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetUserSubscriptionHistory(ctx context.Context, userID uuid.UUID) ([]SubscriptionHistory, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSubscriptionHistory, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SubscriptionHistory
+	for rows.Next() {
+		var i SubscriptionHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.UserID,
+			&i.EventType,
+			&i.OldValue,
+			&i.NewValue,
+			&i.EventSource,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
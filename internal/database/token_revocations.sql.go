@@ -0,0 +1,83 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: token_revocations.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteExpiredRevokedAccessTokens = `-- name: DeleteExpiredRevokedAccessTokens :exec
+DELETE FROM revoked_access_tokens
+WHERE expires_at < NOW()
+`
+
+func (q *Queries) DeleteExpiredRevokedAccessTokens(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredRevokedAccessTokens)
+	return err
+}
+
+const isAccessTokenRevoked = `-- name: IsAccessTokenRevoked :one
+SELECT (
+    EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)
+    OR EXISTS (
+        SELECT 1 FROM user_token_revocations
+        WHERE user_id = $2 AND revoked_before > $3
+    )
+)::boolean AS revoked
+`
+
+type IsAccessTokenRevokedParams struct {
+	Jti      string
+	UserID   uuid.UUID
+	IssuedAt time.Time
+}
+
+func (q *Queries) IsAccessTokenRevoked(ctx context.Context, arg IsAccessTokenRevokedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isAccessTokenRevoked, arg.Jti, arg.UserID, arg.IssuedAt)
+	var revoked bool
+	err := row.Scan(&revoked)
+	return revoked, err
+}
+
+const revokeAccessToken = `-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (
+jti, expires_at )
+VALUES ( $1, $2 )
+ON CONFLICT (jti) DO NOTHING
+`
+
+type RevokeAccessTokenParams struct {
+	Jti       string
+	ExpiresAt time.Time
+}
+
+func (q *Queries) RevokeAccessToken(ctx context.Context, arg RevokeAccessTokenParams) error {
+	_, err := q.db.ExecContext(ctx, revokeAccessToken, arg.Jti, arg.ExpiresAt)
+	return err
+}
+
+const revokeUserAccessTokens = `-- name: RevokeUserAccessTokens :exec
+INSERT INTO user_token_revocations (
+user_id, revoked_before )
+VALUES ( $1, $2 )
+ON CONFLICT (user_id)
+DO UPDATE SET
+    revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before),
+    updated_at = NOW()
+`
+
+type RevokeUserAccessTokensParams struct {
+	UserID        uuid.UUID
+	RevokedBefore time.Time
+}
+
+func (q *Queries) RevokeUserAccessTokens(ctx context.Context, arg RevokeUserAccessTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserAccessTokens, arg.UserID, arg.RevokedBefore)
+	return err
+}
@@ -0,0 +1,142 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: two_factor.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const countUnusedTwoFactorRecoveryCodes = `-- name: CountUnusedTwoFactorRecoveryCodes :one
+SELECT COUNT(*)
+FROM two_factor_recovery_codes
+WHERE user_id = $1 AND used_at IS NULL
+`
+
+func (q *Queries) CountUnusedTwoFactorRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnusedTwoFactorRecoveryCodes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createTwoFactorRecoveryCode = `-- name: CreateTwoFactorRecoveryCode :exec
+INSERT INTO two_factor_recovery_codes (
+user_id, code_hash )
+VALUES ( $1, $2 )
+`
+
+type CreateTwoFactorRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) CreateTwoFactorRecoveryCode(ctx context.Context, arg CreateTwoFactorRecoveryCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createTwoFactorRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const deleteUserTwoFactorRecoveryCodes = `-- name: DeleteUserTwoFactorRecoveryCodes :exec
+DELETE FROM two_factor_recovery_codes
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserTwoFactorRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUserTwoFactorRecoveryCodes, userID)
+	return err
+}
+
+const disableUserTOTP = `-- name: DisableUserTOTP :exec
+UPDATE users
+SET 
+  totp_secret = NULL,
+  totp_enabled_at = NULL,
+  totp_last_used_step = 0
+WHERE id = $1
+`
+
+func (q *Queries) DisableUserTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableUserTOTP, id)
+	return err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :exec
+UPDATE users
+SET 
+  totp_enabled_at = NOW(),
+  totp_last_used_step = $1
+WHERE id = $2
+`
+
+type EnableUserTOTPParams struct {
+	TotpLastUsedStep int64
+	ID               uuid.UUID
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableUserTOTP, arg.TotpLastUsedStep, arg.ID)
+	return err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET 
+  totp_secret = $1,
+  totp_enabled_at = NULL,
+  totp_last_used_step = 0
+WHERE id = $2
+`
+
+type SetUserTOTPSecretParams struct {
+	TotpSecret sql.NullString
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTOTPSecret, arg.TotpSecret, arg.ID)
+	return err
+}
+
+const useTwoFactorRecoveryCode = `-- name: UseTwoFactorRecoveryCode :execrows
+UPDATE two_factor_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+`
+
+type UseTwoFactorRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) UseTwoFactorRecoveryCode(ctx context.Context, arg UseTwoFactorRecoveryCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTwoFactorRecoveryCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useUserTOTPStep = `-- name: UseUserTOTPStep :execrows
+UPDATE users
+SET 
+  totp_last_used_step = $1
+WHERE id = $2 AND totp_last_used_step < $1
+`
+
+type UseUserTOTPStepParams struct {
+	TotpLastUsedStep int64
+	ID               uuid.UUID
+}
+
+func (q *Queries) UseUserTOTPStep(ctx context.Context, arg UseUserTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useUserTOTPStep, arg.TotpLastUsedStep, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
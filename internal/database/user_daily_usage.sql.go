@@ -12,6 +12,33 @@ import (
 	"github.com/google/uuid"
 )
 
+const consumeUserUsage = `-- name: ConsumeUserUsage :execrows
+INSERT INTO user_daily_usages (user_id, max_daily, count, last_used_at)
+VALUES ($1, $2, 1, $3::timestamp)
+ON CONFLICT (user_id)
+DO UPDATE SET
+    count = CASE WHEN user_daily_usages.last_used_at <= $3::timestamp - INTERVAL '24 hours' THEN 1 ELSE user_daily_usages.count + 1 END,
+    last_used_at = $3::timestamp
+WHERE user_daily_usages.last_used_at <= $3::timestamp - INTERVAL '24 hours'
+    OR user_daily_usages.count < user_daily_usages.max_daily
+`
+
+type ConsumeUserUsageParams struct {
+	UserID   uuid.UUID
+	MaxDaily int32
+	Now      time.Time
+}
+
+// counts one analysis, a use more than 24 hours after the last one starts a new window.
+// No row is written when the limit of the current window is reached.
+func (q *Queries) ConsumeUserUsage(ctx context.Context, arg ConsumeUserUsageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, consumeUserUsage, arg.UserID, arg.MaxDaily, arg.Now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUserUsage = `-- name: GetUserUsage :one
 SELECT user_id, count, max_daily, last_used_at FROM user_daily_usages WHERE user_id = $1
 `
@@ -57,6 +84,18 @@ func (q *Queries) InsertUserUsage(ctx context.Context, arg InsertUserUsageParams
 	return err
 }
 
+const refundUserUsage = `-- name: RefundUserUsage :exec
+UPDATE user_daily_usages
+SET count = GREATEST(count - 1, 0)
+WHERE user_id = $1 AND last_used_at > NOW() - INTERVAL '24 hours'
+`
+
+// gives back one analysis, unless the usage window it was counted in is over
+func (q *Queries) RefundUserUsage(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, refundUserUsage, userID)
+	return err
+}
+
 const updateUserDailyUsageLimit = `-- name: UpdateUserDailyUsageLimit :exec
 INSERT INTO user_daily_usages (user_id, max_daily, count, last_used_at)
 VALUES ($1, $2, 0, NOW())
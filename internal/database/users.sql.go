@@ -76,7 +76,7 @@ const createUser = `-- name: CreateUser :one
 INSERT INTO users (
 email, role,password  )
 VALUES ( $1, $2, $3 )
-RETURNING id, email, role, password, created_at
+RETURNING id, email, role, password, created_at, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, suspended_at, suspended_reason
 `
 
 type CreateUserParams struct {
@@ -94,6 +94,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Role,
 		&i.Password,
 		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
 	)
 	return i, err
 }
@@ -144,7 +150,7 @@ func (q *Queries) GetJobSeekerProfileByUserID(ctx context.Context, userID uuid.U
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, email, role, password, created_at FROM users WHERE $1=id
+SELECT id, email, role, password, created_at, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, suspended_at, suspended_reason FROM users WHERE $1=id
 `
 
 func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
@@ -156,12 +162,18 @@ func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Role,
 		&i.Password,
 		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
 	)
 	return i, err
 }
 
 const getUserWithEmail = `-- name: GetUserWithEmail :one
-SELECT id, email, role, password, created_at FROM users WHERE $1=email
+SELECT id, email, role, password, created_at, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, suspended_at, suspended_reason FROM users WHERE $1=email
 `
 
 func (q *Queries) GetUserWithEmail(ctx context.Context, email string) (User, error) {
@@ -173,12 +185,18 @@ func (q *Queries) GetUserWithEmail(ctx context.Context, email string) (User, err
 		&i.Role,
 		&i.Password,
 		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
 	)
 	return i, err
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, email, role, password, created_at FROM users
+SELECT id, email, role, password, created_at, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, suspended_at, suspended_reason FROM users
 `
 
 func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
@@ -196,6 +214,12 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.Role,
 			&i.Password,
 			&i.CreatedAt,
+			&i.EmailVerifiedAt,
+			&i.TotpSecret,
+			&i.TotpEnabledAt,
+			&i.TotpLastUsedStep,
+			&i.SuspendedAt,
+			&i.SuspendedReason,
 		); err != nil {
 			return nil, err
 		}
@@ -210,6 +234,18 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :exec
+UPDATE users
+SET 
+  email_verified_at = NOW()
+WHERE id = $1 AND email_verified_at IS NULL
+`
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markUserEmailVerified, id)
+	return err
+}
+
 const updatePassword = `-- name: UpdatePassword :exec
 UPDATE users
 SET 
@@ -227,6 +263,23 @@ func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams)
 	return err
 }
 
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET 
+  password = $1
+WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	Password string
+	ID       uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.Password, arg.ID)
+	return err
+}
+
 const userExists = `-- name: UserExists :one
 SELECT EXISTS (
     SELECT 1
@@ -0,0 +1,99 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/streadway/amqp"
+)
+
+// Message is one event on a topic. Key says what it's about, e.g. session.<id> on session_updates,
+// backends that route by key (rabbitmq) use it as the routing key.
+type Message struct {
+	Key  string
+	Data []byte
+}
+
+// Handler processes a message. Messages aren't redelivered, a handler that returns an error has
+// the error logged and the message dropped.
+type Handler func(ctx context.Context, msg Message) error
+
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg Message) error
+}
+
+type Subscriber interface {
+	// Subscribe calls handler for the messages published on topic until ctx is done. Subscribers with
+	// the same group share the messages, each goes to one of them. An empty group gets every message.
+	Subscribe(ctx context.Context, topic, group string, handler Handler) error
+}
+
+// Bus carries the analysis jobs to the worker and the session updates back. Backends are picked with
+// ANALYSIS_EVENT_BUS and SESSION_UPDATES_EVENT_BUS so the api can run without the brokers.
+type Bus interface {
+	Publisher
+	Subscriber
+	Close() error
+}
+
+type Config struct {
+	Driver string // rabbitmq, pubsub, postgres or memory
+	// the rabbitmq and pub/sub clients connect in the background, the backends get them when they need them
+	RabbitConn   func() *amqp.Connection
+	PubSubClient func() (*pubsub.Client, error)
+	DB           *database.Queries
+	DBURL        string // the postgres backend listens on its own connection
+}
+
+func New(cfg Config) (Bus, error) {
+	switch cfg.Driver {
+	case "rabbitmq":
+		if cfg.RabbitConn == nil {
+			return nil, fmt.Errorf("rabbitmq event bus needs a connection")
+		}
+		return NewRabbitMQ(cfg.RabbitConn), nil
+	case "pubsub":
+		if cfg.PubSubClient == nil {
+			return nil, fmt.Errorf("pubsub event bus needs a client")
+		}
+		return NewPubSub(cfg.PubSubClient), nil
+	case "postgres":
+		if cfg.DB == nil || cfg.DBURL == "" {
+			return nil, fmt.Errorf("postgres event bus needs DB_URL")
+		}
+		return NewPostgres(cfg.DB, cfg.DBURL), nil
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown event bus driver: %s", cfg.Driver)
+	}
+}
+
+// errNotConnected is returned while a backend's client is still connecting, consumers wait for it quietly.
+var errNotConnected = errors.New("event bus backend is not connected")
+
+// keepConsuming runs consume until ctx is done, starting it again with a backoff when it stops.
+func keepConsuming(ctx context.Context, name string, consume func() error) {
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+	for {
+		err := consume()
+		if ctx.Err() != nil {
+			return
+		}
+		if !errors.Is(err, errNotConnected) {
+			log.Printf("%s stopped, restarting. err: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
@@ -0,0 +1,117 @@
+package eventbus
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// memoryBuffer is how many messages a subscriber can fall behind by before they are dropped.
+const memoryBuffer = 256
+
+// Memory delivers messages within the process. It's for development and tests, every instance has
+// its own bus.
+type Memory struct {
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+}
+
+type memoryTopic struct {
+	// the subscribers without a group
+	broadcast map[chan Message]struct{}
+	groups    map[string]*memoryGroup
+}
+
+// memoryGroup hands its messages to its members in turn.
+type memoryGroup struct {
+	members []chan Message
+	next    int
+}
+
+func NewMemory() *Memory {
+	return &Memory{topics: map[string]*memoryTopic{}}
+}
+
+func (b *Memory) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{broadcast: map[chan Message]struct{}{}, groups: map[string]*memoryGroup{}}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// Publish drops the message when nobody subscribes to the topic, like the brokers do.
+func (b *Memory) Publish(ctx context.Context, topic string, msg Message) error {
+	b.mu.Lock()
+	var targets []chan Message
+	if t, ok := b.topics[topic]; ok {
+		for ch := range t.broadcast {
+			targets = append(targets, ch)
+		}
+		for _, g := range t.groups {
+			targets = append(targets, g.members[g.next%len(g.members)])
+			g.next++
+		}
+	}
+	b.mu.Unlock()
+	for _, ch := range targets {
+		select {
+		case ch <- msg:
+		default:
+			log.Printf("dropping %s message %q for a slow subscriber", topic, msg.Key)
+		}
+	}
+	return nil
+}
+
+func (b *Memory) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	ch := make(chan Message, memoryBuffer)
+	b.mu.Lock()
+	t := b.topic(topic)
+	if group == "" {
+		t.broadcast[ch] = struct{}{}
+	} else {
+		if t.groups[group] == nil {
+			t.groups[group] = &memoryGroup{}
+		}
+		t.groups[group].members = append(t.groups[group].members, ch)
+	}
+	b.mu.Unlock()
+	defer b.unsubscribe(topic, group, ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-ch:
+			if err := handler(ctx, msg); err != nil {
+				log.Printf("error handling %s message %q. err: %v", topic, msg.Key, err)
+			}
+		}
+	}
+}
+
+func (b *Memory) unsubscribe(topic, group string, ch chan Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topics[topic]
+	if group == "" {
+		delete(t.broadcast, ch)
+		return
+	}
+	g := t.groups[group]
+	for i, member := range g.members {
+		if member == ch {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	if len(g.members) == 0 {
+		delete(t.groups, group)
+	}
+}
+
+func (b *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,151 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// subscribe starts a subscriber and waits until it's registered, so nothing published after is missed.
+func subscribe(t *testing.T, ctx context.Context, bus *Memory, topic, group string, got chan<- Message) {
+	t.Helper()
+	before := subscriberCount(bus, topic)
+	go bus.Subscribe(ctx, topic, group, func(ctx context.Context, msg Message) error {
+		got <- msg
+		return nil
+	})
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if subscriberCount(bus, topic) > before {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("subscriber didn't register")
+}
+
+func subscriberCount(bus *Memory, name string) int {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	topic, ok := bus.topics[name]
+	if !ok {
+		return 0
+	}
+	n := len(topic.broadcast)
+	for _, g := range topic.groups {
+		n += len(g.members)
+	}
+	return n
+}
+
+func receive(t *testing.T, ch <-chan Message) Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return Message{}
+	}
+}
+
+func expectNothing(t *testing.T, ch <-chan Message) {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected message %q", msg.Key)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMemoryBroadcast(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemory()
+	first, second := make(chan Message, 1), make(chan Message, 1)
+	subscribe(t, ctx, bus, "session_updates", "", first)
+	subscribe(t, ctx, bus, "session_updates", "", second)
+
+	if err := bus.Publish(ctx, "session_updates", Message{Key: "session.1", Data: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range []chan Message{first, second} {
+		if msg := receive(t, ch); msg.Key != "session.1" || string(msg.Data) != `{}` {
+			t.Errorf("got %q %q", msg.Key, msg.Data)
+		}
+	}
+}
+
+func TestMemoryGroupDeliversOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemory()
+	got := make(chan Message, 10)
+	subscribe(t, ctx, bus, "resume-analysis", "workers", got)
+	subscribe(t, ctx, bus, "resume-analysis", "workers", got)
+
+	counts := map[string]int{}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		bus.Publish(ctx, "resume-analysis", Message{Key: key})
+	}
+	for range 4 {
+		msg := receive(t, got)
+		counts[msg.Key]++
+	}
+	expectNothing(t, got)
+	for key, n := range counts {
+		if n != 1 {
+			t.Errorf("%s delivered %d times", key, n)
+		}
+	}
+}
+
+func TestMemoryTopicsAreSeparate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemory()
+	got := make(chan Message, 1)
+	subscribe(t, ctx, bus, "resume-analysis", "", got)
+
+	bus.Publish(ctx, "resume-analysis-cancel", Message{Key: "session.1"})
+	expectNothing(t, got)
+}
+
+func TestMemoryUnsubscribeOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	bus := NewMemory()
+	got := make(chan Message, 1)
+	done := make(chan struct{})
+	go func() {
+		bus.Subscribe(ctx, "session_updates", "recorder", func(ctx context.Context, msg Message) error {
+			got <- msg
+			return nil
+		})
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	<-done
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if groups := bus.topics["session_updates"].groups; len(groups) != 0 {
+		t.Errorf("group still registered after its only subscriber stopped: %v", groups)
+	}
+}
+
+func TestNewRejectsUnknownDriver(t *testing.T) {
+	if _, err := New(Config{Driver: "kafka"}); err == nil {
+		t.Error("expected an error for an unknown driver")
+	}
+	if _, err := New(Config{Driver: "rabbitmq"}); err == nil {
+		t.Error("expected an error for rabbitmq without a connection")
+	}
+	bus, err := New(Config{Driver: "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bus.(*Memory); !ok {
+		t.Errorf("memory driver built %T", bus)
+	}
+}
@@ -0,0 +1,122 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+)
+
+const (
+	// postgres rejects NOTIFY payloads from 8000 bytes
+	maxNotifyPayload = 7999
+	// claims only have to outlive the notification, which every listener gets at once
+	claimRetention = time.Hour
+	listenerPing   = 90 * time.Second
+)
+
+// Postgres sends messages with NOTIFY on a channel named after the topic. There's no broker in between,
+// messages published while nobody listens are lost, and payloads have to stay under 8000 bytes.
+type Postgres struct {
+	db    *database.Queries
+	dbURL string
+}
+
+// notification is the NOTIFY payload.
+type notification struct {
+	ID   uuid.UUID `json:"id"`
+	Key  string    `json:"key"`
+	Data []byte    `json:"data"`
+}
+
+func NewPostgres(db *database.Queries, dbURL string) *Postgres {
+	return &Postgres{db: db, dbURL: dbURL}
+}
+
+func (b *Postgres) Publish(ctx context.Context, topic string, msg Message) error {
+	payload, err := json.Marshal(notification{ID: uuid.New(), Key: msg.Key, Data: msg.Data})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		return fmt.Errorf("%s message of %d bytes is too large for the postgres event bus", topic, len(payload))
+	}
+	return b.db.PublishNotification(ctx, database.PublishNotificationParams{
+		Channel: topic,
+		Payload: string(payload),
+	})
+}
+
+// Subscribe listens on its own connection. Subscribers in a group claim each message in event_bus_claims,
+// whoever inserts the claim first handles it.
+func (b *Postgres) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	listener := pq.NewListener(b.dbURL, time.Second, 30*time.Second, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("postgres listener of %s %q. err: %v", topic, group, err)
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(topic); err != nil {
+		return fmt.Errorf("error listening on %s. err: %v", topic, err)
+	}
+
+	ping := time.NewTicker(listenerPing)
+	defer ping.Stop()
+	cleanup := time.NewTicker(claimRetention)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ping.C:
+			// the listener only notices a dead connection when it uses it
+			go listener.Ping()
+		case <-cleanup.C:
+			if group != "" {
+				if err := b.db.DeleteEventBusClaimsBefore(ctx, time.Now().UTC().Add(-claimRetention)); err != nil {
+					log.Printf("error deleting old event bus claims. err: %v", err)
+				}
+			}
+		case n := <-listener.Notify:
+			// nil after a reconnect, notifications sent in between are lost
+			if n == nil {
+				continue
+			}
+			b.handle(ctx, topic, group, n.Extra, handler)
+		}
+	}
+}
+
+func (b *Postgres) handle(ctx context.Context, topic, group, payload string, handler Handler) {
+	msg := notification{}
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		log.Printf("dropping malformed %s notification. err: %v", topic, err)
+		return
+	}
+	if group != "" {
+		claimed, err := b.db.ClaimEventBusMessage(ctx, database.ClaimEventBusMessageParams{
+			MessageID:     msg.ID,
+			ConsumerGroup: group,
+		})
+		if err != nil {
+			log.Printf("error claiming %s message %s. err: %v", topic, msg.ID, err)
+			return
+		}
+		if claimed == 0 {
+			return
+		}
+	}
+	if err := handler(ctx, Message{Key: msg.Key, Data: msg.Data}); err != nil {
+		log.Printf("error handling %s message %q. err: %v", topic, msg.Key, err)
+	}
+}
+
+// Close leaves the database to its owner, the listeners close with their subscriptions.
+func (b *Postgres) Close() error {
+	return nil
+}
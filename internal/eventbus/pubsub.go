@@ -0,0 +1,118 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// broadcastSubscriptionTTL is how long pub/sub keeps the subscription of an instance that died without
+// deleting it.
+const broadcastSubscriptionTTL = 24 * time.Hour
+
+// PubSub publishes to the google pub/sub topic of the same name, the message key goes in the key attribute.
+type PubSub struct {
+	client func() (*pubsub.Client, error)
+
+	mu         sync.Mutex
+	publishers map[string]*pubsub.Publisher
+}
+
+func NewPubSub(client func() (*pubsub.Client, error)) *PubSub {
+	return &PubSub{client: client, publishers: map[string]*pubsub.Publisher{}}
+}
+
+func (b *PubSub) publisher(topic string) (*pubsub.Publisher, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.publishers[topic]; ok {
+		return p, nil
+	}
+	client, err := b.client()
+	if err != nil {
+		return nil, err
+	}
+	p := client.Publisher(topic)
+	b.publishers[topic] = p
+	return p, nil
+}
+
+func (b *PubSub) Publish(ctx context.Context, topic string, msg Message) error {
+	p, err := b.publisher(topic)
+	if err != nil {
+		return err
+	}
+	var attributes map[string]string
+	if msg.Key != "" {
+		attributes = map[string]string{"key": msg.Key}
+	}
+	_, err = p.Publish(ctx, &pubsub.Message{Data: msg.Data, Attributes: attributes}).Get(ctx)
+	return err
+}
+
+// Subscribe receives from the subscription named after the group, which has to exist already. Without a
+// group the instance creates a subscription of its own and deletes it when ctx is done.
+func (b *PubSub) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	keepConsuming(ctx, fmt.Sprintf("pubsub subscriber of %s %q", topic, group), func() error {
+		client, err := b.client()
+		if err != nil {
+			return errNotConnected
+		}
+		subscription := group
+		if subscription == "" {
+			subscription, err = b.createBroadcastSubscription(ctx, client, topic)
+			if err != nil {
+				return err
+			}
+			defer b.deleteSubscription(client, subscription)
+		}
+		return client.Subscriber(subscription).Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+			if err := handler(ctx, Message{Key: m.Attributes["key"], Data: m.Data}); err != nil {
+				log.Printf("error handling %s message %s. err: %v", topic, m.ID, err)
+			}
+			m.Ack()
+		})
+	})
+	return nil
+}
+
+func (b *PubSub) createBroadcastSubscription(ctx context.Context, client *pubsub.Client, topic string) (string, error) {
+	name := fmt.Sprintf("projects/%s/subscriptions/%s-%s", client.Project(), topic, uuid.NewString())
+	_, err := client.SubscriptionAdminClient.CreateSubscription(ctx, &pubsubpb.Subscription{
+		Name:             name,
+		Topic:            fmt.Sprintf("projects/%s/topics/%s", client.Project(), topic),
+		ExpirationPolicy: &pubsubpb.ExpirationPolicy{Ttl: durationpb.New(broadcastSubscriptionTTL)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error creating subscription to %s. err: %v", topic, err)
+	}
+	return name, nil
+}
+
+func (b *PubSub) deleteSubscription(client *pubsub.Client, name string) {
+	// ctx is done by now
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := client.SubscriptionAdminClient.DeleteSubscription(ctx, &pubsubpb.DeleteSubscriptionRequest{Subscription: name})
+	if err != nil {
+		log.Printf("error deleting subscription %s. err: %v", name, err)
+	}
+}
+
+// Close flushes the pending messages, the client is left to its owner.
+func (b *PubSub) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for topic, p := range b.publishers {
+		p.Stop()
+		delete(b.publishers, topic)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/streadway/amqp"
+)
+
+// RabbitMQ publishes every topic to a topic exchange of the same name, with the message key as routing key.
+type RabbitMQ struct {
+	conn func() *amqp.Connection
+}
+
+func NewRabbitMQ(conn func() *amqp.Connection) *RabbitMQ {
+	return &RabbitMQ{conn: conn}
+}
+
+func (b *RabbitMQ) connected() bool {
+	conn := b.conn()
+	return conn != nil && !conn.IsClosed()
+}
+
+func (b *RabbitMQ) channel() (*amqp.Channel, error) {
+	if !b.connected() {
+		return nil, errNotConnected
+	}
+	return b.conn().Channel()
+}
+
+func (b *RabbitMQ) Publish(ctx context.Context, topic string, msg Message) error {
+	ch, err := b.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	err = ch.ExchangeDeclare(topic, "topic", true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	return ch.Publish(topic, msg.Key, false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        msg.Data,
+	})
+}
+
+// Subscribe consumes from a durable queue named after the group, or an exclusive queue of the instance's
+// own without one. It waits for rabbitmq and starts again when the connection drops.
+func (b *RabbitMQ) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	keepConsuming(ctx, fmt.Sprintf("rabbitmq consumer of %s %q", topic, group), func() error {
+		if !b.connected() {
+			return errNotConnected
+		}
+		return b.consume(ctx, topic, group, handler)
+	})
+	return nil
+}
+
+func (b *RabbitMQ) consume(ctx context.Context, topic, group string, handler Handler) error {
+	ch, err := b.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	err = ch.ExchangeDeclare(topic, "topic", true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	shared := group != ""
+	// durable, delete when unused, exclusive
+	q, err := ch.QueueDeclare(group, shared, !shared, !shared, false, nil)
+	if err != nil {
+		return err
+	}
+	err = ch.QueueBind(q.Name, "#", topic, false, nil)
+	if err != nil {
+		return err
+	}
+	msgs, err := ch.Consume(q.Name, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-msgs:
+			if !ok {
+				return fmt.Errorf("rabbitmq channel closed")
+			}
+			if err := handler(ctx, Message{Key: d.RoutingKey, Data: d.Body}); err != nil {
+				log.Printf("error handling %s message %q. err: %v", topic, d.RoutingKey, err)
+				d.Nack(false, false)
+				continue
+			}
+			d.Ack(false)
+		}
+	}
+}
+
+// Close leaves the connection to its owner.
+func (b *RabbitMQ) Close() error {
+	return nil
+}
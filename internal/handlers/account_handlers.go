@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ExportAccountHandler streams a zip with everything we hold about the user: json files for the
+// account, profile, sessions, resumes, analysis results, subscription and contact messages, and the
+// uploaded resume files from R2.
+func (cfg *Config) ExportAccountHandler(w http.ResponseWriter, r *http.Request, user User) {
+	ctx := r.Context()
+	dbUser, err := cfg.DB.GetUser(ctx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	profile, err := cfg.getUserProfile(ctx, user)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting profile. err: %v", err))
+		return
+	}
+	organizations, err := cfg.DB.GetUserOrganizations(ctx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting organizations. err: %v", err))
+		return
+	}
+	sessions, err := cfg.DB.GetUserSessions(ctx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting sessions. err: %v", err))
+		return
+	}
+	resumes := []ResumeMetadata{}
+	results := []AnalysesResults{}
+	for _, session := range sessions {
+		sessionResumes, err := cfg.DB.GetResumesBySession(ctx, session.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting resumes. err: %v", err))
+			return
+		}
+		for _, resume := range sessionResumes {
+			resumes = append(resumes, DbResumeToModelResumeMetadata(resume))
+		}
+		result, err := cfg.DB.GetAnalysesResultsBySession(ctx, session.ID)
+		if err == nil {
+			results = append(results, DbAnalysesResultToModelAnalysesResults(result))
+		} else if err != sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting analysis results. err: %v", err))
+			return
+		}
+	}
+	var subscription *Subscription
+	sub, err := cfg.DB.GetSubscriptionWithUserID(ctx, user.ID)
+	if err == nil {
+		modelSub := DbSubscriptionToModelSubscription(sub)
+		subscription = &modelSub
+	} else if err != sql.ErrNoRows {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting subscription. err: %v", err))
+		return
+	}
+	subscriptionHistory, err := cfg.DB.GetUserSubscriptionHistory(ctx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting subscription history. err: %v", err))
+		return
+	}
+	contactMessages, err := cfg.DB.GetContactMessagesByEmail(ctx, dbUser.Email)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting contact messages. err: %v", err))
+		return
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"user.json", DbUserToModelUser(dbUser)},
+		{"profile.json", profile},
+		{"organizations.json", DbUserOrganizationsToModelOrganizations(organizations)},
+		{"sessions.json", DbSessionsToModelSessions(sessions)},
+		{"resumes.json", resumes},
+		{"analysis_results.json", results},
+		{"subscription.json", subscription},
+		{"subscription_history.json", DbSubscriptionHistoryToModelSubscriptionHistoryEntries(subscriptionHistory)},
+		{"contact_messages.json", DbContactMessagesToModelContactMessages(contactMessages)},
+	}
+
+	// from here on the response has started, failures are listed in export_errors.json instead
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="jobmatch-export-%s.zip"`, time.Now().UTC().Format("20060102")))
+	w.WriteHeader(http.StatusOK)
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			log.Printf("error writing %s to export for user %s. err: %v", file.name, user.ID, err)
+			return
+		}
+		encoder := json.NewEncoder(fw)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			log.Printf("error writing %s to export for user %s. err: %v", file.name, user.ID, err)
+			return
+		}
+	}
+
+	exportErrors := []string{}
+	usedNames := map[string]bool{}
+	for _, resume := range resumes {
+		name := fmt.Sprintf("resumes/%s/%s", resume.SessionID, path.Base(strings.ReplaceAll(resume.OriginalFilename, "\\", "/")))
+		if usedNames[name] {
+			name = fmt.Sprintf("resumes/%s/%s-%s", resume.SessionID, resume.ID, path.Base(strings.ReplaceAll(resume.OriginalFilename, "\\", "/")))
+		}
+		usedNames[name] = true
+		// keys registered before UploadCompleteHandler checked them may point outside the session
+		if !isSessionObjectKey(resume.SessionID.String(), resume.ObjectKey) {
+			log.Printf("not exporting %s for user %s, it isn't under its session", resume.ObjectKey, user.ID)
+			exportErrors = append(exportErrors, fmt.Sprintf("%s: could not be exported", resume.OriginalFilename))
+			continue
+		}
+		err := cfg.addR2ObjectToZip(r, zw, name, resume.ObjectKey)
+		if err != nil {
+			log.Printf("error exporting %s for user %s. err: %v", resume.ObjectKey, user.ID, err)
+			exportErrors = append(exportErrors, fmt.Sprintf("%s: could not be exported", resume.OriginalFilename))
+		}
+	}
+	if len(exportErrors) > 0 {
+		fw, err := zw.Create("export_errors.json")
+		if err == nil {
+			json.NewEncoder(fw).Encode(exportErrors)
+		}
+	}
+}
+
+func (cfg *Config) addR2ObjectToZip(r *http.Request, zw *zip.Writer, name, key string) error {
+	body, err := cfg.openR2Object(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, body)
+	return err
+}
+
+// DeleteAccountHandler permanently deletes the user. It needs the password, or the account email for
+// accounts that only use social login, and a 2fa code when 2fa is on.
+// Paystack is told to stop charging first, then the R2 uploads of every session are removed, then the rows.
+// Sessions the user created in organizations other people still use are handed to the organization.
+func (cfg *Config) DeleteAccountHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Password string `json:"password"`
+		Email    string `json:"email"`
+		Code     string `json:"code"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	ctx := r.Context()
+	dbUser, err := cfg.DB.GetUser(ctx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	if dbUser.Password != "" {
+		if bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(body.Password)) != nil {
+			helpers.RespondWithError(w, http.StatusUnauthorized, "Wrong password.")
+			return
+		}
+	} else if !strings.EqualFold(strings.TrimSpace(body.Email), dbUser.Email) {
+		helpers.RespondWithError(w, http.StatusBadRequest, "enter your account email to confirm")
+		return
+	}
+	if dbUser.TotpEnabledAt.Valid {
+		ok, err := cfg.verifySecondFactor(ctx, dbUser, body.Code)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !ok {
+			helpers.RespondWithError(w, http.StatusUnauthorized, "invalid code")
+			return
+		}
+	}
+
+	// an organization can't be left without an owner while other people still use it
+	organizations, err := cfg.DB.GetUserOrganizations(ctx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting organizations. err: %v", err))
+		return
+	}
+	for _, org := range organizations {
+		if org.MemberRole != orgRoleOwner {
+			continue
+		}
+		owners, err := cfg.DB.CountOrganizationOwners(ctx, org.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error counting owners. err: %v", err))
+			return
+		}
+		members, err := cfg.DB.CountOrganizationMembers(ctx, org.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error counting members. err: %v", err))
+			return
+		}
+		if owners == 1 && members > 1 {
+			helpers.RespondWithError(w, http.StatusConflict, fmt.Sprintf("make someone else an owner of %s before deleting your account", org.Name))
+			return
+		}
+	}
+
+	sub, err := cfg.DB.GetSubscriptionWithUserID(ctx, user.ID)
+	if err == nil {
+		if sub.Status != "cancelled" {
+			err = cfg.cancelPaystackSubscription(ctx, sub)
+			if err != nil {
+				log.Printf("error cancelling subscription of user %s. err: %v", user.ID, err)
+				helpers.RespondWithError(w, http.StatusBadGateway, "error cancelling your subscription, try again later")
+				return
+			}
+		}
+	} else if err != sql.ErrNoRows {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting subscription. err: %v", err))
+		return
+	}
+
+	// the sessions of these organizations fall back to the user and are deleted with the account
+	err = cfg.DB.DeleteSoleMemberOrganizations(ctx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting organizations. err: %v", err))
+		return
+	}
+
+	// every step above and this one can safely run again if the request is retried
+	err = cfg.deleteUserSessionUploads(ctx, user.ID)
+	if err != nil {
+		log.Println(err)
+		helpers.RespondWithError(w, http.StatusInternalServerError, "error deleting your uploaded files, try again later")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	err = q.DeleteContactMessagesByEmail(ctx, dbUser.Email)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting contact messages. err: %v", err))
+		return
+	}
+	_, err = q.ClearLoginThrottle(ctx, database.ClearLoginThrottleParams{Scope: emailLoginPolicy.scope, Key: dbUser.Email})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting login attempts. err: %v", err))
+		return
+	}
+	// everything else goes through the foreign keys, organization sessions the user created stay without a creator
+	err = q.DeleteUser(ctx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting user. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
+		return
+	}
+
+	// access tokens still in flight stop working because AuthMiddleware can't find the user anymore
+	auth.ClearRefreshTokenCookie(w)
+	log.Printf("user %s deleted their account\n", user.ID)
+	helpers.RespondWithJson(w, http.StatusOK, "account deleted")
+}
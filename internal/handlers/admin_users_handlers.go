@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+)
+
+const (
+	adminUsersDefaultPageSize = 20
+	adminUsersMaxPageSize     = 100
+)
+
+// GetAdminUsersHandler lists users newest first.
+// Query params: search (email or name), role, plan, created_from, created_to (YYYY-MM-DD or RFC3339),
+// suspended (true/false), page and page_size.
+func (cfg *Config) GetAdminUsersHandler(w http.ResponseWriter, r *http.Request, user User) {
+	query := r.URL.Query()
+	params := database.AdminListUsersParams{
+		Search: nullStringParam(query.Get("search")),
+		Role:   nullStringParam(query.Get("role")),
+		Plan:   nullStringParam(query.Get("plan")),
+	}
+	var err error
+	params.CreatedFrom, err = nullTimeParam(query.Get("created_from"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid created_from, use YYYY-MM-DD or RFC3339")
+		return
+	}
+	params.CreatedTo, err = nullTimeEndParam(query.Get("created_to"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid created_to, use YYYY-MM-DD or RFC3339")
+		return
+	}
+	if suspended := query.Get("suspended"); suspended != "" {
+		value, err := strconv.ParseBool(suspended)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, "suspended must be true or false")
+			return
+		}
+		params.Suspended = sql.NullBool{Valid: true, Bool: value}
+	}
+	page := 1
+	if p := query.Get("page"); p != "" {
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			helpers.RespondWithError(w, http.StatusBadRequest, "page must be a positive number")
+			return
+		}
+	}
+	pageSize := adminUsersDefaultPageSize
+	if ps := query.Get("page_size"); ps != "" {
+		pageSize, err = strconv.Atoi(ps)
+		if err != nil || pageSize < 1 || pageSize > adminUsersMaxPageSize {
+			helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("page_size must be between 1 and %d", adminUsersMaxPageSize))
+			return
+		}
+	}
+	params.PageSize = int32(pageSize)
+	params.PageOffset = int32((page - 1) * pageSize)
+
+	rows, err := cfg.DB.AdminListUsers(r.Context(), params)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting users. err: %v", err))
+		return
+	}
+	total, err := cfg.DB.AdminCountUsers(r.Context(), database.AdminCountUsersParams{
+		Search:      params.Search,
+		Role:        params.Role,
+		Plan:        params.Plan,
+		CreatedFrom: params.CreatedFrom,
+		CreatedTo:   params.CreatedTo,
+		Suspended:   params.Suspended,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error counting users. err: %v", err))
+		return
+	}
+	response := struct {
+		Users    []AdminUser `json:"users"`
+		Page     int         `json:"page"`
+		PageSize int         `json:"page_size"`
+		Total    int64       `json:"total"`
+	}{
+		Users:    DbAdminListUsersRowsToModelAdminUsers(rows),
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}
+	helpers.RespondWithJson(w, http.StatusOK, response)
+}
+
+func (cfg *Config) GetAdminUserHandler(w http.ResponseWriter, r *http.Request, user User) {
+	dbUser, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	detail := AdminUserDetail{}
+	planName, subscriptionStatus := "free", "free"
+
+	employerProfile, err := cfg.DB.GetEmployerProfileByUserID(r.Context(), dbUser.ID)
+	if err == nil {
+		profile := DbEmployerProfileToModelEmployerProfile(employerProfile)
+		detail.EmployerProfile = &profile
+	} else if err != sql.ErrNoRows {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting employer profile. err: %v", err))
+		return
+	}
+	jobSeekerProfile, err := cfg.DB.GetJobSeekerProfileByUserID(r.Context(), dbUser.ID)
+	if err == nil {
+		profile := DbJobSeekerProfileToModelJobSeekerProfile(jobSeekerProfile)
+		detail.JobSeekerProfile = &profile
+	} else if err != sql.ErrNoRows {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting job seeker profile. err: %v", err))
+		return
+	}
+	sub, err := cfg.DB.GetSubscriptionWithUserID(r.Context(), dbUser.ID)
+	if err == nil {
+		subscription := DbSubscriptionToModelSubscription(sub)
+		detail.Subscription = &subscription
+		subscriptionStatus = sub.Status
+		dbPlan, err := cfg.DB.GetPlan(r.Context(), sub.PlanID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting plan. err: %v", err))
+			return
+		}
+		plan := DbPlanToModelPlan(dbPlan)
+		detail.Plan = &plan
+		planName = plan.Name
+	} else if err != sql.ErrNoRows {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting subscription. err: %v", err))
+		return
+	}
+	usage, err := cfg.DB.GetUserUsage(r.Context(), dbUser.ID)
+	if err == nil {
+		detail.Usage = &UserUsage{
+			Count:      usage.Count,
+			MaxDaily:   usage.MaxDaily,
+			LastUsedAt: usage.LastUsedAt,
+		}
+	} else if err != sql.ErrNoRows {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting usage. err: %v", err))
+		return
+	}
+	detail.SessionsCount, err = cfg.DB.CountUserSessions(r.Context(), dbUser.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error counting sessions. err: %v", err))
+		return
+	}
+	detail.User = DbUserToModelAdminUser(dbUser, planName, subscriptionStatus)
+	helpers.RespondWithJson(w, http.StatusOK, detail)
+}
+
+func (cfg *Config) UpdateAdminUserRoleHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Role string `json:"role"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if body.Role != "employer" && body.Role != "job_seeker" && body.Role != "admin" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "role must be one of (employer, job_seeker, admin)")
+		return
+	}
+	dbUser, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	// an admin demoting themselves could leave nobody to undo it
+	if dbUser.ID == user.ID {
+		helpers.RespondWithError(w, http.StatusBadRequest, "you can't change your own role")
+		return
+	}
+	updated, err := cfg.DB.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{
+		Role: body.Role,
+		ID:   dbUser.ID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating role. err: %v", err))
+		return
+	}
+	cfg.recordSecurityEvent(r, dbUser.ID, "role_changed", fmt.Sprintf("role changed from %s to %s by %s", dbUser.Role, body.Role, user.Email))
+	helpers.RespondWithJson(w, http.StatusOK, DbUserToModelUser(updated))
+}
+
+// SuspendUserHandler blocks a user right away, every session and access token stops working.
+func (cfg *Config) SuspendUserHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Reason string `json:"reason"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	dbUser, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	if dbUser.ID == user.ID {
+		helpers.RespondWithError(w, http.StatusBadRequest, "you can't suspend yourself")
+		return
+	}
+	if dbUser.SuspendedAt.Valid {
+		helpers.RespondWithError(w, http.StatusBadRequest, "user is already suspended")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	err = qtx.SuspendUser(r.Context(), database.SuspendUserParams{
+		SuspendedReason: nullStringParam(body.Reason),
+		ID:              dbUser.ID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error suspending user. err: %v", err))
+		return
+	}
+	err = qtx.DeleteUserRefreshTokens(r.Context(), dbUser.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking refresh tokens. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing suspension. err: %v", err))
+		return
+	}
+	// AuthMiddleware rejects suspended users anyway, this just saves the lookups
+	err = cfg.RevocationStore.RevokeUserTokens(r.Context(), dbUser.ID, time.Now())
+	if err != nil {
+		log.Println("error revoking access tokens after suspension. err: ", err)
+	}
+	cfg.recordSecurityEvent(r, dbUser.ID, "account_suspended", fmt.Sprintf("suspended by %s", user.Email))
+	helpers.RespondWithJson(w, http.StatusOK, "user suspended")
+}
+
+func (cfg *Config) UnsuspendUserHandler(w http.ResponseWriter, r *http.Request, user User) {
+	dbUser, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	if !dbUser.SuspendedAt.Valid {
+		helpers.RespondWithError(w, http.StatusBadRequest, "user is not suspended")
+		return
+	}
+	err := cfg.DB.UnsuspendUser(r.Context(), dbUser.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error unsuspending user. err: %v", err))
+		return
+	}
+	cfg.recordSecurityEvent(r, dbUser.ID, "account_unsuspended", fmt.Sprintf("unsuspended by %s", user.Email))
+	helpers.RespondWithJson(w, http.StatusOK, "user unsuspended")
+}
+
+// DeleteAdminUserHandler removes the user and, through the foreign keys, everything they own.
+func (cfg *Config) DeleteAdminUserHandler(w http.ResponseWriter, r *http.Request, user User) {
+	dbUser, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	if dbUser.ID == user.ID {
+		helpers.RespondWithError(w, http.StatusBadRequest, "you can't delete yourself")
+		return
+	}
+	// paystack would keep charging a user we no longer have
+	sub, err := cfg.DB.GetSubscriptionWithUserID(r.Context(), dbUser.ID)
+	if err == nil && sub.Status == "active" {
+		helpers.RespondWithError(w, http.StatusConflict, "user has an active subscription, cancel it first")
+		return
+	}
+	if err != nil && err != sql.ErrNoRows {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting subscription. err: %v", err))
+		return
+	}
+	// same clean up as DeleteAccountHandler, the sessions of organizations only they were in fall back to them
+	err = cfg.DB.DeleteSoleMemberOrganizations(r.Context(), dbUser.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting organizations. err: %v", err))
+		return
+	}
+	err = cfg.deleteUserSessionUploads(r.Context(), dbUser.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = cfg.DB.DeleteUser(r.Context(), dbUser.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting user. err: %v", err))
+		return
+	}
+	log.Printf("user %s (%s) deleted by admin %s\n", dbUser.ID, dbUser.Email, user.Email)
+	helpers.RespondWithJson(w, http.StatusOK, "user deleted")
+}
+
+// adminTargetUser loads the user in the {id} url param, responding itself when it can't.
+func (cfg *Config) adminTargetUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid user id")
+		return database.User{}, false
+	}
+	dbUser, err := cfg.DB.GetUser(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusNotFound, "user not found")
+			return database.User{}, false
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return database.User{}, false
+	}
+	return dbUser, true
+}
+
+func nullStringParam(value string) sql.NullString {
+	value = strings.TrimSpace(value)
+	return sql.NullString{Valid: value != "", String: value}
+}
+
+// nullTimeEndParam is nullTimeParam for the exclusive upper bound of a range. A date means up to the end
+// of that day, so created_to=2026-10-01 includes what was created on the 1st.
+func nullTimeEndParam(value string) (sql.NullTime, error) {
+	t, err := time.Parse("2006-01-02", value)
+	if err == nil {
+		return sql.NullTime{Valid: true, Time: t.AddDate(0, 0, 1).UTC()}, nil
+	}
+	return nullTimeParam(value)
+}
+
+func nullTimeParam(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return sql.NullTime{}, err
+		}
+	}
+	return sql.NullTime{Valid: true, Time: t.UTC()}, nil
+}
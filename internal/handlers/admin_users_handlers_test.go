@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNullTimeParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		end     bool
+		want    time.Time
+		wantSet bool
+		wantErr bool
+	}{
+		{"empty", "", false, time.Time{}, false, false},
+		{"empty end", "", true, time.Time{}, false, false},
+		{"date", "2026-10-01", false, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), true, false},
+		{"date end includes the day", "2026-10-01", true, time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), true, false},
+		{"time", "2026-10-01T12:30:00Z", false, time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC), true, false},
+		{"time end is exact", "2026-10-01T12:30:00+01:00", true, time.Date(2026, 10, 1, 11, 30, 0, 0, time.UTC), true, false},
+		{"invalid", "yesterday", false, time.Time{}, false, true},
+		{"invalid end", "01/10/2026", true, time.Time{}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parse := nullTimeParam
+			if tt.end {
+				parse = nullTimeEndParam
+			}
+			got, err := parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Valid != tt.wantSet || !got.Time.Equal(tt.want) {
+				t.Errorf("got %v (valid %v), want %v (valid %v)", got.Time, got.Valid, tt.want, tt.wantSet)
+			}
+		})
+	}
+}
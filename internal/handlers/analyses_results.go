@@ -5,22 +5,12 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
 	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
 )
 
-func (cfg *Config) GetResultHandler(w http.ResponseWriter, r *http.Request) {
-	session_id := chi.URLParam(r, "sessionID")
-	sessionId, err := uuid.Parse(session_id)
-	if err != nil {
-		msg := fmt.Sprintf("error parsing uuid from param. err: %v", err)
-		log.Println(msg)
-		helpers.RespondWithError(w, http.StatusInternalServerError, msg)
-		return
-	}
-
-	result, err := cfg.DB.GetAnalysesResultsBySession(r.Context(), sessionId)
+func (cfg *Config) GetResultHandler(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	result, err := cfg.DB.GetAnalysesResultsBySession(r.Context(), session.ID)
 	if err != nil {
 		msg := fmt.Sprintf("error getting result for session. err: %v", err)
 		log.Println(msg)
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+)
+
+const (
+	apiClientKeyPrefix = "jmk_"
+	// how long the old key keeps working after a rotation when the admin doesn't say
+	apiClientDefaultRotationOverlap = 24 * time.Hour
+	apiClientMaxRotationOverlap     = 30 * 24 * time.Hour
+	// the name requests authenticated with the CLIENT_API_KEY env value are counted under
+	legacyAPIClientName = "legacy"
+	// how long a resolved key is trusted before it's looked up again, revoking or rotating a client
+	// takes this long to reach the other instances
+	apiClientCacheTTL        = 30 * time.Second
+	apiClientCacheMaxEntries = 10000
+	// TouchAPIClient only writes once a minute anyway
+	apiClientTouchInterval = time.Minute
+)
+
+type apiClientContextKey struct{}
+
+var (
+	// requests per api client name, served with the other expvars at /api/admin/metrics
+	apiClientRequests = expvar.NewMap("api_client_requests")
+	// rejected requests by reason (missing, invalid, origin)
+	apiClientRejections = expvar.NewMap("api_client_rejections")
+)
+
+// APIClientFromContext returns the client resolved by ClientAuth. ok is false on routes ClientAuth bypasses.
+func APIClientFromContext(ctx context.Context) (APIClient, bool) {
+	client, ok := ctx.Value(apiClientContextKey{}).(APIClient)
+	return client, ok
+}
+
+// resolveAPIClient finds the active client for a client-api-key. The key from the last rotation is accepted
+// until its overlap ends. The CLIENT_API_KEY env value keeps working as the legacy client so existing
+// frontends don't break while they move to their own keys. Lookups are cached for apiClientCacheTTL.
+func (cfg *Config) resolveAPIClient(ctx context.Context, key string) (APIClient, error) {
+	if cfg.ClientApiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(cfg.ClientApiKey)) == 1 {
+		return APIClient{Name: legacyAPIClientName, Status: "active", AllowedOrigins: []string{}}, nil
+	}
+	keyHash := auth.HashToken(key)
+	now := time.Now()
+	entry, ok := apiClients.get(keyHash, now)
+	if !ok {
+		dbClient, err := cfg.DB.GetActiveAPIClientByKeyHash(ctx, keyHash)
+		if err != nil && err != sql.ErrNoRows {
+			return APIClient{}, err
+		}
+		entry = newAPIClientCacheEntry(keyHash, dbClient, err == nil, now)
+		apiClients.put(keyHash, entry)
+	}
+	if !entry.found {
+		return APIClient{}, sql.ErrNoRows
+	}
+	if apiClients.shouldTouch(entry.client.ID, now) {
+		err := cfg.DB.TouchAPIClient(ctx, entry.client.ID)
+		if err != nil {
+			log.Printf("error updating api client %s last used time. err: %v", entry.client.Name, err)
+		}
+	}
+	return DbAPIClientToModelAPIClient(entry.client), nil
+}
+
+// apiClientCache keeps resolved keys, unknown ones included, so ClientAuth doesn't read the db on
+// every request, and when each client's last_used_at was last written from this instance.
+type apiClientCache struct {
+	mu      sync.Mutex
+	entries map[string]apiClientCacheEntry // by key hash
+	touched map[uuid.UUID]time.Time
+}
+
+type apiClientCacheEntry struct {
+	client    database.ApiClient
+	found     bool
+	expiresAt time.Time
+}
+
+var apiClients = &apiClientCache{entries: map[string]apiClientCacheEntry{}, touched: map[uuid.UUID]time.Time{}}
+
+// newAPIClientCacheEntry caches a previous key no longer than its overlap, it must stop working on time.
+func newAPIClientCacheEntry(keyHash string, client database.ApiClient, found bool, now time.Time) apiClientCacheEntry {
+	expiresAt := now.Add(apiClientCacheTTL)
+	if found && client.KeyHash != keyHash && client.PreviousKeyExpiresAt.Valid && client.PreviousKeyExpiresAt.Time.Before(expiresAt) {
+		expiresAt = client.PreviousKeyExpiresAt.Time
+	}
+	return apiClientCacheEntry{client: client, found: found, expiresAt: expiresAt}
+}
+
+func (c *apiClientCache) get(keyHash string, now time.Time) (apiClientCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[keyHash]
+	if !ok || !now.Before(entry.expiresAt) {
+		return apiClientCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *apiClientCache) put(keyHash string, entry apiClientCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// random invalid keys shouldn't grow the cache forever
+	if len(c.entries) >= apiClientCacheMaxEntries {
+		clear(c.entries)
+	}
+	c.entries[keyHash] = entry
+}
+
+// invalidate drops the client's keys after it changed here, other instances pick the change up
+// when their entries expire.
+func (c *apiClientCache) invalidate(clientID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for keyHash, entry := range c.entries {
+		if entry.found && entry.client.ID == clientID {
+			delete(c.entries, keyHash)
+		}
+	}
+}
+
+// shouldTouch reports whether last_used_at is due for a write and records that it's being written.
+func (c *apiClientCache) shouldTouch(clientID uuid.UUID, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.touched[clientID]; ok && now.Sub(last) < apiClientTouchInterval {
+		return false
+	}
+	c.touched[clientID] = now
+	return true
+}
+
+// originAllowed reports whether a browser origin may use the client. Requests without an Origin
+// header are server to server calls and aren't restricted.
+func (client APIClient) originAllowed(origin string) bool {
+	if origin == "" || len(client.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range client.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateAPIClientKey() (key, prefix string, err error) {
+	token, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	key = apiClientKeyPrefix + token
+	return key, key[:len(apiClientKeyPrefix)+6], nil
+}
+
+func cleanOrigins(origins []string) []string {
+	cleaned := []string{}
+	for _, origin := range origins {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			cleaned = append(cleaned, origin)
+		}
+	}
+	return cleaned
+}
+
+func (cfg *Config) GetAPIClientsHandler(w http.ResponseWriter, r *http.Request, user User) {
+	clients, err := cfg.DB.GetAPIClients(r.Context())
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting api clients. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbAPIClientsToModelAPIClients(clients))
+}
+
+// CreateAPIClientHandler responds with the new client and its key. The key can't be shown again.
+func (cfg *Config) CreateAPIClientHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Name           string   `json:"name"`
+		AllowedOrigins []string `json:"allowed_origins"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "api client name can't be empty")
+		return
+	}
+	if body.Name == legacyAPIClientName {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("api client name %q is reserved", legacyAPIClientName))
+		return
+	}
+	key, prefix, err := generateAPIClientKey()
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error generating api key. err: %v", err))
+		return
+	}
+	client, err := cfg.DB.CreateAPIClient(r.Context(), database.CreateAPIClientParams{
+		Name:           body.Name,
+		KeyHash:        auth.HashToken(key),
+		KeyPrefix:      prefix,
+		AllowedOrigins: cleanOrigins(body.AllowedOrigins),
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			helpers.RespondWithError(w, http.StatusConflict, "an api client with this name already exists")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating api client. err: %v", err))
+		return
+	}
+	cfg.recordSecurityEvent(r, user.ID, "api_client_created", client.Name)
+	helpers.RespondWithJson(w, http.StatusCreated, map[string]any{
+		"client":  DbAPIClientToModelAPIClient(client),
+		"api_key": key,
+	})
+}
+
+func (cfg *Config) UpdateAPIClientOriginsHandler(w http.ResponseWriter, r *http.Request, user User) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid api client id")
+		return
+	}
+	body := struct {
+		AllowedOrigins []string `json:"allowed_origins"`
+	}{}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	client, err := cfg.DB.UpdateAPIClientOrigins(r.Context(), database.UpdateAPIClientOriginsParams{
+		AllowedOrigins: cleanOrigins(body.AllowedOrigins),
+		ID:             clientID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusNotFound, "api client not found")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating api client. err: %v", err))
+		return
+	}
+	apiClients.invalidate(clientID)
+	helpers.RespondWithJson(w, http.StatusOK, DbAPIClientToModelAPIClient(client))
+}
+
+// RotateAPIClientHandler issues a new key. The current key keeps working for overlap_minutes
+// (default 24 hours) so the frontend can be redeployed without downtime. 0 ends it immediately.
+func (cfg *Config) RotateAPIClientHandler(w http.ResponseWriter, r *http.Request, user User) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid api client id")
+		return
+	}
+	body := struct {
+		OverlapMinutes *int `json:"overlap_minutes"`
+	}{}
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+			return
+		}
+	}
+	overlap := apiClientDefaultRotationOverlap
+	if body.OverlapMinutes != nil {
+		overlap = time.Duration(*body.OverlapMinutes) * time.Minute
+		if overlap < 0 || overlap > apiClientMaxRotationOverlap {
+			helpers.RespondWithError(w, http.StatusBadRequest, "overlap_minutes must be between 0 and 43200")
+			return
+		}
+	}
+	key, prefix, err := generateAPIClientKey()
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error generating api key. err: %v", err))
+		return
+	}
+	client, err := cfg.DB.RotateAPIClientKey(r.Context(), database.RotateAPIClientKeyParams{
+		PreviousKeyExpiresAt: sql.NullTime{Time: time.Now().Add(overlap), Valid: true},
+		KeyHash:              auth.HashToken(key),
+		KeyPrefix:            prefix,
+		ID:                   clientID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusNotFound, "active api client not found")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error rotating api key. err: %v", err))
+		return
+	}
+	apiClients.invalidate(clientID)
+	cfg.recordSecurityEvent(r, user.ID, "api_client_rotated", client.Name)
+	helpers.RespondWithJson(w, http.StatusOK, map[string]any{
+		"client":  DbAPIClientToModelAPIClient(client),
+		"api_key": key,
+	})
+}
+
+// RevokeAPIClientHandler stops the current and any previous key at once.
+func (cfg *Config) RevokeAPIClientHandler(w http.ResponseWriter, r *http.Request, user User) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid api client id")
+		return
+	}
+	rows, err := cfg.DB.RevokeAPIClient(r.Context(), clientID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking api client. err: %v", err))
+		return
+	}
+	if rows == 0 {
+		helpers.RespondWithError(w, http.StatusNotFound, "active api client not found")
+		return
+	}
+	apiClients.invalidate(clientID)
+	cfg.recordSecurityEvent(r, user.ID, "api_client_revoked", clientID.String())
+	helpers.RespondWithJson(w, http.StatusOK, "api client revoked")
+}
+
+// MetricsHandler serves the expvar metrics, including requests per api client.
+func (cfg *Config) MetricsHandler(w http.ResponseWriter, r *http.Request, user User) {
+	expvar.Handler().ServeHTTP(w, r)
+}
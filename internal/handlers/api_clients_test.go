@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+)
+
+func TestNewAPIClientCacheEntry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := database.ApiClient{
+		ID:                   uuid.New(),
+		KeyHash:              "current",
+		PreviousKeyHash:      sql.NullString{String: "previous", Valid: true},
+		PreviousKeyExpiresAt: sql.NullTime{Time: now.Add(5 * time.Second), Valid: true},
+	}
+	tests := []struct {
+		name    string
+		keyHash string
+		found   bool
+		want    time.Time
+	}{
+		{"current key", "current", true, now.Add(apiClientCacheTTL)},
+		{"previous key ends with its overlap", "previous", true, now.Add(5 * time.Second)},
+		{"unknown key", "unknown", false, now.Add(apiClientCacheTTL)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := newAPIClientCacheEntry(tt.keyHash, client, tt.found, now)
+			if !entry.expiresAt.Equal(tt.want) {
+				t.Errorf("expiresAt = %v, want %v", entry.expiresAt, tt.want)
+			}
+			if entry.found != tt.found {
+				t.Errorf("found = %v, want %v", entry.found, tt.found)
+			}
+		})
+	}
+}
+
+func TestAPIClientCache(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := &apiClientCache{entries: map[string]apiClientCacheEntry{}, touched: map[uuid.UUID]time.Time{}}
+	client := database.ApiClient{ID: uuid.New(), KeyHash: "current"}
+	cache.put("current", newAPIClientCacheEntry("current", client, true, now))
+
+	if _, ok := cache.get("current", now.Add(apiClientCacheTTL-time.Second)); !ok {
+		t.Error("entry missing before the ttl")
+	}
+	if _, ok := cache.get("current", now.Add(apiClientCacheTTL)); ok {
+		t.Error("entry returned after the ttl")
+	}
+
+	cache.invalidate(client.ID)
+	if _, ok := cache.get("current", now); ok {
+		t.Error("entry returned after invalidate")
+	}
+
+	if !cache.shouldTouch(client.ID, now) {
+		t.Error("first use not touched")
+	}
+	if cache.shouldTouch(client.ID, now.Add(apiClientTouchInterval-time.Second)) {
+		t.Error("touched again within the interval")
+	}
+	if !cache.shouldTouch(client.ID, now.Add(apiClientTouchInterval)) {
+		t.Error("not touched after the interval")
+	}
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
-	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -20,16 +22,9 @@ import (
 
 func (cfg *Config) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	body := struct {
-		Email           string `json:"email"`
-		Password        string `json:"password"`
-		Role            string `json:"role"`
-		FirstName       string `json:"first_name"`
-		LastName        string `json:"last_name"`
-		CompanyName     string `json:"company_name"`
-		CompanyWebsite  string `json:"company_website"`
-		CompanySize     int32  `json:"company_size"`
-		CompanyIndustry string `json:"company_industry"`
-		ProfessionID    string `json:"profession_id"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		signupDetails
 	}{}
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&body)
@@ -38,11 +33,6 @@ func (cfg *Config) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
 		return
 	}
-	// avoid admin sign up early
-	if body.Role == "admin" {
-		helpers.RespondWithError(w, http.StatusUnauthorized, "Unauthorized signup")
-		return
-	}
 	if body.Email == "" {
 		helpers.RespondWithError(w, http.StatusBadRequest, "Enter a mail")
 		return
@@ -62,64 +52,10 @@ func (cfg *Config) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		helpers.RespondWithError(w, http.StatusBadRequest, "User already exist. Login")
 		return
 	}
-	// Validate role and role in enum
-	if body.Role == "" {
-		helpers.RespondWithError(w, http.StatusBadRequest, "Enter the user role.")
+	if code, msg := cfg.validateSignupDetails(r.Context(), body.signupDetails); code != 0 {
+		helpers.RespondWithError(w, code, msg)
 		return
 	}
-	if body.Role != "employer" && body.Role != "job_seeker" {
-		helpers.RespondWithError(w, http.StatusBadRequest, "User  role must be one of (employer, job_seeker")
-		return
-	}
-	//  Validate payload base on role.
-	if body.Role == "employer" {
-		if body.CompanyName == "" {
-			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the employer company_name")
-			return
-		}
-		if body.CompanyIndustry == "" {
-			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the employer company_industry")
-			return
-		}
-		if body.CompanySize == 0 {
-			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the employer company_size")
-			return
-		}
-		if body.CompanyWebsite == "" {
-			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the employer company_website")
-			return
-		}
-
-	}
-	if body.Role == "job_seeker" {
-		if body.FirstName == "" {
-			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the user first name")
-			return
-		}
-		if body.LastName == "" {
-			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the user last name")
-			return
-		}
-		if body.ProfessionID == "" {
-			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the user profession_id")
-			return
-		}
-		professionIDUUID, err := uuid.Parse(body.ProfessionID)
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusBadRequest, "error parsing profession_id to uuid. err: "+err.Error())
-			return
-		}
-		professionExist, err := cfg.DB.ProfessionExists(r.Context(), professionIDUUID)
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusInternalServerError, "error validating profession_id. err: "+err.Error())
-			return
-		}
-		if !professionExist {
-			helpers.RespondWithError(w, http.StatusBadRequest, "no profession with this id exist")
-			return
-		}
-
-	}
 	// { create the user}
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), 10)
@@ -138,40 +74,15 @@ func (cfg *Config) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating user. err: %v", err))
 		return
 	}
-	//  create detail base on role
-	if user.Role == "employer" {
-		_, err = cfg.DB.CreateEmployer(r.Context(), database.CreateEmployerParams{
-			UserID:          user.ID,
-			CompanyName:     body.CompanyName,
-			CompanyWebsite:  body.CompanyWebsite,
-			CompanySize:     body.CompanySize,
-			CompanyIndustry: body.CompanyIndustry,
-		})
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating employer details, kindly update your details. err: %v", err))
-			return
-
-		}
-
+	err = cfg.createUserProfile(r.Context(), user, body.signupDetails)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
-	if user.Role == "job_seeker" {
-		_, err = cfg.DB.CreateJobSeeker(r.Context(), database.CreateJobSeekerParams{
-			UserID:    user.ID,
-			LastName:  body.LastName,
-			FirstName: body.FirstName,
-		})
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating job seeker details, kindly update your details. err: %v", err))
-			return
-		}
-		_, err = cfg.DB.CreateUserProfession(r.Context(), database.CreateUserProfessionParams{
-			UserID:       user.ID,
-			ProfessionID: uuid.MustParse(body.ProfessionID),
-		})
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating user profession, kindly update your details. err: %v", err))
-			return
-		}
+	err = cfg.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		// signup still succeeded, the user can ask for a new mail
+		log.Println(err)
 	}
 	helpers.RespondWithJson(w, 200, "signup successful")
 }
@@ -198,39 +109,94 @@ func (cfg *Config) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	body.Email = strings.ToLower(strings.TrimSpace(body.Email))
 
-	userExist, err := cfg.DB.UserExists(r.Context(), body.Email)
-	if err != nil {
-		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error validating user. err: %v", err))
-		return
-	}
-	if !userExist {
-		helpers.RespondWithError(w, http.StatusUnauthorized, "No User with this mail. Signup")
+	if !cfg.checkLoginThrottle(w, r, body.Email) {
 		return
 	}
 
 	user, err := cfg.DB.GetUserWithEmail(r.Context(), body.Email)
-
 	if err != nil {
+		if err == sql.ErrNoRows {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(body.Password))
+			cfg.loginFailed(w, r, body.Email, nil)
+			return
+		}
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
 		return
 	}
+	// accounts created with social login have no password
+	if user.Password == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(body.Password))
+		cfg.loginFailed(w, r, body.Email, &user.ID)
+		return
+	}
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password))
 	if err != nil {
-		if strings.Contains(err.Error(), `hashedPassword is not the hash of the given password`) {
-			helpers.RespondWithError(w, http.StatusUnauthorized, "Wrong password.")
+		cfg.loginFailed(w, r, body.Email, &user.ID)
+		return
+	}
+	if user.SuspendedAt.Valid {
+		helpers.RespondWithError(w, http.StatusForbidden, accountSuspendedMessage)
+		return
+	}
+	// with 2fa on, the password only earns a short lived challenge that /login/2fa exchanges for tokens
+	if user.TotpEnabledAt.Valid {
+		challengeToken, err := auth.MakeJwtTokenString(cfg.JwtKeys, user.ID.String(), "2fa_challenge", twoFactorChallengeExpirationTime)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating challenge token. err: %v", err))
 			return
 		}
-		helpers.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf(" err: %v", err))
+		response := struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			ChallengeToken    string `json:"challenge_token"`
+		}{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		}
+		helpers.RespondWithJson(w, 200, response)
 		return
 	}
+	cfg.clearLoginFailures(r.Context(), body.Email)
+	cfg.issueLoginTokens(w, r, user)
+}
+
+var errAccountSuspended = errors.New("account suspended")
+
+const accountSuspendedMessage = "Your account has been suspended. Contact support."
+
+// refreshReuseGrace is how long a rotated refresh token can still be used without revoking its family,
+// for requests that raced the one that rotated it.
+const refreshReuseGrace = 10 * time.Second
+
+func withinRefreshReuseGrace(rotatedAt, now time.Time) bool {
+	return now.Sub(rotatedAt) <= refreshReuseGrace
+}
+
+// startLoginSession sets the refresh token cookie of a new session for a fully authenticated user.
+func (cfg *Config) startLoginSession(w http.ResponseWriter, r *http.Request, user database.User) error {
+	if user.SuspendedAt.Valid {
+		return errAccountSuspended
+	}
+	// clean up expired tokens of old sessions before starting a new one
+	err := cfg.DB.DeleteExpiredUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		log.Println("error deleting expired refresh tokens. err: ", err)
+	}
 	// create refresh token
+	return auth.CreateRefreshToken(cfg.JwtKeys, user.ID, cfg.RefreshTokenEXpirationTime, w, r, cfg.DB)
+}
 
-	err = auth.CreateRefreshToken([]byte(cfg.JwtKey), user.ID, cfg.RefreshTokenEXpirationTime, w, cfg.DB)
+// issueLoginTokens starts a new session and responds with its access token.
+func (cfg *Config) issueLoginTokens(w http.ResponseWriter, r *http.Request, user database.User) {
+	err := cfg.startLoginSession(w, r, user)
+	if err == errAccountSuspended {
+		helpers.RespondWithError(w, http.StatusForbidden, accountSuspendedMessage)
+		return
+	}
 	if err != nil {
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating refresh token. err: %v", err))
 		return
 	}
-	access_token, err := auth.MakeJwtTokenString([]byte(cfg.JwtKey), user.ID.String(), "access_token", cfg.AcessTokenEXpirationTime)
+	access_token, err := auth.MakeJwtTokenString(cfg.JwtKeys, user.ID.String(), "access_token", cfg.AcessTokenEXpirationTime)
 	if err != nil {
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating access token. err: %v", err))
 		return
@@ -302,7 +268,18 @@ func (cfg *Config) PasswordChangeHandler(w http.ResponseWriter, r *http.Request)
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating password. err: %v", err))
 		return
 	}
-	err = auth.UpdateRefreshToken([]byte(cfg.JwtKey), user.ID, 24*7*60, w, cfg.DB)
+	// log out every other device and start a fresh session on this one
+	err = cfg.DB.DeleteUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking refresh tokens. err: %v", err))
+		return
+	}
+	err = cfg.RevocationStore.RevokeUserTokens(r.Context(), user.ID, time.Now())
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking access tokens. err: %v", err))
+		return
+	}
+	err = auth.CreateRefreshToken(cfg.JwtKeys, user.ID, cfg.RefreshTokenEXpirationTime, w, r, cfg.DB)
 	if err != nil {
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating refresh token. err: %v", err))
 		return
@@ -311,88 +288,84 @@ func (cfg *Config) PasswordChangeHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (cfg *Config) GetUserHandler(w http.ResponseWriter, r *http.Request, user User) {
+	profile, err := cfg.getUserProfile(r.Context(), user)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting profile. err: %v", err))
+		return
+	}
 
-	// 1. Default display name is the email prefix (fallback)
+	// Default display name is the email prefix (fallback)
 	displayName := strings.Split(user.Email, "@")[0]
-
-	switch user.Role {
-	case "job_seeker":
-		profile, err := cfg.DB.GetJobSeekerProfileByUserID(r.Context(), user.ID)
-		if err == nil {
-			displayName = profile.FirstName
-		}
-	case "employer":
-		profile, err := cfg.DB.GetEmployerProfileByUserID(r.Context(), user.ID)
-		if err == nil {
-			displayName = profile.CompanyName
-		}
-	case "admin":
-		// Try Job Seeker first
-		jsProfile, err := cfg.DB.GetJobSeekerProfileByUserID(r.Context(), user.ID)
-		if err == nil {
-			displayName = jsProfile.FirstName
-			break // Stop here if found
-		}
-
-		// Fallback to Employer check
-		empProfile, err := cfg.DB.GetEmployerProfileByUserID(r.Context(), user.ID)
-		if err == nil {
-			displayName = empProfile.CompanyName
-		}
+	if profile.JobSeeker != nil {
+		displayName = profile.JobSeeker.FirstName
+	} else if profile.Employer != nil {
+		displayName = profile.Employer.CompanyName
 	}
 	res := struct {
 		User
-		DisplayName string `json:"display_name"`
+		DisplayName string  `json:"display_name"`
+		Profile     Profile `json:"profile"`
 	}{
 		User:        user,
 		DisplayName: displayName,
+		Profile:     profile,
 	}
 
-	// 3. Respond with the new field
 	helpers.RespondWithJson(w, http.StatusOK, res)
 }
 
 func (cfg *Config) RefreshTokens(w http.ResponseWriter, r *http.Request) {
-	refreshtoken, err := r.Cookie("refresh_token")
+	refreshtoken, err := r.Cookie(auth.RefreshTokenCookieName)
 	if err != nil {
-		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting refreshToken, Try login again. err: %v", err))
+		helpers.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("error getting refreshToken, Try login again. err: %v", err))
 		return
 	}
 
 	refreshclaims := &jwt.RegisteredClaims{}
 
-	_, err = jwt.ParseWithClaims(
-		refreshtoken.Value,
-		refreshclaims,
-		func(token *jwt.Token) (interface{}, error) { return []byte(cfg.JwtKey), nil },
-	)
+	_, err = cfg.JwtKeys.Parse(refreshtoken.Value, refreshclaims, jwt.WithSubject("refresh_token"))
 
 	if err != nil {
-		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error parsing jwt claims. err: %v", err))
+		helpers.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("error parsing jwt claims. err: %v", err))
 		return
 	}
 	// Make sure refresh token exist in db
-	refreshTokenExists, err := cfg.DB.RefreshTokenExists(context.Background(), refreshtoken.Value)
+	dbRefreshToken, err := cfg.DB.GetRefreshTokenByHash(r.Context(), auth.HashToken(refreshtoken.Value))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			auth.ClearRefreshTokenCookie(w)
+			helpers.RespondWithError(w, http.StatusUnauthorized, "refresh token doesnt exist, Try login again.")
+			return
+		}
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error checking refresh token in db. err: %v", err))
 		return
 	}
-	if !refreshTokenExists {
-		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("refresh token doesnt exist. err: %v", err))
-		return
-	}
 	userIdString := refreshclaims.Issuer
 	userId, err := uuid.Parse(userIdString)
-	if err != nil {
-		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error parsing user id, err: %v", err))
+	if err != nil || userId != dbRefreshToken.UserID {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "invalid refresh token")
 		return
 	}
+	// A token that was already rotated is being used again, someone else has a copy of it. Unless it
+	// was rotated a moment ago, then it's another tab or a retry racing the request that rotated it.
+	rotatedJustNow := false
+	if dbRefreshToken.RotatedAt.Valid {
+		if !withinRefreshReuseGrace(dbRefreshToken.RotatedAt.Time, time.Now().UTC()) {
+			cfg.handleRefreshTokenReuse(w, r, dbRefreshToken)
+			return
+		}
+		rotatedJustNow = true
+	}
 
 	user, err := cfg.DB.GetUser(r.Context(), userId)
 	if err != nil {
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user with id, err: %v", err))
 		return
 	}
+	if user.SuspendedAt.Valid {
+		helpers.RespondWithError(w, http.StatusForbidden, accountSuspendedMessage)
+		return
+	}
 
 	refreshExpiration := refreshclaims.ExpiresAt.Time
 
@@ -400,19 +373,49 @@ func (cfg *Config) RefreshTokens(w http.ResponseWriter, r *http.Request) {
 		helpers.RespondWithError(w, http.StatusUnauthorized, "refresh token expired")
 		return
 	}
-	// invalidate current refresh token
-	err = cfg.DB.DeleteRefreshToken(context.Background(), refreshtoken.Value)
+
+	// the browser already got the child's cookie from the request that rotated it, only the access
+	// token is needed
+	if rotatedJustNow {
+		cfg.respondWithAccessToken(w, user)
+		return
+	}
+
+	// rotate: mark the current token used and issue its child in the same family
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction, err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+	rows, err := qtx.MarkRefreshTokenRotated(r.Context(), dbRefreshToken.ID)
 	if err != nil {
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error invalidating refresh token, err: %v", err))
 		return
 	}
-	// make new refresh token
-	err = auth.CreateRefreshToken([]byte(cfg.JwtKey), userId, cfg.RefreshTokenEXpirationTime, w, cfg.DB)
+	if rows == 0 {
+		// another request rotated it first, just now
+		tx.Rollback()
+		cfg.respondWithAccessToken(w, user)
+		return
+	}
+	newRefreshToken, expiresAt, err := auth.RotateRefreshToken(cfg.JwtKeys, dbRefreshToken, cfg.RefreshTokenEXpirationTime, r, qtx)
 	if err != nil {
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating refresh token, err: %v", err))
 		return
 	}
-	access_token, err := auth.MakeJwtTokenString([]byte(cfg.JwtKey), user.ID.String(), "access_token", cfg.AcessTokenEXpirationTime)
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error saving refresh token, err: %v", err))
+		return
+	}
+	auth.SetRefreshTokenCookie(w, newRefreshToken, expiresAt)
+	cfg.respondWithAccessToken(w, user)
+}
+
+// respondWithAccessToken responds to a refresh with a new access token for the user.
+func (cfg *Config) respondWithAccessToken(w http.ResponseWriter, user database.User) {
+	access_token, err := auth.MakeJwtTokenString(cfg.JwtKeys, user.ID.String(), "access_token", cfg.AcessTokenEXpirationTime)
 	if err != nil {
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating access token. err: %v", err))
 		return
@@ -430,3 +433,33 @@ func (cfg *Config) Validate(w http.ResponseWriter, r *http.Request) {
 
 	helpers.RespondWithJson(w, 200, "logged in")
 }
+
+// handleRefreshTokenReuse revokes the whole token family and records a security event the user can see.
+func (cfg *Config) handleRefreshTokenReuse(w http.ResponseWriter, r *http.Request, reused database.RefreshToken) {
+	err := cfg.DB.DeleteRefreshTokenFamily(r.Context(), reused.FamilyID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking refresh token family, err: %v", err))
+		return
+	}
+	_, err = cfg.DB.CreateSecurityEvent(r.Context(), database.CreateSecurityEventParams{
+		UserID:    reused.UserID,
+		EventType: "refresh_token_reuse",
+		IpAddress: auth.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Details: sql.NullString{
+			Valid:  true,
+			String: fmt.Sprintf("a rotated refresh token was used again, the session started on %s from %s was revoked", reused.FamilyCreatedAt.Format(time.RFC3339), reused.IpAddress),
+		},
+	})
+	if err != nil {
+		log.Println("error recording security event. err: ", err)
+	}
+	auth.ClearRefreshTokenCookie(w)
+	helpers.RespondWithError(w, http.StatusUnauthorized, "refresh token reuse detected, Try login again.")
+}
+
+// JWKSHandler publishes the public keys used to verify our tokens.
+func (cfg *Config) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	helpers.RespondWithJson(w, http.StatusOK, cfg.JwtKeys.JWKS())
+}
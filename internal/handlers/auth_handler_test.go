@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithinRefreshReuseGrace(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		rotatedAt time.Time
+		want      bool
+	}{
+		{"same instant", now, true},
+		{"racing tab", now.Add(-2 * time.Second), true},
+		{"edge of the window", now.Add(-refreshReuseGrace), true},
+		{"just after the window", now.Add(-refreshReuseGrace - time.Millisecond), false},
+		{"old token replayed", now.Add(-time.Hour), false},
+		// the db clock can be slightly ahead
+		{"rotated in the future", now.Add(time.Second), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withinRefreshReuseGrace(tt.rotatedAt, now); got != tt.want {
+				t.Errorf("withinRefreshReuseGrace(%s) = %v, want %v", now.Sub(tt.rotatedAt), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+)
+
+// GetAuthSessionsHandler lists the devices the user is logged in on.
+func (cfg *Config) GetAuthSessionsHandler(w http.ResponseWriter, r *http.Request, user User) {
+	refreshTokens, err := cfg.DB.GetUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting sessions. err: %v", err))
+		return
+	}
+	currentTokenHash := ""
+	if cookie, err := r.Cookie(auth.RefreshTokenCookieName); err == nil {
+		currentTokenHash = auth.HashToken(cookie.Value)
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbRefreshTokensToModelAuthSessions(refreshTokens, currentTokenHash))
+}
+
+// DeleteAuthSessionHandler logs out a single device, e.g. a lost phone.
+func (cfg *Config) DeleteAuthSessionHandler(w http.ResponseWriter, r *http.Request, user User) {
+	sessionID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error parsing session id. err: %v", err))
+		return
+	}
+	// a device session is a token family
+	rows, err := cfg.DB.DeleteUserRefreshTokenFamily(r.Context(), database.DeleteUserRefreshTokenFamilyParams{
+		FamilyID: sessionID,
+		UserID:   user.ID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking session. err: %v", err))
+		return
+	}
+	if rows == 0 {
+		helpers.RespondWithError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, "session revoked")
+}
+
+// LogoutAllHandler revokes every device session of the user, including the current one.
+func (cfg *Config) LogoutAllHandler(w http.ResponseWriter, r *http.Request, user User) {
+	err := cfg.DB.DeleteUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking sessions. err: %v", err))
+		return
+	}
+	// access tokens already handed out must stop working too
+	err = cfg.RevocationStore.RevokeUserTokens(r.Context(), user.ID, time.Now())
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking access tokens. err: %v", err))
+		return
+	}
+	auth.ClearRefreshTokenCookie(w)
+	helpers.RespondWithJson(w, http.StatusOK, "logged out of all devices")
+}
+
+// LogoutHandler ends the current device session. It works with an expired access token,
+// the refresh cookie is enough to know which session to end.
+func (cfg *Config) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(auth.RefreshTokenCookieName); err == nil && cookie.Value != "" {
+		dbRefreshToken, err := cfg.DB.GetRefreshTokenByHash(r.Context(), auth.HashToken(cookie.Value))
+		switch {
+		case err == nil:
+			err = cfg.DB.DeleteRefreshTokenFamily(r.Context(), dbRefreshToken.FamilyID)
+			if err != nil {
+				helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking refresh token. err: %v", err))
+				return
+			}
+		case err != sql.ErrNoRows:
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting refresh token. err: %v", err))
+			return
+		}
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		claims := &jwt.RegisteredClaims{}
+		_, err := cfg.JwtKeys.Parse(strings.TrimPrefix(authHeader, "Bearer "), claims)
+		// an invalid or expired access token has nothing left to revoke
+		if err == nil && claims.ID != "" && claims.ExpiresAt != nil {
+			err = cfg.RevocationStore.RevokeToken(r.Context(), claims.ID, claims.ExpiresAt.Time)
+			if err != nil {
+				helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking access token. err: %v", err))
+				return
+			}
+		}
+	}
+
+	auth.ClearRefreshTokenCookie(w)
+	helpers.RespondWithJson(w, http.StatusOK, "logged out")
+}
+
+// GetSecurityEventsHandler shows the user security relevant events on their account.
+func (cfg *Config) GetSecurityEventsHandler(w http.ResponseWriter, r *http.Request, user User) {
+	events, err := cfg.DB.GetUserSecurityEvents(r.Context(), database.GetUserSecurityEventsParams{
+		UserID: user.ID,
+		Limit:  50,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting security events. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbSecurityEventsToModelSecurityEvents(events))
+}
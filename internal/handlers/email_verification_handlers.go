@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+	"github.com/muhammadolammi/jobmatchapi/internal/mailer"
+)
+
+func (cfg *Config) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := auth.MakeEmailVerificationToken(cfg.JwtKeys, user.ID, user.Email, cfg.EmailVerificationTokenExpirationTime)
+	if err != nil {
+		return fmt.Errorf("error creating verification token. err: %v", err)
+	}
+	verifyLink := fmt.Sprintf("%s/verify-email?token=%s", cfg.AppURL, token)
+	err = cfg.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your JobMatch email",
+		Body:    fmt.Sprintf("Welcome to JobMatch!\n\nPlease confirm this is your email by opening the link below.\n\n%s\n\nIf you didn't create an account, you can ignore this mail.", verifyLink),
+	})
+	if err != nil {
+		return fmt.Errorf("error sending verification mail. err: %v", err)
+	}
+	return cfg.DB.CreateEmailVerificationSend(ctx, user.ID)
+}
+
+func (cfg *Config) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "missing verification token")
+		return
+	}
+	claims, err := auth.ParseEmailVerificationToken(cfg.JwtKeys, token)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired verification token")
+		return
+	}
+	userId, err := uuid.Parse(claims.Issuer)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired verification token")
+		return
+	}
+	user, err := cfg.DB.GetUser(r.Context(), userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired verification token")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	if user.Email != claims.Email {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired verification token")
+		return
+	}
+	err = cfg.DB.MarkUserEmailVerified(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error verifying email. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, "email verified")
+}
+
+func (cfg *Config) ResendVerificationEmailHandler(w http.ResponseWriter, r *http.Request, user User) {
+	if user.EmailVerified {
+		helpers.RespondWithError(w, http.StatusBadRequest, "email already verified")
+		return
+	}
+	dbUser, err := cfg.DB.GetUser(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	err = cfg.sendVerificationEmail(r.Context(), dbUser)
+	if err != nil {
+		log.Println(err)
+		helpers.RespondWithError(w, http.StatusInternalServerError, "error sending verification mail")
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, "verification mail sent")
+}
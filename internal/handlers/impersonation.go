@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+)
+
+const (
+	impersonationTokenExpirationTime = 15 // in minute
+	impersonationAuditDefaultLimit   = 100
+	impersonationAuditMaxLimit       = 500
+)
+
+type impersonationBlockedContextKey struct{}
+
+// BlockImpersonation marks a route support must never use while impersonating, e.g. billing,
+// 2fa, tokens and account deletion. AuthMiddleware refuses impersonation tokens on it.
+func (cfg *Config) BlockImpersonation(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), impersonationBlockedContextKey{}, true)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// serveImpersonated runs next as the target user for a token carrying an act claim and records the request
+// in the impersonation audit log. The admin in act must still be an active admin.
+func (cfg *Config) serveImpersonated(w http.ResponseWriter, r *http.Request, claims *auth.AccessClaims, user database.User, next func(http.ResponseWriter, *http.Request, User)) {
+	adminID, err := uuid.Parse(claims.Act.Subject)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+	admin, err := cfg.DB.GetUser(r.Context(), adminID)
+	if err != nil || admin.Role != "admin" || admin.SuspendedAt.Valid {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	if blocked, _ := r.Context().Value(impersonationBlockedContextKey{}).(bool); blocked {
+		helpers.RespondWithError(ww, http.StatusForbidden, "This action isn't available while impersonating a user")
+	} else {
+		modelUser := DbUserToModelUser(user)
+		modelUser.ImpersonatedBy = &adminID
+		ctx := context.WithValue(r.Context(), "user", modelUser)
+		next(ww, r.WithContext(ctx), modelUser)
+	}
+
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	cfg.recordImpersonationAudit(r, adminID, user.ID, claims.ID, status, "")
+}
+
+func (cfg *Config) recordImpersonationAudit(r *http.Request, adminID, targetUserID uuid.UUID, tokenID string, status int, reason string) {
+	// the request context may already be cancelled, e.g. a closed sse stream, the entry must still be written
+	err := cfg.DB.CreateImpersonationAuditEntry(context.WithoutCancel(r.Context()), database.CreateImpersonationAuditEntryParams{
+		AdminID:      adminID,
+		TargetUserID: targetUserID,
+		TokenID:      tokenID,
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		StatusCode:   int32(status),
+		Reason:       sql.NullString{String: reason, Valid: reason != ""},
+		IpAddress:    auth.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+	})
+	if err != nil {
+		log.Printf("error recording impersonation audit entry for admin %s. err: %v", adminID, err)
+	}
+}
+
+// ImpersonateUserHandler issues a short lived access token for the user in {userID} that carries the
+// admin in its act claim. A reason is required for the audit log. There is no refresh token, support
+// asks for a new one when it expires.
+func (cfg *Config) ImpersonateUserHandler(w http.ResponseWriter, r *http.Request, user User) {
+	if user.ImpersonatedBy != nil {
+		helpers.RespondWithError(w, http.StatusForbidden, "This action isn't available while impersonating a user")
+		return
+	}
+	targetID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	body := struct {
+		Reason string `json:"reason"`
+	}{}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "enter the reason for impersonating this user, e.g. the support ticket")
+		return
+	}
+	target, err := cfg.DB.GetUser(r.Context(), targetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	if target.ID == user.ID {
+		helpers.RespondWithError(w, http.StatusBadRequest, "you can't impersonate yourself")
+		return
+	}
+	// an admin token for another admin would let support escalate through someone else's account
+	if target.Role == "admin" {
+		helpers.RespondWithError(w, http.StatusForbidden, "admins can't be impersonated")
+		return
+	}
+	if target.SuspendedAt.Valid {
+		helpers.RespondWithError(w, http.StatusBadRequest, "suspended users can't be impersonated")
+		return
+	}
+
+	token, claims, err := auth.MakeImpersonationToken(cfg.JwtKeys, target.ID, user.ID, impersonationTokenExpirationTime)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating impersonation token. err: %v", err))
+		return
+	}
+	cfg.recordImpersonationAudit(r, user.ID, target.ID, claims.ID, http.StatusCreated, body.Reason)
+	// the user can see support looked at their account in their security events
+	cfg.recordSecurityEvent(r, target.ID, "impersonation_started", fmt.Sprintf("by support (%s)", user.Email))
+	log.Printf("admin %s started impersonating user %s: %s\n", user.Email, target.ID, body.Reason)
+	helpers.RespondWithJson(w, http.StatusCreated, map[string]any{
+		"access_token": token,
+		"expires_at":   claims.ExpiresAt.Time,
+		"user":         DbUserToModelUser(target),
+	})
+}
+
+// GetImpersonationAuditHandler lists the audit log newest first, filtered by admin_id and/or user_id.
+func (cfg *Config) GetImpersonationAuditHandler(w http.ResponseWriter, r *http.Request, user User) {
+	query := r.URL.Query()
+	params := database.GetImpersonationAuditLogParams{PageSize: impersonationAuditDefaultLimit}
+	if adminID := query.Get("admin_id"); adminID != "" {
+		id, err := uuid.Parse(adminID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid admin_id")
+			return
+		}
+		params.AdminID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if userID := query.Get("user_id"); userID != "" {
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid user_id")
+			return
+		}
+		params.TargetUserID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > impersonationAuditMaxLimit {
+			helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", impersonationAuditMaxLimit))
+			return
+		}
+		params.PageSize = int32(value)
+	}
+	entries, err := cfg.DB.GetImpersonationAuditLog(r.Context(), params)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting impersonation audit log. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbImpersonationAuditLogToModelImpersonationAuditEntries(entries))
+}
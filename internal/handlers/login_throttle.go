@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// failures older than this are forgotten
+const loginFailureWindow = 15 * time.Minute
+
+type loginThrottlePolicy struct {
+	scope      string
+	delayAfter int32 // failures before each new attempt has to wait
+	lockAfter  int32
+	lockFor    time.Duration
+}
+
+var (
+	// per account, counted for unknown emails too so the response doesn't reveal which exist
+	emailLoginPolicy = loginThrottlePolicy{scope: "email", delayAfter: 3, lockAfter: 10, lockFor: 15 * time.Minute}
+	// per ip, looser since offices and mobile networks share addresses
+	ipLoginPolicy = loginThrottlePolicy{scope: "ip", delayAfter: 20, lockAfter: 100, lockFor: 15 * time.Minute}
+)
+
+// dummyPasswordHash is compared against when the email has no password, so every failed login
+// takes about as long as a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("jobmatch-dummy-password"), 10)
+
+// delay doubles from 1s with every failure past delayAfter, up to a minute.
+func (p loginThrottlePolicy) delay(failures int32) time.Duration {
+	if failures < p.delayAfter {
+		return 0
+	}
+	exp := float64(failures - p.delayAfter)
+	return time.Duration(math.Min(math.Pow(2, exp), 60)) * time.Second
+}
+
+// loginRetryAfter returns how long the caller has to wait before trying key again.
+func (cfg *Config) loginRetryAfter(ctx context.Context, policy loginThrottlePolicy, key string) (time.Duration, error) {
+	throttle, err := cfg.DB.GetLoginThrottle(ctx, database.GetLoginThrottleParams{
+		Scope: policy.scope,
+		Key:   key,
+	})
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	if throttle.LockedUntil.Valid && throttle.LockedUntil.Time.After(now) {
+		return throttle.LockedUntil.Time.Sub(now), nil
+	}
+	if throttle.LastFailedAt.Before(now.Add(-loginFailureWindow)) {
+		return 0, nil
+	}
+	nextAttempt := throttle.LastFailedAt.Add(policy.delay(throttle.FailedAttempts))
+	if nextAttempt.After(now) {
+		return nextAttempt.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// recordLoginFailure counts a failure for key and reports whether it locked the key.
+func (cfg *Config) recordLoginFailure(ctx context.Context, policy loginThrottlePolicy, key string) (bool, error) {
+	now := time.Now().UTC()
+	err := cfg.DB.DeleteStaleLoginThrottles(ctx, now.Add(-loginFailureWindow))
+	if err != nil {
+		log.Println("error deleting stale login throttles. err: ", err)
+	}
+	throttle, err := cfg.DB.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		Scope:       policy.scope,
+		Key:         key,
+		WindowStart: now.Add(-loginFailureWindow),
+	})
+	if err != nil {
+		return false, err
+	}
+	if throttle.FailedAttempts < policy.lockAfter {
+		return false, nil
+	}
+	err = cfg.DB.LockLoginThrottle(ctx, database.LockLoginThrottleParams{
+		Scope:       policy.scope,
+		Key:         key,
+		LockedUntil: sql.NullTime{Valid: true, Time: now.Add(policy.lockFor)},
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// checkLoginThrottle responds with 429 and returns false when the email or the caller ip
+// has to wait before another attempt.
+func (cfg *Config) checkLoginThrottle(w http.ResponseWriter, r *http.Request, email string) bool {
+	retryAfter := time.Duration(0)
+	for _, check := range []struct {
+		policy loginThrottlePolicy
+		key    string
+	}{
+		{emailLoginPolicy, email},
+		{ipLoginPolicy, auth.ClientIP(r)},
+	} {
+		wait, err := cfg.loginRetryAfter(r.Context(), check.policy, check.key)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error checking login attempts. err: %v", err))
+			return false
+		}
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+		helpers.RespondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts. Try again later.")
+		return false
+	}
+	return true
+}
+
+// loginFailed records a failed attempt and responds the same way whatever went wrong.
+// userId is nil when no account has the email.
+func (cfg *Config) loginFailed(w http.ResponseWriter, r *http.Request, email string, userId *uuid.UUID) {
+	cfg.recordFailedLogin(r, email, userId)
+	helpers.RespondWithError(w, http.StatusUnauthorized, "Invalid email or password.")
+}
+
+func (cfg *Config) recordFailedLogin(r *http.Request, email string, userId *uuid.UUID) {
+	locked, err := cfg.recordLoginFailure(r.Context(), emailLoginPolicy, email)
+	if err != nil {
+		log.Println("error recording failed login. err: ", err)
+	}
+	if locked && userId != nil {
+		cfg.recordSecurityEvent(r, *userId, "account_locked", fmt.Sprintf("login locked for %s after %d failed attempts", emailLoginPolicy.lockFor, emailLoginPolicy.lockAfter))
+	}
+	_, err = cfg.recordLoginFailure(r.Context(), ipLoginPolicy, auth.ClientIP(r))
+	if err != nil {
+		log.Println("error recording failed login. err: ", err)
+	}
+}
+
+func (cfg *Config) clearLoginFailures(ctx context.Context, email string) {
+	_, err := cfg.DB.ClearLoginThrottle(ctx, database.ClearLoginThrottleParams{
+		Scope: emailLoginPolicy.scope,
+		Key:   email,
+	})
+	if err != nil {
+		log.Println("error clearing failed logins. err: ", err)
+	}
+}
+
+func (cfg *Config) GetLoginLocksHandler(w http.ResponseWriter, r *http.Request, user User) {
+	throttles, err := cfg.DB.GetLockedLoginThrottles(r.Context())
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting login locks. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbLoginThrottlesToModelLoginLocks(throttles))
+}
+
+// UnlockLoginHandler lets an admin lift a lockout before it expires, by email or by ip.
+func (cfg *Config) UnlockLoginHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	params := database.ClearLoginThrottleParams{}
+	switch {
+	case body.Email != "":
+		params.Scope = emailLoginPolicy.scope
+		params.Key = strings.ToLower(strings.TrimSpace(body.Email))
+	case body.IP != "":
+		params.Scope = ipLoginPolicy.scope
+		params.Key = strings.TrimSpace(body.IP)
+	default:
+		helpers.RespondWithError(w, http.StatusBadRequest, "Enter an email or an ip.")
+		return
+	}
+	rows, err := cfg.DB.ClearLoginThrottle(r.Context(), params)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error unlocking login. err: %v", err))
+		return
+	}
+	if rows == 0 {
+		helpers.RespondWithError(w, http.StatusNotFound, "no failed logins recorded for this "+params.Scope)
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, "login unlocked")
+}
@@ -11,8 +11,9 @@ import (
 	"time"
 	"unicode/utf8"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
 	"github.com/muhammadolammi/jobmatchapi/internal/database"
 	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
 )
@@ -41,6 +42,22 @@ func (cfg *Config) ClientAuth() func(http.Handler) http.Handler {
 				next.ServeHTTP(w, r)
 				return
 			}
+			// Bypass the sessions websocket, browsers can't send our headers on it. The client
+			// authenticates with its first message instead.
+			if r.URL.Path == "/api/sessions/ws" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Bypass the public jwks, other services fetch it without a client key
+			if strings.HasPrefix(r.URL.Path, "/.well-known/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Bypass the oidc browser redirects, they are plain navigations without our headers
+			if strings.HasPrefix(r.URL.Path, "/api/oauth/") && r.Method == http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
 			// Bypass Paystack webhook
 			if strings.HasPrefix(r.URL.Path, "/api/webhook/paystack") {
 				// TODO handle paystack athorization
@@ -50,17 +67,52 @@ func (cfg *Config) ClientAuth() func(http.Handler) http.Handler {
 			}
 
 			clientApiKey := r.Header.Get("client-api-key")
+			// scripts using a personal access token aren't one of our frontends, a valid token is enough.
+			// AuthMiddleware still checks the route accepts it.
+			if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); clientApiKey == "" && isPersonalAccessToken(token) {
+				dbToken, err := cfg.DB.GetActivePersonalAccessTokenByHash(r.Context(), auth.HashToken(token))
+				if err != nil {
+					if err != sql.ErrNoRows {
+						log.Println("error getting personal access token. err: ", err)
+						helpers.RespondWithError(w, http.StatusInternalServerError, "error validating token")
+						return
+					}
+					apiClientRejections.Add("invalid", 1)
+					helpers.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+					return
+				}
+				apiClientRequests.Add("personal_access_token", 1)
+				ctx := context.WithValue(r.Context(), personalAccessTokenContextKey{}, dbToken)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
 			if clientApiKey == "" {
 				log.Println("empty client api key  in request.")
+				apiClientRejections.Add("missing", 1)
 				helpers.RespondWithError(w, http.StatusUnauthorized, "empty client api key in request.")
 				return
 			}
-			if clientApiKey != cfg.ClientApiKey {
+			client, err := cfg.resolveAPIClient(r.Context(), clientApiKey)
+			if err != nil {
+				if err != sql.ErrNoRows {
+					log.Printf("error resolving api client. err: %v", err)
+					helpers.RespondWithError(w, http.StatusInternalServerError, "error checking client api key.")
+					return
+				}
 				log.Println("invalid client api key in request.")
+				apiClientRejections.Add("invalid", 1)
 				helpers.RespondWithError(w, http.StatusUnauthorized, "invalid client api key in request.")
 				return
 			}
-			next.ServeHTTP(w, r)
+			if origin := r.Header.Get("Origin"); !client.originAllowed(origin) {
+				log.Printf("api client %s used from disallowed origin %s", client.Name, origin)
+				apiClientRejections.Add("origin", 1)
+				helpers.RespondWithError(w, http.StatusForbidden, "origin not allowed for this client api key.")
+				return
+			}
+			apiClientRequests.Add(client.Name, 1)
+			ctx := context.WithValue(r.Context(), apiClientContextKey{}, client)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
@@ -74,64 +126,113 @@ func (cfg *Config) AuthMiddleware(next func(http.ResponseWriter, *http.Request,
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		authclaims := &jwt.RegisteredClaims{}
-
-		authJwt, err := jwt.ParseWithClaims(tokenString, authclaims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JwtKey), nil
-		})
-		if err != nil || !authJwt.Valid {
-			helpers.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
-			return
+		// personal access tokens only work on routes wrapped with RequireScope
+		var user database.User
+		var authclaims *auth.AccessClaims
+		if isPersonalAccessToken(tokenString) {
+			var ok bool
+			user, ok = cfg.personalAccessTokenUser(w, r, tokenString)
+			if !ok {
+				return
+			}
+		} else {
+			var code int
+			var msg string
+			user, authclaims, code, msg = cfg.verifyAccessToken(r.Context(), tokenString)
+			if code != 0 {
+				helpers.RespondWithError(w, code, msg)
+				return
+			}
 		}
-
-		if authclaims.ExpiresAt != nil && authclaims.ExpiresAt.Time.Before(time.Now().UTC()) {
-			helpers.RespondWithError(w, http.StatusUnauthorized, "Token expired")
+		if user.SuspendedAt.Valid {
+			helpers.RespondWithError(w, http.StatusForbidden, "Account suspended")
 			return
 		}
-
-		userId, err := authJwt.Claims.GetIssuer()
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusUnauthorized, "Invalid token issuer")
+		if authclaims != nil && authclaims.Act != nil {
+			cfg.serveImpersonated(w, r, authclaims, user, next)
 			return
 		}
 
-		id, err := uuid.Parse(userId)
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusUnauthorized, "Invalid user ID in token")
-			return
-		}
+		ctx := context.WithValue(r.Context(), "user", DbUserToModelUser(user))
+		next(w, r.WithContext(ctx), DbUserToModelUser(user))
+	})
+}
 
-		user, err := cfg.DB.GetUser(r.Context(), id)
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusUnauthorized, "User not found")
+// verifyAccessToken checks a login access token and returns its user. code is 0 when the token is valid,
+// otherwise it's the status code and msg the message to respond with.
+func (cfg *Config) verifyAccessToken(ctx context.Context, tokenString string) (user database.User, claims *auth.AccessClaims, code int, msg string) {
+	claims = &auth.AccessClaims{}
+	authJwt, err := cfg.JwtKeys.Parse(tokenString, claims)
+	if err != nil || !authJwt.Valid {
+		return user, nil, http.StatusUnauthorized, "Invalid or expired token"
+	}
+
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(time.Now().UTC()) {
+		return user, nil, http.StatusUnauthorized, "Token expired"
+	}
+	// refresh and email verification tokens are signed with the same key, only accept access tokens here
+	if claims.Subject != "access_token" {
+		return user, nil, http.StatusUnauthorized, "Invalid or expired token"
+	}
+
+	userId, err := authJwt.Claims.GetIssuer()
+	if err != nil {
+		return user, nil, http.StatusUnauthorized, "Invalid token issuer"
+	}
+
+	id, err := uuid.Parse(userId)
+	if err != nil {
+		return user, nil, http.StatusUnauthorized, "Invalid user ID in token"
+	}
+
+	// logged out tokens, reset passwords... must stop working right away
+	issuedAt := time.Time{}
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := cfg.RevocationStore.IsRevoked(ctx, claims.ID, id, issuedAt)
+	if err != nil {
+		log.Println("error checking token revocation. err: ", err)
+		return user, nil, http.StatusInternalServerError, "error validating token"
+	}
+	if revoked {
+		return user, nil, http.StatusUnauthorized, "Token revoked"
+	}
+
+	user, err = cfg.DB.GetUser(ctx, id)
+	if err != nil {
+		return user, nil, http.StatusUnauthorized, "User not found"
+	}
+	return user, claims, 0, ""
+}
+
+// VerifiedAuthMiddleware is AuthMiddleware that also blocks users who haven't verified their email,
+// when REQUIRE_VERIFIED_EMAIL is on.
+func (cfg *Config) VerifiedAuthMiddleware(next func(http.ResponseWriter, *http.Request, User)) http.HandlerFunc {
+	return cfg.AuthMiddleware(func(w http.ResponseWriter, r *http.Request, user User) {
+		if cfg.RequireVerifiedEmail && !user.EmailVerified && user.Role != "admin" {
+			helpers.RespondWithJson(w, http.StatusForbidden, map[string]any{
+				"error":   "email_not_verified",
+				"message": "Verify your email to continue",
+			})
 			return
 		}
-
-		ctx := context.WithValue(r.Context(), "user", DbUserToModelUser(user))
-		next(w, r.WithContext(ctx), DbUserToModelUser(user))
+		next(w, r, user)
 	})
 }
+
+// AnalyzeRateLimiter turns away users who used up their daily analyses. It doesn't count the analysis,
+// queueSessionAnalysis does that in the transaction that queues it, so a request that fails counts nothing.
 func (cfg *Config) AnalyzeRateLimiter(next func(http.ResponseWriter, *http.Request, User)) http.HandlerFunc {
-	return cfg.AuthMiddleware(func(w http.ResponseWriter, r *http.Request, user User) {
+	return cfg.VerifiedAuthMiddleware(func(w http.ResponseWriter, r *http.Request, user User) {
 		if user.Role == "admin" {
 			next(w, r, user)
 			return
 		}
-		now := time.Now()
 		usage, err := cfg.DB.GetUserUsage(r.Context(), user.ID)
 		switch {
 		// ✅ First-time usage
 		case err == sql.ErrNoRows:
-			err = cfg.DB.InsertUserUsage(r.Context(), database.InsertUserUsageParams{
-				UserID:     user.ID,
-				MaxDaily:   int32(cfg.RateLimit),
-				Count:      1,
-				LastUsedAt: now,
-			})
-			if err != nil {
-				helpers.RespondWithError(w, http.StatusInternalServerError, "failed to initialize usage")
-				return
-			}
 
 		// ✅ Some other DB error
 		case err != nil:
@@ -140,29 +241,9 @@ func (cfg *Config) AnalyzeRateLimiter(next func(http.ResponseWriter, *http.Reque
 
 		// ✅ Normal usage flow
 		default:
-			if now.Sub(usage.LastUsedAt) < 24*time.Hour {
-				if usage.Count >= usage.MaxDaily {
-					remaining := 24*time.Hour - now.Sub(usage.LastUsedAt)
-					helpers.RespondWithJson(w, http.StatusTooManyRequests, map[string]any{
-						"error":             "daily_usage_limit_reached",
-						"message":           "Daily usage limit reached",
-						"remaining_seconds": int(remaining.Seconds()),
-					})
-					return
-				}
-
-				_ = cfg.DB.UpdateUserUsage(r.Context(), database.UpdateUserUsageParams{
-					UserID:     user.ID,
-					Count:      usage.Count + 1,
-					LastUsedAt: now,
-				})
-			} else {
-				// new day
-				_ = cfg.DB.UpdateUserUsage(r.Context(), database.UpdateUserUsageParams{
-					UserID:     user.ID,
-					Count:      1,
-					LastUsedAt: now,
-				})
+			if isUsageLimitReached(usage, time.Now()) {
+				respondUsageLimitReached(w, usage, time.Now())
+				return
 			}
 		}
 
@@ -171,6 +252,20 @@ func (cfg *Config) AnalyzeRateLimiter(next func(http.ResponseWriter, *http.Reque
 	})
 }
 
+// isUsageLimitReached mirrors ConsumeUserUsage, the window starts over 24 hours after the last use.
+func isUsageLimitReached(usage database.GetUserUsageRow, now time.Time) bool {
+	return now.Sub(usage.LastUsedAt) < 24*time.Hour && usage.Count >= usage.MaxDaily
+}
+
+func respondUsageLimitReached(w http.ResponseWriter, usage database.GetUserUsageRow, now time.Time) {
+	remaining := 24*time.Hour - now.Sub(usage.LastUsedAt)
+	helpers.RespondWithJson(w, http.StatusTooManyRequests, map[string]any{
+		"error":             "daily_usage_limit_reached",
+		"message":           "Daily usage limit reached",
+		"remaining_seconds": int(remaining.Seconds()),
+	})
+}
+
 func (cfg *Config) RoleMiddleware(allowedRoles []string, next func(http.ResponseWriter, *http.Request, User)) http.HandlerFunc {
 	return cfg.AuthMiddleware(func(w http.ResponseWriter, r *http.Request, user User) {
 		for _, role := range allowedRoles {
@@ -183,6 +278,37 @@ func (cfg *Config) RoleMiddleware(allowedRoles []string, next func(http.Response
 	})
 }
 
+// OrgRoleMiddleware is RoleMiddleware for organization roles. The organization comes from the {orgID}
+// url param. Non members get a 404 so organization ids can't be probed.
+func (cfg *Config) OrgRoleMiddleware(allowedRoles []string, next func(http.ResponseWriter, *http.Request, User, OrganizationMember)) http.HandlerFunc {
+	return cfg.AuthMiddleware(func(w http.ResponseWriter, r *http.Request, user User) {
+		orgID, err := uuid.Parse(chi.URLParam(r, "orgID"))
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid organization id")
+			return
+		}
+		member, err := cfg.DB.GetOrganizationMember(r.Context(), database.GetOrganizationMemberParams{
+			OrganizationID: orgID,
+			UserID:         user.ID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				helpers.RespondWithError(w, http.StatusNotFound, "organization not found")
+				return
+			}
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting membership. err: %v", err))
+			return
+		}
+		for _, role := range allowedRoles {
+			if member.Role == role {
+				next(w, r, user, DbOrganizationMemberToModelOrganizationMember(member))
+				return
+			}
+		}
+		helpers.RespondWithError(w, http.StatusForbidden, "Forbidden: insufficient organization permissions")
+	})
+}
+
 func (cfg *Config) ContactRateLimiter(next func(http.ResponseWriter, *http.Request, PostContactMessageBody)) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		body := PostContactMessageBody{}
@@ -255,3 +381,27 @@ func (cfg *Config) ContactRateLimiter(next func(http.ResponseWriter, *http.Reque
 
 	})
 }
+
+func (cfg *Config) VerificationEmailRateLimiter(next func(http.ResponseWriter, *http.Request, User)) http.HandlerFunc {
+	return cfg.AuthMiddleware(func(w http.ResponseWriter, r *http.Request, user User) {
+		lastMinuteSends, err := cfg.DB.GetUserLastMinuteEmailVerificationSends(r.Context(), user.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, "error validating request")
+			return
+		}
+		if lastMinuteSends >= 1 {
+			helpers.RespondWithError(w, http.StatusTooManyRequests, "wait a minute before requesting another mail")
+			return
+		}
+		lastHourSends, err := cfg.DB.GetUserLastHourEmailVerificationSends(r.Context(), user.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, "error validating request")
+			return
+		}
+		if lastHourSends >= 5 {
+			helpers.RespondWithError(w, http.StatusTooManyRequests, "too many verification mails this hour")
+			return
+		}
+		next(w, r, user)
+	})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+)
+
+func TestIsUsageLimitReached(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		usage database.GetUserUsageRow
+		want  bool
+	}{
+		{"under the limit", database.GetUserUsageRow{Count: 1, MaxDaily: 2, LastUsedAt: now.Add(-time.Hour)}, false},
+		{"at the limit", database.GetUserUsageRow{Count: 2, MaxDaily: 2, LastUsedAt: now.Add(-time.Hour)}, true},
+		{"window over", database.GetUserUsageRow{Count: 2, MaxDaily: 2, LastUsedAt: now.Add(-24 * time.Hour)}, false},
+		{"no analyses allowed", database.GetUserUsageRow{Count: 0, MaxDaily: 0, LastUsedAt: now}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUsageLimitReached(tt.usage, now); got != tt.want {
+				t.Errorf("isUsageLimitReached = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/muhammadolammi/jobmatchapi/internal/database"
 )
@@ -9,10 +10,12 @@ import (
 // User model helpers
 func DbUserToModelUser(dbUser database.User) User {
 	return User{
-		ID:        dbUser.ID,
-		Email:     dbUser.Email,
-		Role:      dbUser.Role,
-		CreatedAt: dbUser.CreatedAt,
+		ID:               dbUser.ID,
+		Email:            dbUser.Email,
+		Role:             dbUser.Role,
+		CreatedAt:        dbUser.CreatedAt,
+		EmailVerified:    dbUser.EmailVerifiedAt.Valid,
+		TwoFactorEnabled: dbUser.TotpEnabledAt.Valid,
 	}
 
 }
@@ -26,17 +29,68 @@ func DbUsersToModelUsers(dbUsers []database.User) []User {
 }
 
 // Session model helpers
+func DbUserToModelAdminUser(dbUser database.User, planName, subscriptionStatus string) AdminUser {
+	adminUser := AdminUser{
+		User:               DbUserToModelUser(dbUser),
+		PlanName:           planName,
+		SubscriptionStatus: subscriptionStatus,
+		SuspendedReason:    dbUser.SuspendedReason.String,
+	}
+	if dbUser.SuspendedAt.Valid {
+		adminUser.SuspendedAt = &dbUser.SuspendedAt.Time
+	}
+	return adminUser
+}
+
+func DbAdminListUsersRowsToModelAdminUsers(rows []database.AdminListUsersRow) []AdminUser {
+	users := []AdminUser{}
+	for _, row := range rows {
+		users = append(users, DbUserToModelAdminUser(row.User, row.PlanName, row.SubscriptionStatus))
+	}
+	return users
+}
+
+func DbEmployerProfileToModelEmployerProfile(dbProfile database.EmployerProfile) EmployerProfile {
+	return EmployerProfile{
+		ID:              dbProfile.ID,
+		CompanyName:     dbProfile.CompanyName,
+		CompanyWebsite:  dbProfile.CompanyWebsite,
+		CompanySize:     dbProfile.CompanySize,
+		CompanyIndustry: dbProfile.CompanyIndustry,
+		UserID:          dbProfile.UserID,
+	}
+}
+
+func DbJobSeekerProfileToModelJobSeekerProfile(dbProfile database.JobSeekerProfile) JobSeekerProfile {
+	return JobSeekerProfile{
+		ID:        dbProfile.ID,
+		FirstName: dbProfile.FirstName,
+		LastName:  dbProfile.LastName,
+		ResumeUrl: dbProfile.ResumeUrl.String,
+		UserID:    dbProfile.UserID,
+	}
+}
+
 func DbSessionToModelSession(dbSession database.Session) Session {
-	return Session{
+	session := Session{
 		ID:             dbSession.ID,
 		Name:           dbSession.Name,
 		CreatedAt:      dbSession.CreatedAt,
-		UserID:         dbSession.UserID,
 		Status:         dbSession.Status,
 		JobTitle:       dbSession.JobTitle,
 		JobDescription: dbSession.JobDescription,
+		UpdatedAt:      dbSession.UpdatedAt,
 	}
-
+	if dbSession.UserID.Valid {
+		session.UserID = &dbSession.UserID.UUID
+	}
+	if dbSession.OrganizationID.Valid {
+		session.OrganizationID = &dbSession.OrganizationID.UUID
+	}
+	if dbSession.ArchivedAt.Valid {
+		session.ArchivedAt = &dbSession.ArchivedAt.Time
+	}
+	return session
 }
 
 func DbSessionsToModelSessions(dbSessions []database.Session) []Session {
@@ -47,6 +101,132 @@ func DbSessionsToModelSessions(dbSessions []database.Session) []Session {
 	return sessions
 }
 
+// Auth session model helpers
+// Organization model helpers
+func DbOrganizationToModelOrganization(dbOrg database.Organization, role string) Organization {
+	return Organization{
+		ID:        dbOrg.ID,
+		Name:      dbOrg.Name,
+		Role:      role,
+		CreatedAt: dbOrg.CreatedAt,
+		UpdatedAt: dbOrg.UpdatedAt,
+	}
+}
+
+func DbUserOrganizationsToModelOrganizations(rows []database.GetUserOrganizationsRow) []Organization {
+	orgs := []Organization{}
+	for _, row := range rows {
+		orgs = append(orgs, Organization{
+			ID:        row.ID,
+			Name:      row.Name,
+			Role:      row.MemberRole,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+		})
+	}
+	return orgs
+}
+
+func DbOrganizationMemberToModelOrganizationMember(dbMember database.OrganizationMember) OrganizationMember {
+	return OrganizationMember{
+		OrganizationID: dbMember.OrganizationID,
+		UserID:         dbMember.UserID,
+		Role:           dbMember.Role,
+		CreatedAt:      dbMember.CreatedAt,
+	}
+}
+
+func DbOrganizationMembersToModelOrganizationMembers(rows []database.GetOrganizationMembersRow) []OrganizationMember {
+	members := []OrganizationMember{}
+	for _, row := range rows {
+		members = append(members, OrganizationMember{
+			OrganizationID: row.OrganizationID,
+			UserID:         row.UserID,
+			Email:          row.Email,
+			Role:           row.Role,
+			CreatedAt:      row.CreatedAt,
+		})
+	}
+	return members
+}
+
+func DbOrganizationInvitationToModelOrganizationInvitation(dbInvitation database.OrganizationInvitation) OrganizationInvitation {
+	return OrganizationInvitation{
+		ID:             dbInvitation.ID,
+		OrganizationID: dbInvitation.OrganizationID,
+		Email:          dbInvitation.Email,
+		Role:           dbInvitation.Role,
+		ExpiresAt:      dbInvitation.ExpiresAt,
+		CreatedAt:      dbInvitation.CreatedAt,
+	}
+}
+
+func DbOrganizationInvitationsToModelOrganizationInvitations(dbInvitations []database.OrganizationInvitation) []OrganizationInvitation {
+	invitations := []OrganizationInvitation{}
+	for _, dbInvitation := range dbInvitations {
+		invitations = append(invitations, DbOrganizationInvitationToModelOrganizationInvitation(dbInvitation))
+	}
+	return invitations
+}
+
+func DbRefreshTokenToModelAuthSession(dbRefreshToken database.RefreshToken, currentTokenHash string) AuthSession {
+	return AuthSession{
+		ID:         dbRefreshToken.FamilyID,
+		UserAgent:  dbRefreshToken.UserAgent,
+		IPAddress:  dbRefreshToken.IpAddress,
+		CreatedAt:  dbRefreshToken.FamilyCreatedAt,
+		LastUsedAt: dbRefreshToken.LastUsedAt,
+		ExpiresAt:  dbRefreshToken.ExpiresAt,
+		Current:    currentTokenHash != "" && dbRefreshToken.TokenHash == currentTokenHash,
+	}
+}
+
+func DbRefreshTokensToModelAuthSessions(dbRefreshTokens []database.RefreshToken, currentTokenHash string) []AuthSession {
+	authSessions := []AuthSession{}
+	for _, dbRefreshToken := range dbRefreshTokens {
+		authSessions = append(authSessions, DbRefreshTokenToModelAuthSession(dbRefreshToken, currentTokenHash))
+	}
+	return authSessions
+}
+
+// Security event model helpers
+func DbSecurityEventToModelSecurityEvent(dbEvent database.SecurityEvent) SecurityEvent {
+	return SecurityEvent{
+		ID:        dbEvent.ID,
+		EventType: dbEvent.EventType,
+		IPAddress: dbEvent.IpAddress,
+		UserAgent: dbEvent.UserAgent,
+		Details:   dbEvent.Details.String,
+		CreatedAt: dbEvent.CreatedAt,
+	}
+}
+
+func DbSecurityEventsToModelSecurityEvents(dbEvents []database.SecurityEvent) []SecurityEvent {
+	events := []SecurityEvent{}
+	for _, dbEvent := range dbEvents {
+		events = append(events, DbSecurityEventToModelSecurityEvent(dbEvent))
+	}
+	return events
+}
+
+func DbLoginThrottleToModelLoginLock(dbThrottle database.LoginThrottle) LoginLock {
+	return LoginLock{
+		Scope:          dbThrottle.Scope,
+		Key:            dbThrottle.Key,
+		FailedAttempts: dbThrottle.FailedAttempts,
+		LastFailedAt:   dbThrottle.LastFailedAt,
+		LockedUntil:    dbThrottle.LockedUntil.Time,
+	}
+}
+
+func DbLoginThrottlesToModelLoginLocks(dbThrottles []database.LoginThrottle) []LoginLock {
+	locks := []LoginLock{}
+	for _, dbThrottle := range dbThrottles {
+		locks = append(locks, DbLoginThrottleToModelLoginLock(dbThrottle))
+	}
+	return locks
+}
+
 // AnalysesResult model helpers
 func DbAnalysesResultToModelAnalysesResults(dbAnalysesResults database.AnalysesResult) AnalysesResults {
 	results := []AnalysesResult{}
@@ -57,6 +237,7 @@ func DbAnalysesResultToModelAnalysesResults(dbAnalysesResults database.AnalysesR
 		SessionID: dbAnalysesResults.SessionID,
 		CreatedAt: dbAnalysesResults.CreatedAt,
 		UpdatedAt: dbAnalysesResults.UpdatedAt,
+		Stale:     dbAnalysesResults.Stale,
 	}
 
 }
@@ -166,3 +347,147 @@ func DbContactDepartmentsToModelContactDepartments(dbContactDepartments []databa
 	}
 	return contactDepartments
 }
+
+func DbAPIClientToModelAPIClient(dbClient database.ApiClient) APIClient {
+	client := APIClient{
+		ID:             dbClient.ID,
+		Name:           dbClient.Name,
+		KeyPrefix:      dbClient.KeyPrefix,
+		AllowedOrigins: dbClient.AllowedOrigins,
+		Status:         dbClient.Status,
+		CreatedAt:      dbClient.CreatedAt,
+		UpdatedAt:      dbClient.UpdatedAt,
+	}
+	if client.AllowedOrigins == nil {
+		client.AllowedOrigins = []string{}
+	}
+	if dbClient.PreviousKeyExpiresAt.Valid && dbClient.PreviousKeyExpiresAt.Time.After(time.Now()) {
+		client.PreviousKeyExpiresAt = &dbClient.PreviousKeyExpiresAt.Time
+	}
+	if dbClient.LastUsedAt.Valid {
+		client.LastUsedAt = &dbClient.LastUsedAt.Time
+	}
+	if dbClient.RevokedAt.Valid {
+		client.RevokedAt = &dbClient.RevokedAt.Time
+	}
+	return client
+}
+
+func DbAPIClientsToModelAPIClients(dbClients []database.ApiClient) []APIClient {
+	clients := []APIClient{}
+	for _, dbClient := range dbClients {
+		clients = append(clients, DbAPIClientToModelAPIClient(dbClient))
+	}
+	return clients
+}
+
+func DbPersonalAccessTokenToModelPersonalAccessToken(dbToken database.PersonalAccessToken) PersonalAccessToken {
+	token := PersonalAccessToken{
+		ID:          dbToken.ID,
+		Name:        dbToken.Name,
+		TokenPrefix: dbToken.TokenPrefix,
+		Scopes:      dbToken.Scopes,
+		LastUsedIP:  dbToken.LastUsedIp.String,
+		CreatedAt:   dbToken.CreatedAt,
+	}
+	if dbToken.ExpiresAt.Valid {
+		token.ExpiresAt = &dbToken.ExpiresAt.Time
+	}
+	if dbToken.LastUsedAt.Valid {
+		token.LastUsedAt = &dbToken.LastUsedAt.Time
+	}
+	return token
+}
+
+func DbPersonalAccessTokensToModelPersonalAccessTokens(dbTokens []database.PersonalAccessToken) []PersonalAccessToken {
+	tokens := []PersonalAccessToken{}
+	for _, dbToken := range dbTokens {
+		tokens = append(tokens, DbPersonalAccessTokenToModelPersonalAccessToken(dbToken))
+	}
+	return tokens
+}
+
+func DbResumeToModelResumeMetadata(dbResume database.Resume) ResumeMetadata {
+	return ResumeMetadata{
+		ID:               dbResume.ID,
+		SessionID:        dbResume.SessionID,
+		OriginalFilename: dbResume.OriginalFilename,
+		Mime:             dbResume.Mime,
+		SizeBytes:        dbResume.SizeBytes,
+		ObjectKey:        dbResume.ObjectKey,
+		UploadStatus:     dbResume.UploadStatus,
+		CreatedAt:        dbResume.CreatedAt,
+	}
+}
+
+func DbSubscriptionHistoryToModelSubscriptionHistoryEntries(dbHistory []database.SubscriptionHistory) []SubscriptionHistoryEntry {
+	entries := []SubscriptionHistoryEntry{}
+	for _, dbEntry := range dbHistory {
+		entries = append(entries, SubscriptionHistoryEntry{
+			ID:             dbEntry.ID,
+			SubscriptionID: dbEntry.SubscriptionID,
+			EventType:      dbEntry.EventType,
+			OldValue:       dbEntry.OldValue.String,
+			NewValue:       dbEntry.NewValue.String,
+			EventSource:    dbEntry.EventSource,
+			CreatedAt:      dbEntry.CreatedAt,
+		})
+	}
+	return entries
+}
+
+func DbContactMessagesToModelContactMessages(dbMessages []database.ContactMessage) []ContactMessage {
+	messages := []ContactMessage{}
+	for _, dbMessage := range dbMessages {
+		message := ContactMessage{
+			ID:                  dbMessage.ID,
+			FirstName:           dbMessage.FirstName,
+			LastName:            dbMessage.LastName,
+			Email:               dbMessage.Email,
+			ContactDepartmentID: dbMessage.ContactDepartmentID,
+			Message:             dbMessage.Message,
+		}
+		if dbMessage.CreatedAt.Valid {
+			message.CreatedAt = &dbMessage.CreatedAt.Time
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func DbImpersonationAuditLogToModelImpersonationAuditEntries(dbEntries []database.ImpersonationAuditLog) []ImpersonationAuditEntry {
+	entries := []ImpersonationAuditEntry{}
+	for _, dbEntry := range dbEntries {
+		entries = append(entries, ImpersonationAuditEntry{
+			ID:           dbEntry.ID,
+			AdminID:      dbEntry.AdminID,
+			TargetUserID: dbEntry.TargetUserID,
+			TokenID:      dbEntry.TokenID,
+			Method:       dbEntry.Method,
+			Path:         dbEntry.Path,
+			StatusCode:   dbEntry.StatusCode,
+			Reason:       dbEntry.Reason.String,
+			IpAddress:    dbEntry.IpAddress,
+			UserAgent:    dbEntry.UserAgent,
+			CreatedAt:    dbEntry.CreatedAt,
+		})
+	}
+	return entries
+}
+
+func DbSessionStatusHistoryToModelSessionStatusChanges(dbHistory []database.SessionStatusHistory) []SessionStatusChange {
+	changes := []SessionStatusChange{}
+	for _, dbChange := range dbHistory {
+		change := SessionStatusChange{
+			FromStatus: dbChange.FromStatus.String,
+			ToStatus:   dbChange.ToStatus,
+			Reason:     dbChange.Reason,
+			CreatedAt:  dbChange.CreatedAt,
+		}
+		if dbChange.ChangedBy.Valid {
+			change.ChangedBy = &dbChange.ChangedBy.UUID
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
@@ -8,7 +8,11 @@ import (
 	"cloud.google.com/go/pubsub/v2"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
 	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/eventbus"
+	"github.com/muhammadolammi/jobmatchapi/internal/mailer"
+	"github.com/muhammadolammi/jobmatchapi/internal/oidc"
 	"github.com/streadway/amqp"
 )
 
@@ -18,6 +22,7 @@ type Config struct {
 	DBConn *sql.DB
 
 	JwtKey                     string
+	JwtKeys                    *auth.KeySet // signs and verifies every token, falls back to HS256 with JwtKey
 	ClientApiKey               string
 	Port                       string
 	R2                         *R2Config
@@ -32,35 +37,177 @@ type Config struct {
 	PaystackApi                string
 	PaystackSecretKey          string
 
+	Mailer                               mailer.Mailer
+	AppURL                               string // frontend base url used in emailed links
+	PasswordResetTokenExpirationTime     int    //in minute
+	EmailVerificationTokenExpirationTime int    //in minute
+	RequireVerifiedEmail                 bool
+	RevocationStore                      auth.RevocationStore
+	OIDCProviders                        map[string]*oidc.Provider // social login providers by name
+
 	HttpClient *http.Client // this should be used for all internal and external http communication
 	ENV        string
 	// WorkerApi  string
 	ProjectId string
+
+	AnalysisEventBus       string       // driver of AnalysisBus: pubsub, rabbitmq, postgres or memory
+	SessionUpdatesEventBus string       // driver of SessionUpdatesBus
+	AnalysisBus            eventbus.Bus // carries analysis jobs and cancellations to the worker
+	SessionUpdatesBus      eventbus.Bus // carries the worker's progress back to the sse and websocket clients
 }
 
 type EmployerProfile struct {
-	ID              uuid.UUID
-	CompanyName     string
-	CompanyWebsite  string
-	CompanySize     int32
-	CompanyIndustry string
-	UserID          uuid.UUID
+	ID              uuid.UUID `json:"id"`
+	CompanyName     string    `json:"company_name"`
+	CompanyWebsite  string    `json:"company_website"`
+	CompanySize     int32     `json:"company_size"`
+	CompanyIndustry string    `json:"company_industry"`
+	UserID          uuid.UUID `json:"user_id"`
 }
 
 type JobSeekerProfile struct {
-	ID        uuid.UUID
-	FirstName string
-	LastName  string
-	ResumeUrl sql.NullString
-	UserID    uuid.UUID
+	ID        uuid.UUID `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	ResumeUrl string    `json:"resume_url"`
+	UserID    uuid.UUID `json:"user_id"`
 }
 
-type RefreshToken struct {
-	ID        uuid.UUID
-	UserID    uuid.UUID
-	Token     string
-	ExpiresAt time.Time
-	CreatedAt time.Time
+// Profile is the role specific part of an account. Only the section for the user's role is set,
+// admins may have either or none.
+type Profile struct {
+	Role        string            `json:"role"`
+	Employer    *EmployerProfile  `json:"employer,omitempty"`
+	JobSeeker   *JobSeekerProfile `json:"job_seeker,omitempty"`
+	Professions []Profession      `json:"professions,omitempty"`
+}
+
+// AdminUser is a user as the support team sees it in the user list.
+type AdminUser struct {
+	User
+	PlanName           string     `json:"plan_name"`
+	SubscriptionStatus string     `json:"subscription_status"`
+	SuspendedAt        *time.Time `json:"suspended_at"`
+	SuspendedReason    string     `json:"suspended_reason"`
+}
+
+type UserUsage struct {
+	Count      int32     `json:"count"`
+	MaxDaily   int32     `json:"max_daily"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// AdminUserDetail is everything support needs about one user. Missing parts are null.
+type AdminUserDetail struct {
+	User             AdminUser         `json:"user"`
+	EmployerProfile  *EmployerProfile  `json:"employer_profile"`
+	JobSeekerProfile *JobSeekerProfile `json:"job_seeker_profile"`
+	Subscription     *Subscription     `json:"subscription"`
+	Plan             *Plan             `json:"plan"`
+	Usage            *UserUsage        `json:"usage"`
+	SessionsCount    int64             `json:"sessions_count"`
+}
+
+// AuthSession is a device the user is logged in on, backed by a refresh token.
+type AuthSession struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type SecurityEvent struct {
+	ID        uuid.UUID `json:"id"`
+	EventType string    `json:"event_type"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginLock is an email or ip that failed to log in too often.
+type LoginLock struct {
+	Scope          string    `json:"scope"`
+	Key            string    `json:"key"`
+	FailedAttempts int32     `json:"failed_attempts"`
+	LastFailedAt   time.Time `json:"last_failed_at"`
+	LockedUntil    time.Time `json:"locked_until"`
+}
+
+// APIClient is a frontend or integration with its own client-api-key. The key itself is only
+// returned once, when it is created or rotated.
+type APIClient struct {
+	ID                   uuid.UUID  `json:"id"`
+	Name                 string     `json:"name"`
+	KeyPrefix            string     `json:"key_prefix"`
+	AllowedOrigins       []string   `json:"allowed_origins"`
+	Status               string     `json:"status"`
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at,omitempty"`
+	LastUsedAt           *time.Time `json:"last_used_at"`
+	RevokedAt            *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// PersonalAccessToken is a long lived, scoped token for scripts. The token itself is only returned once.
+type PersonalAccessToken struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ResumeMetadata describes an uploaded file, the file itself is in R2.
+type ResumeMetadata struct {
+	ID               uuid.UUID `json:"id"`
+	SessionID        uuid.UUID `json:"session_id"`
+	OriginalFilename string    `json:"original_filename"`
+	Mime             string    `json:"mime"`
+	SizeBytes        int64     `json:"size_bytes"`
+	ObjectKey        string    `json:"object_key"`
+	UploadStatus     string    `json:"upload_status"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type SubscriptionHistoryEntry struct {
+	ID             uuid.UUID `json:"id"`
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	EventType      string    `json:"event_type"`
+	OldValue       string    `json:"old_value"`
+	NewValue       string    `json:"new_value"`
+	EventSource    string    `json:"event_source"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ContactMessage struct {
+	ID                  uuid.UUID  `json:"id"`
+	FirstName           string     `json:"first_name"`
+	LastName            string     `json:"last_name"`
+	Email               string     `json:"email"`
+	ContactDepartmentID uuid.UUID  `json:"contact_department_id"`
+	Message             string     `json:"message"`
+	CreatedAt           *time.Time `json:"created_at"`
+}
+
+type ImpersonationAuditEntry struct {
+	ID           uuid.UUID `json:"id"`
+	AdminID      uuid.UUID `json:"admin_id"`
+	TargetUserID uuid.UUID `json:"target_user_id"`
+	TokenID      string    `json:"token_id"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	StatusCode   int32     `json:"status_code"`
+	Reason       string    `json:"reason,omitempty"`
+	IpAddress    string    `json:"ip_address"`
+	UserAgent    string    `json:"user_agent"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type Resume struct {
@@ -72,20 +219,64 @@ type Resume struct {
 }
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	Email       string    `json:"email"`
-	Role        string    `json:"role"`
-	CreatedAt   time.Time `json:"created_at"`
-	DisplayName string    `json:"display_name"`
+	ID               uuid.UUID `json:"id"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	CreatedAt        time.Time `json:"created_at"`
+	DisplayName      string    `json:"display_name"`
+	EmailVerified    bool      `json:"email_verified"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	// set when an admin is using the account through an impersonation token
+	ImpersonatedBy *uuid.UUID `json:"impersonated_by,omitempty"`
 }
 type Session struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	// the creator, null once they deleted their account
+	UserID         *uuid.UUID `json:"user_id"`
+	Status         string     `json:"status"`
+	JobTitle       string     `json:"job_title"`
+	JobDescription string     `json:"job_description"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	ArchivedAt     *time.Time `json:"archived_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	// only set on GET /api/sessions/{id}
+	StatusHistory []SessionStatusChange `json:"status_history,omitempty"`
+}
+
+type SessionStatusChange struct {
+	FromStatus string     `json:"from_status,omitempty"`
+	ToStatus   string     `json:"to_status"`
+	Reason     string     `json:"reason"`
+	ChangedBy  *uuid.UUID `json:"changed_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Organization is a hiring team sharing sessions. Role is the caller's role in it.
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Email          string    `json:"email,omitempty"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type OrganizationInvitation struct {
 	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	ExpiresAt      time.Time `json:"expires_at"`
 	CreatedAt      time.Time `json:"created_at"`
-	Name           string    `json:"name"`
-	UserID         uuid.UUID `json:"user_id"`
-	Status         string    `json:"status"`
-	JobTitle       string    `json:"job_title"`
-	JobDescription string    `json:"job_description"`
 }
 
 type PresignResponse struct {
@@ -123,6 +314,8 @@ type AnalysesResults struct {
 	CreatedAt time.Time        `json:"created_at"`
 	SessionID uuid.UUID        `json:"session_id"`
 	UpdatedAt time.Time        `json:"updated_at"`
+	// the job description changed after the analysis, run it again for up to date results
+	Stale bool `json:"stale"`
 }
 
 type Plan struct {
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+	"github.com/muhammadolammi/jobmatchapi/internal/oidc"
+)
+
+const (
+	oauthStateExpirationTime       = 10 // in minute
+	oauthSignupTokenExpirationTime = 30 // in minute
+)
+
+func (cfg *Config) GetOAuthProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	providers := []string{}
+	for name := range cfg.OIDCProviders {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	helpers.RespondWithJson(w, http.StatusOK, providers)
+}
+
+// OAuthAuthorizeHandler sends the browser to the provider sign in page.
+func (cfg *Config) OAuthAuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.OIDCProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		helpers.RespondWithError(w, http.StatusNotFound, "unknown login provider")
+		return
+	}
+	state, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error generating state. err: %v", err))
+		return
+	}
+	nonce, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error generating nonce. err: %v", err))
+		return
+	}
+	// 32 random bytes encode to a 43 character pkce verifier
+	codeVerifier, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error generating code verifier. err: %v", err))
+		return
+	}
+	err = cfg.DB.DeleteExpiredOAuthStates(r.Context())
+	if err != nil {
+		log.Println("error deleting expired oauth states. err: ", err)
+	}
+	expiresAt := time.Now().UTC().Add(oauthStateExpirationTime * time.Minute)
+	err = cfg.DB.CreateOAuthState(r.Context(), database.CreateOAuthStateParams{
+		StateHash:    auth.HashToken(state),
+		Provider:     provider.Name(),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error saving oauth state. err: %v", err))
+		return
+	}
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		log.Println(err)
+		helpers.RespondWithError(w, http.StatusBadGateway, "login provider unavailable")
+		return
+	}
+	auth.SetOAuthStateCookie(w, state, expiresAt)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OAuthCallbackHandler is where the provider sends the browser back. Every outcome redirects to the
+// frontend: a started session, a 2fa challenge, a signup to complete or an oauth_error.
+func (cfg *Config) OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.OIDCProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		cfg.redirectOAuthError(w, r, "unknown_provider")
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		cfg.redirectOAuthError(w, r, "provider_denied")
+		return
+	}
+	state := query.Get("state")
+	stateCookie, err := r.Cookie(auth.OAuthStateCookieName)
+	auth.ClearOAuthStateCookie(w)
+	if state == "" || err != nil || subtle.ConstantTimeCompare([]byte(stateCookie.Value), []byte(state)) != 1 {
+		cfg.redirectOAuthError(w, r, "invalid_state")
+		return
+	}
+	storedState, err := cfg.DB.ConsumeOAuthState(r.Context(), auth.HashToken(state))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("error getting oauth state. err: ", err)
+		}
+		cfg.redirectOAuthError(w, r, "invalid_state")
+		return
+	}
+	if storedState.Provider != provider.Name() || storedState.ExpiresAt.Before(time.Now().UTC()) {
+		cfg.redirectOAuthError(w, r, "invalid_state")
+		return
+	}
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), storedState.CodeVerifier, storedState.Nonce)
+	if err != nil {
+		log.Println(err)
+		cfg.redirectOAuthError(w, r, "provider_error")
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+
+	identity, err := cfg.DB.GetUserIdentity(r.Context(), database.GetUserIdentityParams{
+		Provider: provider.Name(),
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		user, err := cfg.DB.GetUser(r.Context(), identity.UserID)
+		if err != nil {
+			log.Println("error getting user of identity. err: ", err)
+			cfg.redirectOAuthError(w, r, "server_error")
+			return
+		}
+		err = cfg.DB.TouchUserIdentity(r.Context(), database.TouchUserIdentityParams{
+			Email: email,
+			ID:    identity.ID,
+		})
+		if err != nil {
+			log.Println("error updating identity. err: ", err)
+		}
+		cfg.finishOAuthLogin(w, r, user)
+		return
+	}
+	if err != sql.ErrNoRows {
+		log.Println("error getting identity. err: ", err)
+		cfg.redirectOAuthError(w, r, "server_error")
+		return
+	}
+
+	// first login with this provider account, only a verified email can be trusted to match a user
+	if email == "" || !claims.EmailVerified {
+		cfg.redirectOAuthError(w, r, "email_not_verified")
+		return
+	}
+	user, err := cfg.DB.GetUserWithEmail(r.Context(), email)
+	if err == sql.ErrNoRows {
+		cfg.redirectOAuthSignup(w, r, provider.Name(), email, claims)
+		return
+	}
+	if err != nil {
+		log.Println("error getting user. err: ", err)
+		cfg.redirectOAuthError(w, r, "server_error")
+		return
+	}
+	user, err = cfg.linkUserIdentity(r, user, provider.Name(), claims.Subject, email)
+	if err != nil {
+		log.Println(err)
+		cfg.redirectOAuthError(w, r, "server_error")
+		return
+	}
+	cfg.finishOAuthLogin(w, r, user)
+}
+
+// linkUserIdentity adds a provider identity to an existing account. If the account email was never
+// verified, whoever set its password may not own the email, so the password and sessions are dropped.
+func (cfg *Config) linkUserIdentity(r *http.Request, user database.User, provider, subject, email string) (database.User, error) {
+	unverified := !user.EmailVerifiedAt.Valid
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return user, fmt.Errorf("error starting transaction. err: %v", err)
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if unverified {
+		err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+			ID:       user.ID,
+			Password: "",
+		})
+		if err != nil {
+			return user, fmt.Errorf("error clearing password. err: %v", err)
+		}
+		err = qtx.DeleteUserRefreshTokens(r.Context(), user.ID)
+		if err != nil {
+			return user, fmt.Errorf("error revoking refresh tokens. err: %v", err)
+		}
+		err = qtx.MarkUserEmailVerified(r.Context(), user.ID)
+		if err != nil {
+			return user, fmt.Errorf("error verifying email. err: %v", err)
+		}
+	}
+	_, err = qtx.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+	if err != nil {
+		return user, fmt.Errorf("error linking identity. err: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return user, fmt.Errorf("error committing identity link. err: %v", err)
+	}
+	if unverified {
+		err = cfg.RevocationStore.RevokeUserTokens(r.Context(), user.ID, time.Now())
+		if err != nil {
+			log.Println("error revoking access tokens after identity link. err: ", err)
+		}
+	}
+	cfg.recordSecurityEvent(r, user.ID, "oidc_identity_linked", fmt.Sprintf("%s account %s was linked", provider, email))
+	return user, nil
+}
+
+// finishOAuthLogin mirrors LoginHandler for a user the provider has authenticated.
+// The frontend gets its access token from /refresh with the new cookie.
+func (cfg *Config) finishOAuthLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	if user.TotpEnabledAt.Valid {
+		challengeToken, err := auth.MakeJwtTokenString(cfg.JwtKeys, user.ID.String(), "2fa_challenge", twoFactorChallengeExpirationTime)
+		if err != nil {
+			log.Println("error creating challenge token. err: ", err)
+			cfg.redirectOAuthError(w, r, "server_error")
+			return
+		}
+		// a fragment never reaches a server log
+		http.Redirect(w, r, cfg.AppURL+"/login/2fa#"+url.Values{"challenge_token": {challengeToken}}.Encode(), http.StatusFound)
+		return
+	}
+	err := cfg.startLoginSession(w, r, user)
+	if err == errAccountSuspended {
+		cfg.redirectOAuthError(w, r, "account_suspended")
+		return
+	}
+	if err != nil {
+		log.Println("error creating refresh token. err: ", err)
+		cfg.redirectOAuthError(w, r, "server_error")
+		return
+	}
+	http.Redirect(w, r, cfg.AppURL+"/auth/callback", http.StatusFound)
+}
+
+func (cfg *Config) redirectOAuthSignup(w http.ResponseWriter, r *http.Request, provider, email string, claims *oidc.Claims) {
+	signupClaims := auth.OIDCSignupClaims{
+		Provider:        provider,
+		ProviderSubject: claims.Subject,
+		Email:           email,
+		FirstName:       claims.GivenName,
+		LastName:        claims.FamilyName,
+	}
+	signupToken, err := auth.MakeOIDCSignupToken(cfg.JwtKeys, signupClaims, oauthSignupTokenExpirationTime)
+	if err != nil {
+		log.Println("error creating signup token. err: ", err)
+		cfg.redirectOAuthError(w, r, "server_error")
+		return
+	}
+	fragment := url.Values{
+		"signup_token": {signupToken},
+		"email":        {email},
+		"first_name":   {claims.GivenName},
+		"last_name":    {claims.FamilyName},
+	}
+	http.Redirect(w, r, cfg.AppURL+"/signup/complete#"+fragment.Encode(), http.StatusFound)
+}
+
+func (cfg *Config) redirectOAuthError(w http.ResponseWriter, r *http.Request, code string) {
+	http.Redirect(w, r, cfg.AppURL+"/login?"+url.Values{"oauth_error": {code}}.Encode(), http.StatusFound)
+}
+
+// OAuthCompleteSignupHandler creates the account for a new provider identity with the same
+// role and profile details RegisterHandler asks for. The account has no password.
+func (cfg *Config) OAuthCompleteSignupHandler(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		SignupToken string `json:"signup_token"`
+		signupDetails
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	claims, err := auth.ParseOIDCSignupToken(cfg.JwtKeys, body.SignupToken)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired signup token")
+		return
+	}
+	if body.FirstName == "" {
+		body.FirstName = claims.FirstName
+	}
+	if body.LastName == "" {
+		body.LastName = claims.LastName
+	}
+	userExist, err := cfg.DB.UserExists(r.Context(), claims.Email)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error validating user. err: %v", err))
+		return
+	}
+	if userExist {
+		helpers.RespondWithError(w, http.StatusBadRequest, "User already exist. Login")
+		return
+	}
+	if code, msg := cfg.validateSignupDetails(r.Context(), body.signupDetails); code != 0 {
+		helpers.RespondWithError(w, code, msg)
+		return
+	}
+
+	user, err := cfg.createOAuthUser(r.Context(), claims, body.Role)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = cfg.createUserProfile(r.Context(), user, body.signupDetails)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cfg.issueLoginTokens(w, r, user)
+}
+
+func (cfg *Config) createOAuthUser(ctx context.Context, claims *auth.OIDCSignupClaims, role string) (database.User, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, fmt.Errorf("error starting transaction. err: %v", err)
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	// an empty password can never match, the user can set one with the forgot password flow
+	user, err := qtx.CreateUser(ctx, database.CreateUserParams{
+		Email:    claims.Email,
+		Password: "",
+		Role:     role,
+	})
+	if err != nil {
+		return user, fmt.Errorf("error creating user. err: %v", err)
+	}
+	err = qtx.MarkUserEmailVerified(ctx, user.ID)
+	if err != nil {
+		return user, fmt.Errorf("error verifying email. err: %v", err)
+	}
+	_, err = qtx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: claims.Provider,
+		Subject:  claims.ProviderSubject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		return user, fmt.Errorf("error linking identity. err: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return user, fmt.Errorf("error committing signup. err: %v", err)
+	}
+	return user, nil
+}
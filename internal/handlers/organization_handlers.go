@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+	"github.com/muhammadolammi/jobmatchapi/internal/mailer"
+)
+
+const organizationInvitationExpiration = 7 * 24 * time.Hour
+
+func (cfg *Config) CreateOrganizationHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Name string `json:"name"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "organization name can't be empty")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	org, err := q.CreateOrganization(r.Context(), database.CreateOrganizationParams{
+		Name:      body.Name,
+		CreatedBy: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating organization. err: %v", err))
+		return
+	}
+	_, err = q.CreateOrganizationMember(r.Context(), database.CreateOrganizationMemberParams{
+		OrganizationID: org.ID,
+		UserID:         user.ID,
+		Role:           orgRoleOwner,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating organization owner. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusCreated, DbOrganizationToModelOrganization(org, orgRoleOwner))
+}
+
+func (cfg *Config) GetOrganizationsHandler(w http.ResponseWriter, r *http.Request, user User) {
+	orgs, err := cfg.DB.GetUserOrganizations(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting organizations. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbUserOrganizationsToModelOrganizations(orgs))
+}
+
+func (cfg *Config) GetOrganizationHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	org, err := cfg.DB.GetOrganization(r.Context(), member.OrganizationID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting organization. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbOrganizationToModelOrganization(org, member.Role))
+}
+
+func (cfg *Config) UpdateOrganizationHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	body := struct {
+		Name string `json:"name"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "organization name can't be empty")
+		return
+	}
+	org, err := cfg.DB.UpdateOrganizationName(r.Context(), database.UpdateOrganizationNameParams{
+		Name: body.Name,
+		ID:   member.OrganizationID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating organization. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbOrganizationToModelOrganization(org, member.Role))
+}
+
+// DeleteOrganizationHandler removes the organization and its memberships. Its sessions are kept
+// and fall back to being personal sessions of whoever created them.
+func (cfg *Config) DeleteOrganizationHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	err := cfg.DB.DeleteOrganization(r.Context(), member.OrganizationID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting organization. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, "organization deleted")
+}
+
+func (cfg *Config) GetOrganizationMembersHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	members, err := cfg.DB.GetOrganizationMembers(r.Context(), member.OrganizationID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting organization members. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbOrganizationMembersToModelOrganizationMembers(members))
+}
+
+func (cfg *Config) UpdateOrganizationMemberRoleHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	targetID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	body := struct {
+		Role string `json:"role"`
+	}{}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if !hasOrgRole(body.Role, allOrgRoles) {
+		helpers.RespondWithError(w, http.StatusBadRequest, "role must be one of owner, recruiter or viewer")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	target, err := q.GetOrganizationMember(r.Context(), database.GetOrganizationMemberParams{
+		OrganizationID: member.OrganizationID,
+		UserID:         targetID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusNotFound, "member not found")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting member. err: %v", err))
+		return
+	}
+	if target.Role == orgRoleOwner && body.Role != orgRoleOwner {
+		if ok := cfg.hasAnotherOwner(r.Context(), q, w, member.OrganizationID); !ok {
+			return
+		}
+	}
+	updated, err := q.UpdateOrganizationMemberRole(r.Context(), database.UpdateOrganizationMemberRoleParams{
+		Role:           body.Role,
+		OrganizationID: member.OrganizationID,
+		UserID:         targetID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating member role. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbOrganizationMemberToModelOrganizationMember(updated))
+}
+
+// RemoveOrganizationMemberHandler lets owners remove anyone and any member remove themselves (leave).
+func (cfg *Config) RemoveOrganizationMemberHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	targetID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if targetID != user.ID && member.Role != orgRoleOwner {
+		helpers.RespondWithError(w, http.StatusForbidden, "Forbidden: insufficient organization permissions")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	target, err := q.GetOrganizationMember(r.Context(), database.GetOrganizationMemberParams{
+		OrganizationID: member.OrganizationID,
+		UserID:         targetID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusNotFound, "member not found")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting member. err: %v", err))
+		return
+	}
+	if target.Role == orgRoleOwner {
+		if ok := cfg.hasAnotherOwner(r.Context(), q, w, member.OrganizationID); !ok {
+			return
+		}
+	}
+	_, err = q.DeleteOrganizationMember(r.Context(), database.DeleteOrganizationMemberParams{
+		OrganizationID: member.OrganizationID,
+		UserID:         targetID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error removing member. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, "member removed")
+}
+
+// hasAnotherOwner responds with an error and returns false when an owner change would leave the organization without one.
+// It locks the owners until q's transaction ends, so two owners can't demote each other at the same time.
+func (cfg *Config) hasAnotherOwner(ctx context.Context, q *database.Queries, w http.ResponseWriter, orgID uuid.UUID) bool {
+	owners, err := q.LockOrganizationOwners(ctx, orgID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error counting owners. err: %v", err))
+		return false
+	}
+	if len(owners) <= 1 {
+		helpers.RespondWithError(w, http.StatusBadRequest, "an organization must have at least one owner")
+		return false
+	}
+	return true
+}
+
+func (cfg *Config) CreateOrganizationInvitationHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	body := struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	body.Email = strings.ToLower(strings.TrimSpace(body.Email))
+	if body.Email == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "email can't be empty")
+		return
+	}
+	if body.Role == "" {
+		body.Role = orgRoleRecruiter
+	}
+	if !hasOrgRole(body.Role, allOrgRoles) {
+		helpers.RespondWithError(w, http.StatusBadRequest, "role must be one of owner, recruiter or viewer")
+		return
+	}
+	org, err := cfg.DB.GetOrganization(r.Context(), member.OrganizationID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting organization. err: %v", err))
+		return
+	}
+	invitee, err := cfg.DB.GetUserWithEmail(r.Context(), body.Email)
+	if err == nil {
+		role, err := cfg.organizationRole(r.Context(), org.ID, invitee.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting membership. err: %v", err))
+			return
+		}
+		if role != "" {
+			helpers.RespondWithError(w, http.StatusBadRequest, "user is already a member of this organization")
+			return
+		}
+	} else if err != sql.ErrNoRows {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	// an expired invitation would otherwise block a new one for the same email
+	err = cfg.DB.DeleteExpiredOrganizationInvitations(r.Context(), org.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error clearing expired invitations. err: %v", err))
+		return
+	}
+
+	token, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error generating invitation token. err: %v", err))
+		return
+	}
+	invitation, err := cfg.DB.CreateOrganizationInvitation(r.Context(), database.CreateOrganizationInvitationParams{
+		OrganizationID: org.ID,
+		Email:          body.Email,
+		Role:           body.Role,
+		TokenHash:      auth.HashToken(token),
+		InvitedBy:      uuid.NullUUID{UUID: user.ID, Valid: true},
+		ExpiresAt:      time.Now().Add(organizationInvitationExpiration),
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			helpers.RespondWithError(w, http.StatusBadRequest, "there is already a pending invitation for this email")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating invitation. err: %v", err))
+		return
+	}
+
+	acceptLink := fmt.Sprintf("%s/invitations/accept?token=%s", cfg.AppURL, token)
+	err = cfg.Mailer.Send(r.Context(), mailer.Message{
+		To:      body.Email,
+		Subject: fmt.Sprintf("You've been invited to join %s on JobMatch", org.Name),
+		Body:    fmt.Sprintf("%s invited you to join %s on JobMatch as a %s.\n\nOpen the link below to accept. It expires in 7 days.\n\n%s\n\nIf you weren't expecting this, you can ignore this mail.", user.Email, org.Name, body.Role, acceptLink),
+	})
+	if err != nil {
+		// the invitation is still listed, the owner can revoke it and invite again
+		log.Printf("error sending invitation mail. err: %v", err)
+		helpers.RespondWithError(w, http.StatusInternalServerError, "error sending invitation mail")
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusCreated, DbOrganizationInvitationToModelOrganizationInvitation(invitation))
+}
+
+func (cfg *Config) GetOrganizationInvitationsHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	invitations, err := cfg.DB.GetPendingOrganizationInvitations(r.Context(), member.OrganizationID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting invitations. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbOrganizationInvitationsToModelOrganizationInvitations(invitations))
+}
+
+func (cfg *Config) DeleteOrganizationInvitationHandler(w http.ResponseWriter, r *http.Request, user User, member OrganizationMember) {
+	invitationID, err := uuid.Parse(chi.URLParam(r, "invitationID"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid invitation id")
+		return
+	}
+	rows, err := cfg.DB.DeleteOrganizationInvitation(r.Context(), database.DeleteOrganizationInvitationParams{
+		ID:             invitationID,
+		OrganizationID: member.OrganizationID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting invitation. err: %v", err))
+		return
+	}
+	if rows == 0 {
+		helpers.RespondWithError(w, http.StatusNotFound, "invitation not found")
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, "invitation revoked")
+}
+
+// AcceptOrganizationInvitationHandler adds the logged in user to the organization. The invitation
+// must have been sent to the user's email so a leaked link can't be used by another account.
+func (cfg *Config) AcceptOrganizationInvitationHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Token string `json:"token"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if body.Token == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "missing invitation token")
+		return
+	}
+	invitation, err := cfg.DB.GetOrganizationInvitationByHash(r.Context(), auth.HashToken(body.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired invitation")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting invitation. err: %v", err))
+		return
+	}
+	if invitation.AcceptedAt.Valid || time.Now().After(invitation.ExpiresAt) || invitation.Email != strings.ToLower(user.Email) {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired invitation")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	rows, err := q.AcceptOrganizationInvitation(r.Context(), invitation.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error accepting invitation. err: %v", err))
+		return
+	}
+	if rows == 0 {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired invitation")
+		return
+	}
+	newMember, err := q.CreateOrganizationMember(r.Context(), database.CreateOrganizationMemberParams{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         user.ID,
+		Role:           invitation.Role,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			helpers.RespondWithError(w, http.StatusBadRequest, "you are already a member of this organization")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error adding member. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbOrganizationMemberToModelOrganizationMember(newMember))
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/eventbus"
+)
+
+const (
+	outboxPollInterval   = time.Second
+	outboxBatchSize      = 20
+	outboxPublishTimeout = 10 * time.Second
+	// how long a relay has a batch to itself, longer than publishing a whole batch can take
+	outboxLease = 5 * time.Minute
+	// a message that failed this many times is marked dead instead of retried
+	outboxMaxAttempts = 10
+	outboxBaseBackoff = 2 * time.Second
+	outboxMaxBackoff  = 10 * time.Minute
+	// published messages are kept this long for debugging
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+var (
+	// outbox state, served with the other expvars at /api/admin/metrics. lag_seconds is the age of the
+	// oldest message still waiting to be published.
+	outboxMetrics   = expvar.NewMap("outbox")
+	outboxLag       = new(expvar.Float)
+	outboxPending   = new(expvar.Int)
+	outboxDead      = new(expvar.Int)
+	outboxPublished = new(expvar.Int)
+	outboxFailures  = new(expvar.Int)
+	outboxSkipped   = new(expvar.Int)
+)
+
+func init() {
+	outboxMetrics.Set("lag_seconds", outboxLag)
+	outboxMetrics.Set("pending", outboxPending)
+	outboxMetrics.Set("dead", outboxDead)
+	outboxMetrics.Set("published", outboxPublished)
+	outboxMetrics.Set("failures", outboxFailures)
+	outboxMetrics.Set("skipped", outboxSkipped)
+}
+
+// enqueueOutbox adds a message about the session to the outbox. Call it with the queries of the
+// transaction making the change, the relay publishes it once that commits.
+func enqueueOutbox(ctx context.Context, q *database.Queries, topic string, sessionID uuid.UUID, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.CreateOutboxMessage(ctx, database.CreateOutboxMessageParams{
+		Topic:      topic,
+		MessageKey: sessionEventKey(sessionID),
+		Payload:    string(data),
+	})
+	return err
+}
+
+// outboxBus returns the bus a topic is published on.
+func (cfg *Config) outboxBus(topic string) eventbus.Publisher {
+	if topic == sessionUpdatesTopic || topic == sessionEventsTopic {
+		return cfg.SessionUpdatesBus
+	}
+	return cfg.AnalysisBus
+}
+
+// outboxBackoff is how long to wait before the next attempt after attempts failures.
+func outboxBackoff(attempts int32) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := int32(1); i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
+
+// RunOutboxRelay publishes the outbox until ctx is done. Every instance runs one, the rows a relay works
+// on are leased to it so each message goes out once, unless a relay dies between publishing it and saving
+// that it did.
+func (cfg *Config) RunOutboxRelay(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// keep going while there are full batches
+		for {
+			n, err := cfg.relayOutboxBatch(ctx)
+			if err != nil {
+				log.Printf("error relaying outbox. err: %v", err)
+				break
+			}
+			if n < outboxBatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+		cfg.updateOutboxMetrics(ctx)
+		if time.Since(lastCleanup) > time.Hour {
+			lastCleanup = time.Now()
+			err := cfg.DB.DeletePublishedOutboxMessagesBefore(ctx, sql.NullTime{Time: time.Now().UTC().Add(-outboxRetention), Valid: true})
+			if err != nil {
+				log.Printf("error deleting published outbox messages. err: %v", err)
+			}
+		}
+	}
+}
+
+// outboxResult is what happened to a leased message, saved once the whole batch is through.
+type outboxResult struct {
+	msg        database.Outbox
+	err        error
+	skipReason string
+}
+
+// relayOutboxBatch publishes the messages that are due and returns how many it tried. The messages are
+// leased in their own statement so no transaction stays open while the bus is slow or down.
+func (cfg *Config) relayOutboxBatch(ctx context.Context) (int, error) {
+	messages, err := cfg.DB.ClaimOutboxMessages(ctx, database.ClaimOutboxMessagesParams{
+		LeasedUntil: time.Now().UTC().Add(outboxLease),
+		BatchSize:   outboxBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	results := []outboxResult{}
+	var relayErr error
+	for _, msg := range messages {
+		if ctx.Err() != nil {
+			// the rest goes out when the lease runs out
+			break
+		}
+		skipReason, err := cfg.outboxSkipReason(ctx, msg)
+		if err != nil {
+			relayErr = err
+			break
+		}
+		if skipReason != "" {
+			results = append(results, outboxResult{msg: msg, skipReason: skipReason})
+			continue
+		}
+		publishCtx, cancel := context.WithTimeout(ctx, outboxPublishTimeout)
+		err = cfg.publishOutboxMessage(publishCtx, msg)
+		cancel()
+		results = append(results, outboxResult{msg: msg, err: err})
+	}
+	// save what was published even when shutting down, otherwise it goes out again
+	dead, err := cfg.saveOutboxResults(context.WithoutCancel(ctx), results)
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range dead {
+		cfg.handleDeadOutboxMessage(ctx, msg)
+	}
+	return len(messages), relayErr
+}
+
+// outboxSkipReason says why a message shouldn't be published anymore, it's empty when it should. An analysis
+// job is only needed while its session waits in the queue, not once it was cancelled or deleted.
+func (cfg *Config) outboxSkipReason(ctx context.Context, msg database.Outbox) (string, error) {
+	if msg.Topic != analysisTopic {
+		return "", nil
+	}
+	sessionID, err := sessionIDFromKey(msg.MessageKey)
+	if err != nil {
+		return "", nil
+	}
+	session, err := cfg.DB.GetSession(ctx, sessionID)
+	if err == sql.ErrNoRows {
+		return "session deleted", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if session.Status != SessionQueued {
+		return "session is " + session.Status, nil
+	}
+	return "", nil
+}
+
+// saveOutboxResults marks the messages published, skipped or failed in one short transaction and returns
+// the ones that failed for the last time.
+func (cfg *Config) saveOutboxResults(ctx context.Context, results []outboxResult) ([]database.Outbox, error) {
+	if len(results) == 0 {
+		return nil, nil
+	}
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	var dead []database.Outbox
+	for _, result := range results {
+		msg := result.msg
+		if result.skipReason != "" {
+			outboxSkipped.Add(1)
+			err := q.SkipOutboxMessage(ctx, database.SkipOutboxMessageParams{
+				ID:        msg.ID,
+				LastError: sql.NullString{String: "skipped, " + result.skipReason, Valid: true},
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if result.err == nil {
+			outboxPublished.Add(1)
+			if err := q.MarkOutboxMessagePublished(ctx, msg.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		outboxFailures.Add(1)
+		attempts := msg.Attempts + 1
+		isDead := attempts >= outboxMaxAttempts
+		log.Printf("error publishing outbox message %d to %s, attempt %d. err: %v", msg.ID, msg.Topic, attempts, result.err)
+		err := q.MarkOutboxMessageFailed(ctx, database.MarkOutboxMessageFailedParams{
+			LastError:   sql.NullString{String: result.err.Error(), Valid: true},
+			AvailableAt: time.Now().UTC().Add(outboxBackoff(attempts)),
+			Dead:        isDead,
+			ID:          msg.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if isDead {
+			dead = append(dead, msg)
+		}
+	}
+	return dead, tx.Commit()
+}
+
+func (cfg *Config) publishOutboxMessage(ctx context.Context, msg database.Outbox) error {
+	bus := cfg.outboxBus(msg.Topic)
+	if bus == nil {
+		return fmt.Errorf("event bus is not ready")
+	}
+	return bus.Publish(ctx, msg.Topic, eventbus.Message{Key: msg.MessageKey, Data: []byte(msg.Payload)})
+}
+
+// handleDeadOutboxMessage fails the session of an analysis job that couldn't be published, so it isn't
+// left queued, and gives the analysis back.
+func (cfg *Config) handleDeadOutboxMessage(ctx context.Context, msg database.Outbox) {
+	log.Printf("outbox message %d to %s failed %d times, giving up on it", msg.ID, msg.Topic, outboxMaxAttempts)
+	if msg.Topic != analysisTopic {
+		return
+	}
+	sessionID, err := sessionIDFromKey(msg.MessageKey)
+	if err != nil {
+		return
+	}
+	session, err := cfg.DB.GetSession(ctx, sessionID)
+	if err != nil || session.Status != SessionQueued {
+		// deleted, cancelled or already picked up some other way
+		return
+	}
+	_, err = cfg.changeSessionStatus(ctx, session, SessionFailed, "error queueing the analysis", uuid.NullUUID{})
+	if err != nil {
+		log.Printf("error marking session %s failed. err: %v", session.ID, err)
+		return
+	}
+	err = cfg.refundAnalysis(ctx, session.ID)
+	if err != nil {
+		log.Printf("error refunding failed analysis of session %s. err: %v", session.ID, err)
+	}
+}
+
+func (cfg *Config) updateOutboxMetrics(ctx context.Context) {
+	stats, err := cfg.DB.GetOutboxStats(ctx)
+	if err != nil {
+		log.Printf("error reading outbox stats. err: %v", err)
+		return
+	}
+	outboxLag.Set(stats.LagSeconds)
+	outboxPending.Set(stats.Pending)
+	outboxDead.Set(stats.Dead)
+}
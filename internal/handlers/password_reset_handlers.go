@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+	"github.com/muhammadolammi/jobmatchapi/internal/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ForgotPasswordHandler emails a single use reset link. It always responds the same way, failures
+// included, so it can't be used to find out which emails have an account.
+func (cfg *Config) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Email string `json:"email"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if body.Email == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "Enter a mail.")
+		return
+	}
+	body.Email = strings.ToLower(strings.TrimSpace(body.Email))
+	response := "If an account exists for this mail, a password reset link has been sent."
+
+	user, err := cfg.DB.GetUserWithEmail(r.Context(), body.Email)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("error getting user for password reset. err: ", err)
+		}
+		helpers.RespondWithJson(w, http.StatusOK, response)
+		return
+	}
+
+	// only the latest link should work
+	err = cfg.DB.InvalidateUserPasswordResetTokens(r.Context(), user.ID)
+	if err != nil {
+		log.Println("error invalidating old password reset tokens. err: ", err)
+		helpers.RespondWithJson(w, http.StatusOK, response)
+		return
+	}
+	token, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		log.Println("error generating password reset token. err: ", err)
+		helpers.RespondWithJson(w, http.StatusOK, response)
+		return
+	}
+	expiresAt := time.Now().UTC().Add(time.Duration(cfg.PasswordResetTokenExpirationTime) * time.Minute)
+	_, err = cfg.DB.CreatePasswordResetToken(r.Context(), database.CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Println("error saving password reset token. err: ", err)
+		helpers.RespondWithJson(w, http.StatusOK, response)
+		return
+	}
+
+	resetLink := fmt.Sprintf("%s/reset-password?token=%s", cfg.AppURL, token)
+	err = cfg.Mailer.Send(r.Context(), mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your JobMatch password",
+		Body: fmt.Sprintf("We received a request to reset your password.\n\nUse the link below to choose a new one. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you didn't request this, you can ignore this mail.",
+			cfg.PasswordResetTokenExpirationTime, resetLink),
+	})
+	if err != nil {
+		log.Println("error sending password reset mail. err: ", err)
+		helpers.RespondWithJson(w, http.StatusOK, response)
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, response)
+}
+
+// ResetPasswordHandler sets a new password from an emailed token and logs the user out everywhere.
+func (cfg *Config) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if body.Token == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "Enter the reset token.")
+		return
+	}
+	if body.NewPassword == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "Enter a new password.")
+		return
+	}
+
+	resetToken, err := cfg.DB.GetPasswordResetTokenByHash(r.Context(), auth.HashToken(body.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired reset token")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting reset token. err: %v", err))
+		return
+	}
+	if resetToken.UsedAt.Valid || resetToken.ExpiresAt.Before(time.Now().UTC()) {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired reset token")
+		return
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), 10)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error hashing password. err: %v", err))
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	// guard against the same token being used twice concurrently
+	rows, err := qtx.MarkPasswordResetTokenUsed(r.Context(), resetToken.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error using reset token. err: %v", err))
+		return
+	}
+	if rows == 0 {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid or expired reset token")
+		return
+	}
+	err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:       resetToken.UserID,
+		Password: string(newHashedPassword),
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating password. err: %v", err))
+		return
+	}
+	// revoke every refresh token so all devices have to log in again
+	err = qtx.DeleteUserRefreshTokens(r.Context(), resetToken.UserID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking refresh tokens. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing password reset. err: %v", err))
+		return
+	}
+	err = cfg.RevocationStore.RevokeUserTokens(r.Context(), resetToken.UserID, time.Now())
+	if err != nil {
+		log.Println("error revoking access tokens after password reset. err: ", err)
+	}
+
+	helpers.RespondWithJson(w, http.StatusOK, "Password Updated")
+}
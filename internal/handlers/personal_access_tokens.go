@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+)
+
+const (
+	personalAccessTokenPrefix    = "jmp_"
+	maxPersonalAccessTokens      = 20
+	maxPersonalAccessTokenExpiry = 365 // in days
+)
+
+// Scopes a personal access token can be given. Routes opt in to tokens with RequireScope in server.go,
+// every other route only accepts login jwts.
+const (
+	ScopeSessionsRead      = "sessions:read"
+	ScopeSessionsWrite     = "sessions:write"
+	ScopeResumesWrite      = "resumes:write"
+	ScopeAnalysisWrite     = "analysis:write"
+	ScopeResultsRead       = "results:read"
+	ScopeOrganizationsRead = "organizations:read"
+)
+
+var personalAccessTokenScopes = []string{
+	ScopeSessionsRead,
+	ScopeSessionsWrite,
+	ScopeResumesWrite,
+	ScopeAnalysisWrite,
+	ScopeResultsRead,
+	ScopeOrganizationsRead,
+}
+
+type requiredScopeContextKey struct{}
+
+// personalAccessTokenContextKey holds the token ClientAuth resolved, so it's only looked up once.
+type personalAccessTokenContextKey struct{}
+
+// RequireScope marks the route as usable with personal access tokens that have the scope.
+// It wraps the route's auth middleware, AuthMiddleware does the check.
+func (cfg *Config) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requiredScopeContextKey{}, scope)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func isPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
+
+// personalAccessTokenUser authenticates a request made with a personal access token. It responds and
+// returns false when the token is invalid or the route doesn't allow the token's scopes.
+func (cfg *Config) personalAccessTokenUser(w http.ResponseWriter, r *http.Request, token string) (database.User, bool) {
+	dbToken, ok := r.Context().Value(personalAccessTokenContextKey{}).(database.PersonalAccessToken)
+	var err error
+	if !ok {
+		dbToken, err = cfg.DB.GetActivePersonalAccessTokenByHash(r.Context(), auth.HashToken(token))
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return database.User{}, false
+		}
+		log.Println("error getting personal access token. err: ", err)
+		helpers.RespondWithError(w, http.StatusInternalServerError, "error validating token")
+		return database.User{}, false
+	}
+	scope, _ := r.Context().Value(requiredScopeContextKey{}).(string)
+	if scope == "" {
+		helpers.RespondWithError(w, http.StatusForbidden, "Personal access tokens can't be used on this route")
+		return database.User{}, false
+	}
+	if !hasScope(dbToken.Scopes, scope) {
+		helpers.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("Token is missing the %s scope", scope))
+		return database.User{}, false
+	}
+	user, err := cfg.DB.GetUser(r.Context(), dbToken.UserID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "User not found")
+		return database.User{}, false
+	}
+	err = cfg.DB.TouchPersonalAccessToken(r.Context(), database.TouchPersonalAccessTokenParams{
+		ID:         dbToken.ID,
+		LastUsedIp: sql.NullString{String: auth.ClientIP(r), Valid: true},
+	})
+	if err != nil {
+		log.Println("error recording personal access token use. err: ", err)
+	}
+	return user, true
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *Config) GetPersonalAccessTokensHandler(w http.ResponseWriter, r *http.Request, user User) {
+	tokens, err := cfg.DB.GetUserPersonalAccessTokens(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting tokens. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbPersonalAccessTokensToModelPersonalAccessTokens(tokens))
+}
+
+// CreatePersonalAccessTokenHandler responds with the token once, only its hash is stored.
+// expires_in_days is optional, tokens without it don't expire.
+func (cfg *Config) CreatePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "token name can't be empty")
+		return
+	}
+	if len(body.Scopes) == 0 {
+		helpers.RespondWithError(w, http.StatusBadRequest, "a token needs at least one scope")
+		return
+	}
+	scopes := []string{}
+	for _, scope := range body.Scopes {
+		if !hasScope(personalAccessTokenScopes, scope) {
+			helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown scope %q, use one of %s", scope, strings.Join(personalAccessTokenScopes, ", ")))
+			return
+		}
+		if !hasScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if body.ExpiresInDays < 0 || body.ExpiresInDays > maxPersonalAccessTokenExpiry {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("expires_in_days must be between 0 and %d", maxPersonalAccessTokenExpiry))
+		return
+	}
+	count, err := cfg.DB.CountUserPersonalAccessTokens(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error counting tokens. err: %v", err))
+		return
+	}
+	if count >= maxPersonalAccessTokens {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("you can have at most %d active tokens, revoke one first", maxPersonalAccessTokens))
+		return
+	}
+
+	random, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error generating token. err: %v", err))
+		return
+	}
+	token := personalAccessTokenPrefix + random
+	expiresAt := sql.NullTime{}
+	if body.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, body.ExpiresInDays), Valid: true}
+	}
+	dbToken, err := cfg.DB.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:      user.ID,
+		Name:        body.Name,
+		TokenHash:   auth.HashToken(token),
+		TokenPrefix: token[:len(personalAccessTokenPrefix)+6],
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error creating token. err: %v", err))
+		return
+	}
+	cfg.recordSecurityEvent(r, user.ID, "personal_access_token_created", body.Name)
+	helpers.RespondWithJson(w, http.StatusCreated, map[string]any{
+		"token":          token,
+		"token_metadata": DbPersonalAccessTokenToModelPersonalAccessToken(dbToken),
+	})
+}
+
+func (cfg *Config) RevokePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request, user User) {
+	tokenID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid token id")
+		return
+	}
+	rows, err := cfg.DB.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error revoking token. err: %v", err))
+		return
+	}
+	if rows == 0 {
+		helpers.RespondWithError(w, http.StatusNotFound, "token not found")
+		return
+	}
+	cfg.recordSecurityEvent(r, user.ID, "personal_access_token_revoked", tokenID.String())
+	helpers.RespondWithJson(w, http.StatusOK, "token revoked")
+}
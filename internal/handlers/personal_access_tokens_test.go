@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+)
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{"granted", []string{ScopeSessionsRead, ScopeResultsRead}, ScopeResultsRead, true},
+		{"missing", []string{ScopeSessionsRead}, ScopeSessionsWrite, false},
+		{"no scopes", nil, ScopeSessionsRead, false},
+		{"prefix isn't enough", []string{"sessions"}, ScopeSessionsRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasScope(tt.scopes, tt.scope); got != tt.want {
+				t.Errorf("hasScope(%v, %q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPersonalAccessToken(t *testing.T) {
+	tests := map[string]bool{
+		"jmp_abc":          true,
+		"eyJhbGciOiJIUzI1": false,
+		"":                 false,
+		"JMP_abc":          false,
+	}
+	for token, want := range tests {
+		if got := isPersonalAccessToken(token); got != want {
+			t.Errorf("isPersonalAccessToken(%q) = %v, want %v", token, got, want)
+		}
+	}
+}
+
+// The scope checks run before the user is loaded, so they don't need a database.
+func TestPersonalAccessTokenUserScopes(t *testing.T) {
+	tests := []struct {
+		name        string
+		routeScope  string
+		tokenScopes []string
+	}{
+		{"route without RequireScope", "", []string{ScopeSessionsRead}},
+		{"token without the scope", ScopeSessionsWrite, []string{ScopeSessionsRead}},
+		{"token without scopes", ScopeResultsRead, nil},
+	}
+	cfg := &Config{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), personalAccessTokenContextKey{}, database.PersonalAccessToken{Scopes: tt.tokenScopes})
+			if tt.routeScope != "" {
+				ctx = context.WithValue(ctx, requiredScopeContextKey{}, tt.routeScope)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/api/sessions", nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			_, ok := cfg.personalAccessTokenUser(w, r, "jmp_token")
+			if ok {
+				t.Fatal("expected the token to be rejected")
+			}
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+)
+
+// getUserProfile returns the profile for the user's role. Admins get whichever profile they have.
+func (cfg *Config) getUserProfile(ctx context.Context, user User) (Profile, error) {
+	profile := Profile{Role: user.Role}
+	if user.Role == "employer" || user.Role == "admin" {
+		employer, err := cfg.DB.GetEmployerProfileByUserID(ctx, user.ID)
+		if err == nil {
+			employerProfile := DbEmployerProfileToModelEmployerProfile(employer)
+			profile.Employer = &employerProfile
+		} else if err != sql.ErrNoRows {
+			return Profile{}, err
+		}
+	}
+	if user.Role == "job_seeker" || user.Role == "admin" {
+		jobSeeker, err := cfg.DB.GetJobSeekerProfileByUserID(ctx, user.ID)
+		if err == nil {
+			jobSeekerProfile := DbJobSeekerProfileToModelJobSeekerProfile(jobSeeker)
+			profile.JobSeeker = &jobSeekerProfile
+		} else if err != sql.ErrNoRows {
+			return Profile{}, err
+		}
+		if profile.JobSeeker != nil {
+			professions, err := cfg.DB.GetUserProfessionDetails(ctx, user.ID)
+			if err != nil {
+				return Profile{}, err
+			}
+			profile.Professions = DbProfessionsToModelProfessions(professions)
+		}
+	}
+	return profile, nil
+}
+
+func (cfg *Config) GetProfileHandler(w http.ResponseWriter, r *http.Request, user User) {
+	profile, err := cfg.getUserProfile(r.Context(), user)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting profile. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, profile)
+}
+
+// UpdateProfileHandler updates the profile for the user's role. Fields left out keep their value,
+// users without a profile yet (e.g. created by an admin) must send all of them.
+func (cfg *Config) UpdateProfileHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		CompanyName     *string  `json:"company_name"`
+		CompanyWebsite  *string  `json:"company_website"`
+		CompanySize     *int32   `json:"company_size"`
+		CompanyIndustry *string  `json:"company_industry"`
+		FirstName       *string  `json:"first_name"`
+		LastName        *string  `json:"last_name"`
+		ProfessionIDs   []string `json:"profession_ids"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+
+	switch user.Role {
+	case "employer":
+		params := database.UpsertEmployerProfileParams{UserID: user.ID}
+		existing, err := cfg.DB.GetEmployerProfileByUserID(r.Context(), user.ID)
+		if err == nil {
+			params.CompanyName = existing.CompanyName
+			params.CompanyWebsite = existing.CompanyWebsite
+			params.CompanySize = existing.CompanySize
+			params.CompanyIndustry = existing.CompanyIndustry
+		} else if err != sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting profile. err: %v", err))
+			return
+		}
+		if body.CompanyName != nil {
+			params.CompanyName = strings.TrimSpace(*body.CompanyName)
+		}
+		if body.CompanyWebsite != nil {
+			params.CompanyWebsite = strings.TrimSpace(*body.CompanyWebsite)
+		}
+		if body.CompanySize != nil {
+			params.CompanySize = *body.CompanySize
+		}
+		if body.CompanyIndustry != nil {
+			params.CompanyIndustry = strings.TrimSpace(*body.CompanyIndustry)
+		}
+		if code, msg := cfg.validateSignupDetails(r.Context(), signupDetails{
+			Role:            user.Role,
+			CompanyName:     params.CompanyName,
+			CompanyWebsite:  params.CompanyWebsite,
+			CompanySize:     params.CompanySize,
+			CompanyIndustry: params.CompanyIndustry,
+		}); code != 0 {
+			helpers.RespondWithError(w, code, msg)
+			return
+		}
+		if params.CompanySize < 0 {
+			helpers.RespondWithError(w, http.StatusBadRequest, "company_size can't be negative")
+			return
+		}
+		taken, err := cfg.DB.EmployerCompanyNameTaken(r.Context(), database.EmployerCompanyNameTakenParams{
+			CompanyName: params.CompanyName,
+			UserID:      user.ID,
+		})
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error checking company name. err: %v", err))
+			return
+		}
+		if taken {
+			helpers.RespondWithError(w, http.StatusConflict, "another employer already uses this company_name")
+			return
+		}
+		taken, err = cfg.DB.EmployerCompanyWebsiteTaken(r.Context(), database.EmployerCompanyWebsiteTakenParams{
+			CompanyWebsite: params.CompanyWebsite,
+			UserID:         user.ID,
+		})
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error checking company website. err: %v", err))
+			return
+		}
+		if taken {
+			helpers.RespondWithError(w, http.StatusConflict, "another employer already uses this company_website")
+			return
+		}
+		_, err = cfg.DB.UpsertEmployerProfile(r.Context(), params)
+		if err != nil {
+			// two updates racing for the same name or website
+			if strings.Contains(err.Error(), "duplicate key") {
+				helpers.RespondWithError(w, http.StatusConflict, "another employer already uses this company_name or company_website")
+				return
+			}
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating profile. err: %v", err))
+			return
+		}
+
+	case "job_seeker":
+		params := database.UpsertJobSeekerProfileParams{UserID: user.ID}
+		existing, err := cfg.DB.GetJobSeekerProfileByUserID(r.Context(), user.ID)
+		if err == nil {
+			params.FirstName = existing.FirstName
+			params.LastName = existing.LastName
+		} else if err != sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting profile. err: %v", err))
+			return
+		}
+		if body.FirstName != nil {
+			params.FirstName = strings.TrimSpace(*body.FirstName)
+		}
+		if body.LastName != nil {
+			params.LastName = strings.TrimSpace(*body.LastName)
+		}
+		if params.FirstName == "" {
+			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the user first name")
+			return
+		}
+		if params.LastName == "" {
+			helpers.RespondWithError(w, http.StatusBadRequest, "Enter the user last name")
+			return
+		}
+		professionIDs := []uuid.UUID{}
+		if body.ProfessionIDs != nil {
+			if len(body.ProfessionIDs) == 0 {
+				helpers.RespondWithError(w, http.StatusBadRequest, "Enter at least one profession_id")
+				return
+			}
+			for _, id := range body.ProfessionIDs {
+				professionID, err := uuid.Parse(id)
+				if err != nil {
+					helpers.RespondWithError(w, http.StatusBadRequest, "error parsing profession_id to uuid. err: "+err.Error())
+					return
+				}
+				exists, err := cfg.DB.ProfessionExists(r.Context(), professionID)
+				if err != nil {
+					helpers.RespondWithError(w, http.StatusInternalServerError, "error validating profession_id. err: "+err.Error())
+					return
+				}
+				if !exists {
+					helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("no profession with id %s exist", professionID))
+					return
+				}
+				professionIDs = append(professionIDs, professionID)
+			}
+		}
+
+		tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+			return
+		}
+		defer tx.Rollback()
+		q := cfg.DB.WithTx(tx)
+		_, err = q.UpsertJobSeekerProfile(r.Context(), params)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating profile. err: %v", err))
+			return
+		}
+		if body.ProfessionIDs != nil {
+			err = q.DeleteUserProfessions(r.Context(), user.ID)
+			if err != nil {
+				helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating professions. err: %v", err))
+				return
+			}
+			seen := map[uuid.UUID]bool{}
+			for _, professionID := range professionIDs {
+				if seen[professionID] {
+					continue
+				}
+				seen[professionID] = true
+				_, err = q.CreateUserProfession(r.Context(), database.CreateUserProfessionParams{
+					UserID:       user.ID,
+					ProfessionID: professionID,
+				})
+				if err != nil {
+					helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating professions. err: %v", err))
+					return
+				}
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
+			return
+		}
+
+	default:
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s accounts don't have an editable profile", user.Role))
+		return
+	}
+
+	profile, err := cfg.getUserProfile(r.Context(), user)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting profile. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, profile)
+}
@@ -3,100 +3,56 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
 
-	"cloud.google.com/go/pubsub/v2"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/eventbus"
 )
 
-// func (apiConfig *Config) PublishSession(session Session, rabbitChan *amqp.Channel) error {
-func (cfg *Config) PublishSession(session Session) error {
-
-	// defer rabbitChan.Close()
-
-	// q, err := rabbitChan.QueueDeclare(
-	// 	"sessions", // queue name
-	// 	true,       // durable
-	// 	false,      // auto-delete
-	// 	false,      // exclusive
-	// 	false,      // no-wait
-	// 	nil,        // arguments
-	// )
-	// if err != nil {
-	// 	return fmt.Errorf("error declating que on channel channel. err: %v", err)
+const (
+	// the worker subscribes to these topics on the analysis bus
+	analysisTopic       = "resume-analysis"
+	analysisCancelTopic = "resume-analysis-cancel"
+	// the worker publishes its progress here on the session updates bus, keyed by session.<id>
+	sessionUpdatesTopic = "session_updates"
+	// the recorder republishes each update here with its session_events id once it's stored
+	sessionEventsTopic = "session_events"
+)
 
-	// }
+func sessionEventKey(sessionID uuid.UUID) string {
+	return fmt.Sprintf("session.%s", sessionID)
+}
 
-	// body, err := json.Marshal(session)
-	// if err != nil {
-	// 	return fmt.Errorf("error marshalling session. err: %v", err)
-	// }
+// analysisJob is the message the worker gets on the analysis topic. Jobs go out through the outbox,
+// see queueSessionAnalysis.
+func analysisJob(sessionID uuid.UUID) map[string]string {
+	return map[string]string{"session_id": sessionID.String()}
+}
 
-	// err = rabbitChan.Publish(
-	// 	"",     // exchange
-	// 	q.Name, // routing key (queue name)
-	// 	false,  // mandatory
-	// 	false,  // immediate
-	// 	amqp.Publishing{
-	// 		ContentType: "application/json",
-	// 		Body:        body,
-	// 	})
-	// if err != nil {
-	// 	return fmt.Errorf("error publishing session. err: %v", err)
+// PublishSessionCancellation tells the worker to stop analysing the session. The worker should check
+// the session status before writing results too, a cancellation can arrive after it finished.
+func (cfg *Config) PublishSessionCancellation(sessionID uuid.UUID) error {
+	return cfg.publishEvent(cfg.AnalysisBus, analysisCancelTopic, sessionID, map[string]string{
+		"session_id": sessionID.String(),
+	})
+}
 
-	// }
-	// // log.Println("session saved to db and published")
-	// return nil
+// publishSessionUpdate sends an event to the session's sse and websocket clients.
+func (cfg *Config) publishSessionUpdate(sessionID uuid.UUID, payload any) error {
+	return cfg.publishEvent(cfg.SessionUpdatesBus, sessionUpdatesTopic, sessionID, payload)
+}
 
-	// lets send a simple http post request to the worker instead of using rabbitmq for now, we can switch to rabbitmq later if needed
-	// workerURL := apiConfig.WorkerApi + "/process-session"
-	// jsonData, err := json.Marshal(session)
-	// if err != nil {
-	// 	return fmt.Errorf("error marshalling session. err: %v", err)
-	// }
-	// req, err := http.NewRequest("POST", workerURL, bytes.NewBuffer(jsonData))
-	// if err != nil {
-	// 	return fmt.Errorf("error creating request to worker. err: %v", err)
-	// }
-	// req.Header.Set("Content-Type", "application/json")
-	// client := apiConfig.HttpClient
-	// resp, err := client.Do(req)
-	// if err != nil {
-	// 	return fmt.Errorf("error sending request to worker. err: %v", err)
-	// }
-	// defer resp.Body.Close()
-	// if resp.StatusCode != http.StatusOK {
-	// 	return fmt.Errorf("worker returned non-200 status: %d", resp.StatusCode)
-	// }
-	// return nil
-	log.Println("Publishing session to Pub/Sub with session ID:", session.ID.String())
-	// using google pub/sub
-	ctx := context.Background()
-	if cfg.PubSubClient == nil {
-		log.Println("PubSub client is not initialized")
-		client, err := pubsub.NewClient(ctx, cfg.ProjectId)
-		if err != nil {
-			log.Println("Failed to reinitialize Pub/Sub client:", err)
-			return err
-		}
-		cfg.PubSubClient = client
-		log.Println("✅ Pub/Sub client reinitialized")
-		return nil
+// publishEvent publishes the json payload about the session on the bus.
+func (cfg *Config) publishEvent(bus eventbus.Publisher, topic string, sessionID uuid.UUID, payload any) error {
+	if bus == nil {
+		return fmt.Errorf("event bus is not ready")
 	}
-	publisher := cfg.PubSubClient.Publisher("resume-analysis")
-	payload := map[string]string{
-		"session_id": session.ID.String(),
-	}
-
-	data, _ := json.Marshal(payload)
-	result := publisher.Publish(ctx, &pubsub.Message{
-		Data: data,
-	})
-
-	id, err := result.Get(ctx)
+	data, err := json.Marshal(payload)
 	if err != nil {
-		// log.Println("Failed to publish message:", err)
 		return err
 	}
-	log.Println("Published job ID:", session.ID.String(), "Message ID:", id)
-	return nil
+	return bus.Publish(context.Background(), topic, eventbus.Message{
+		Key:  sessionEventKey(sessionID),
+		Data: data,
+	})
 }
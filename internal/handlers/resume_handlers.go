@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +11,6 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/muhammadolammi/jobmatchapi/internal/database"
 	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
@@ -40,33 +40,72 @@ func (cfg *Config) AnalyzeHandler(w http.ResponseWriter, r *http.Request, user U
 		helpers.RespondWithError(w, http.StatusInternalServerError, "error parsing uuid. err: "+err.Error())
 		return
 	}
-	session, err := cfg.DB.GetSession(r.Context(), sessionUUid)
-	if err != nil {
-		helpers.RespondWithError(w, http.StatusInternalServerError, "error getting session from db. err: "+err.Error())
+	session, ok := cfg.getAccessibleSession(w, r, user, sessionUUid, SessionWrite)
+	if !ok {
 		return
 	}
-	//  set session status to pending
-	err = cfg.DB.UpdateSessionStatus(r.Context(), database.UpdateSessionStatusParams{
-		ID:     session.ID,
-		Status: "pending",
-	})
+	// the job goes out through the outbox, so it's queued exactly when the status change commits
+	session, err = cfg.queueSessionAnalysis(r.Context(), session, user)
 	if err != nil {
-		helpers.RespondWithError(w, http.StatusInternalServerError, "error updating session status to pending(db error). err: "+err.Error())
+		if err == errUsageLimitReached {
+			// another request used up the limit after AnalyzeRateLimiter checked it
+			usage, usageErr := cfg.DB.GetUserUsage(r.Context(), user.ID)
+			if usageErr != nil {
+				helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error checking usage: %v", usageErr))
+				return
+			}
+			respondUsageLimitReached(w, usage, time.Now())
+			return
+		}
+		if isSessionTransitionError(err) {
+			helpers.RespondWithError(w, http.StatusConflict, fmt.Sprintf("the session can't be analysed now. err: %v", err))
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, "error queueing session. err: "+err.Error())
 		return
 	}
 
-	// publish the session
-	err = cfg.PublishSession(DbSessionToModelSession(session))
+	helpers.RespondWithJson(w, http.StatusOK, "workflow queued")
+}
+
+var errUsageLimitReached = errors.New("daily usage limit reached")
+
+// queueSessionAnalysis counts the analysis against the user's daily usage, moves the session to queued and
+// adds the analysis job to the outbox in one transaction.
+func (cfg *Config) queueSessionAnalysis(ctx context.Context, session database.Session, user User) (database.Session, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
 	if err != nil {
-		helpers.RespondWithError(w, http.StatusInternalServerError, "error queing session. err: "+err.Error())
-		return
+		return database.Session{}, err
 	}
-
-	helpers.RespondWithJson(w, http.StatusOK, "workflow queued")
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	// admins aren't limited
+	if user.Role != "admin" {
+		rows, err := q.ConsumeUserUsage(ctx, database.ConsumeUserUsageParams{
+			UserID:   user.ID,
+			MaxDaily: int32(cfg.RateLimit),
+			Now:      time.Now(),
+		})
+		if err != nil {
+			return database.Session{}, err
+		}
+		if rows == 0 {
+			return database.Session{}, errUsageLimitReached
+		}
+	}
+	session, err = cfg.transitionSessionStatus(ctx, q, session, SessionQueued, "analysis requested", uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		return database.Session{}, err
+	}
+	err = enqueueOutbox(ctx, q, analysisTopic, session.ID, analysisJob(session.ID))
+	if err != nil {
+		return database.Session{}, err
+	}
+	return session, tx.Commit()
 }
 
-func (cfg *Config) PresignUploadHandler(w http.ResponseWriter, r *http.Request, user User) {
-	sessionID := chi.URLParam(r, "id")
+func (cfg *Config) PresignUploadHandler(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	sessionID := session.ID.String()
 	var body struct {
 		Filename string `json:"file_name"`
 		MimeType string `json:"mime_type"`
@@ -90,10 +129,11 @@ func (cfg *Config) PresignUploadHandler(w http.ResponseWriter, r *http.Request,
 	} else {
 		objectKey = fmt.Sprintf("sessions/%s/resume.%s", sessionID, body.MimeType)
 	}
-	client := s3.NewFromConfig(*cfg.AwsConfig, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.R2.AccountID))
-	})
-	presignClient := s3.NewPresignClient(client)
+	if !isSessionObjectKey(sessionID, objectKey) {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid file_name")
+		return
+	}
+	presignClient := s3.NewPresignClient(cfg.r2Client())
 	presignResult, err := presignClient.PresignPutObject(context.TODO(), &s3.PutObjectInput{
 		Bucket:      aws.String(cfg.R2.Bucket),
 		Key:         aws.String(objectKey),
@@ -153,10 +193,29 @@ func (cfg *Config) UploadCompleteHandler(w http.ResponseWriter, r *http.Request,
 		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error parsing uuid. err: %v", err))
 		return
 	}
+	session, ok := cfg.getAccessibleSession(w, r, user, sessionUUid, SessionWrite)
+	if !ok {
+		return
+	}
+	if !isSessionObjectKey(session.ID.String(), body.ObjectKey) {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("object_key must be under %s", sessionObjectPrefix(session.ID.String())))
+		return
+	}
+	if session.Status != SessionUploaded && !canTransitionSession(session.Status, SessionUploaded) {
+		helpers.RespondWithError(w, http.StatusConflict, fmt.Sprintf("resumes can't be added while the session is %s", session.Status))
+		return
+	}
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
 	// If user is job seeker just update the resume for that session and create one if session has no resume
-	resumeExists, _ := cfg.DB.ResumeExists(r.Context(), sessionUUid)
+	resumeExists, _ := q.ResumeExists(r.Context(), sessionUUid)
 	if user.Role == "job_seeker" && resumeExists {
-		err = cfg.DB.UpdateResumeStorageUrlForSession(r.Context(), database.UpdateResumeStorageUrlForSessionParams{
+		err = q.UpdateResumeStorageUrlForSession(r.Context(), database.UpdateResumeStorageUrlForSessionParams{
 			StorageUrl:       body.StorageUrl,
 			ObjectKey:        body.ObjectKey,
 			OriginalFilename: body.Filename,
@@ -164,32 +223,40 @@ func (cfg *Config) UploadCompleteHandler(w http.ResponseWriter, r *http.Request,
 			SizeBytes:        body.Size,
 			StorageProvider:  "r2",
 			UploadStatus:     "uploaded",
+			SessionID:        sessionUUid,
+		})
+	} else {
+		_, err = q.CreateResume(r.Context(), database.CreateResumeParams{
+			SessionID:        sessionUUid,
+			ObjectKey:        body.ObjectKey,
+			OriginalFilename: body.Filename,
+			Mime:             body.MimeType,
+			SizeBytes:        body.Size,
+			StorageProvider:  "r2",
+			StorageUrl:       body.StorageUrl,
+			UploadStatus:     "uploaded",
 		})
-		if err != nil {
-			helpers.RespondWithError(w, http.StatusInternalServerError, "db err: "+err.Error())
-			log.Println(err)
-			return
-		}
-
-		helpers.RespondWithJson(w, http.StatusCreated, "")
-		return
-
 	}
-	_, err = cfg.DB.CreateResume(r.Context(), database.CreateResumeParams{
-		SessionID:        sessionUUid,
-		ObjectKey:        body.ObjectKey,
-		OriginalFilename: body.Filename,
-		Mime:             body.MimeType,
-		SizeBytes:        body.Size,
-		StorageProvider:  "r2",
-		StorageUrl:       body.StorageUrl,
-		UploadStatus:     "uploaded",
-	})
 	if err != nil {
 		helpers.RespondWithError(w, http.StatusInternalServerError, "db err: "+err.Error())
 		log.Println(err)
 		return
 	}
+	if session.Status != SessionUploaded {
+		_, err = cfg.transitionSessionStatus(r.Context(), q, session, SessionUploaded, "resume uploaded", uuid.NullUUID{UUID: user.ID, Valid: true})
+		if err != nil {
+			if isSessionTransitionError(err) {
+				helpers.RespondWithError(w, http.StatusConflict, fmt.Sprintf("resumes can't be added now. err: %v", err))
+				return
+			}
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating session status. err: %v", err))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
+		return
+	}
 
 	helpers.RespondWithJson(w, http.StatusCreated, "")
 }
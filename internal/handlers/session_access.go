@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+)
+
+const (
+	orgRoleOwner     = "owner"
+	orgRoleRecruiter = "recruiter"
+	orgRoleViewer    = "viewer"
+)
+
+var (
+	allOrgRoles = []string{orgRoleOwner, orgRoleRecruiter, orgRoleViewer}
+	// roles that can create sessions, upload resumes and run analyses in an organization
+	orgSessionWriteRoles = []string{orgRoleOwner, orgRoleRecruiter}
+)
+
+// SessionAction is what a route does with a session, checked by canAccessSession.
+type SessionAction int
+
+const (
+	SessionRead SessionAction = iota
+	SessionWrite
+)
+
+type sessionContextKey struct{}
+
+// SessionFromContext returns the session loaded by SessionMiddleware.
+func SessionFromContext(ctx context.Context) (database.Session, bool) {
+	session, ok := ctx.Value(sessionContextKey{}).(database.Session)
+	return session, ok
+}
+
+// canAccessSession is the one place that decides who may use a session. Personal sessions belong
+// to their creator, organization sessions can be read by every member and changed by owners and recruiters.
+// Admins can do anything.
+func (cfg *Config) canAccessSession(ctx context.Context, user User, session database.Session, action SessionAction) (bool, error) {
+	if user.Role == "admin" {
+		return true, nil
+	}
+	if !session.OrganizationID.Valid {
+		return session.UserID.Valid && session.UserID.UUID == user.ID, nil
+	}
+	role, err := cfg.organizationRole(ctx, session.OrganizationID.UUID, user.ID)
+	if err != nil || role == "" {
+		return false, err
+	}
+	if action == SessionRead {
+		return true, nil
+	}
+	return hasOrgRole(role, orgSessionWriteRoles), nil
+}
+
+// organizationRole returns the user's role in the organization, or "" when they aren't a member.
+func (cfg *Config) organizationRole(ctx context.Context, orgID, userID uuid.UUID) (string, error) {
+	member, err := cfg.DB.GetOrganizationMember(ctx, database.GetOrganizationMemberParams{
+		OrganizationID: orgID,
+		UserID:         userID,
+	})
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+func hasOrgRole(role string, allowedRoles []string) bool {
+	for _, allowed := range allowedRoles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// getAccessibleSession loads the session and checks the policy. It responds with a 404 both when the session
+// doesn't exist and when the user can't use it, so session ids can't be probed, and returns false.
+// Routes with the session in the url use SessionMiddleware, this is for the ones that take it in the body.
+func (cfg *Config) getAccessibleSession(w http.ResponseWriter, r *http.Request, user User, sessionID uuid.UUID, action SessionAction) (database.Session, bool) {
+	session, err := cfg.DB.GetSession(r.Context(), sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusNotFound, "session not found")
+			return database.Session{}, false
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting session. err: %v", err))
+		return database.Session{}, false
+	}
+	ok, err := cfg.canAccessSession(r.Context(), user, session, action)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error checking session access. err: %v", err))
+		return database.Session{}, false
+	}
+	if !ok {
+		helpers.RespondWithError(w, http.StatusNotFound, "session not found")
+		return database.Session{}, false
+	}
+	return session, true
+}
+
+// SessionMiddleware authenticates the user, loads the session in the {sessionID} url param once and checks
+// the user may do action on it. The session is passed to next and put in the request context.
+func (cfg *Config) SessionMiddleware(action SessionAction, next func(http.ResponseWriter, *http.Request, User, database.Session)) http.HandlerFunc {
+	return cfg.AuthMiddleware(func(w http.ResponseWriter, r *http.Request, user User) {
+		sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid session id")
+			return
+		}
+		session, ok := cfg.getAccessibleSession(w, r, user, sessionID, action)
+		if !ok {
+			return
+		}
+		ctx := context.WithValue(r.Context(), sessionContextKey{}, session)
+		next(w, r.WithContext(ctx), user, session)
+	})
+}
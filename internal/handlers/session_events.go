@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/eventbus"
+)
+
+const (
+	// every api instance subscribes in this group, so each update is recorded once
+	sessionEventsGroup    = "session_events_recorder"
+	sessionEventsPageSize = 100
+)
+
+// recordedSessionEvent is what the recorder publishes on session_events, the live feed of the sse and
+// websocket clients. ID lets sse clients resume from session_events and skip what they already got.
+type recordedSessionEvent struct {
+	ID      int64  `json:"id"`
+	Payload string `json:"payload"`
+}
+
+// RecordSessionEvents stores every message published on session_updates in session_events and passes it
+// on to session_events, until ctx is done.
+func (cfg *Config) RecordSessionEvents(ctx context.Context) {
+	cfg.SessionUpdatesBus.Subscribe(ctx, sessionUpdatesTopic, sessionEventsGroup, cfg.recordSessionEvent)
+}
+
+// sessionIDFromKey reads the session id from a session_updates message key.
+func sessionIDFromKey(key string) (uuid.UUID, error) {
+	return uuid.Parse(strings.TrimPrefix(key, "session."))
+}
+
+func (cfg *Config) recordSessionEvent(ctx context.Context, msg eventbus.Message) error {
+	sessionID, err := sessionIDFromKey(msg.Key)
+	if err != nil {
+		return fmt.Errorf("dropping session update with key %q", msg.Key)
+	}
+	event, err := cfg.DB.CreateSessionEvent(ctx, database.CreateSessionEventParams{
+		SessionID: sessionID,
+		Payload:   string(msg.Data),
+	})
+	if err != nil {
+		// e.g. the session was deleted, retrying won't help
+		return fmt.Errorf("error recording event of session %s. err: %v", sessionID, err)
+	}
+	// live clients miss the event if this fails, sse clients get it when they reconnect
+	err = cfg.publishEvent(cfg.SessionUpdatesBus, sessionEventsTopic, sessionID, recordedSessionEvent{
+		ID:      event.ID,
+		Payload: event.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("error publishing recorded event of session %s. err: %v", sessionID, err)
+	}
+	return nil
+}
+
+// writeSessionEvent writes the event in the sse format, payloads with line breaks take one data line each.
+func writeSessionEvent(w io.Writer, event database.SessionEvent) {
+	fmt.Fprintf(w, "id: %d\n", event.ID)
+	for _, line := range strings.Split(event.Payload, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
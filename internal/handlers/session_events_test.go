@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+)
+
+func TestWriteSessionEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		event database.SessionEvent
+		want  string
+	}{
+		{"json payload", database.SessionEvent{ID: 7, Payload: `{"status":"processing"}`}, "id: 7\ndata: {\"status\":\"processing\"}\n\n"},
+		{"multi line payload", database.SessionEvent{ID: 8, Payload: "first\nsecond"}, "id: 8\ndata: first\ndata: second\n\n"},
+		{"empty payload", database.SessionEvent{ID: 9}, "id: 9\ndata: \n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeSessionEvent(&buf, tt.event)
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionEventStatus(t *testing.T) {
+	tests := map[string]string{
+		`{"session_id": "x", "status": "cancelled"}`: SessionCancelled,
+		`{"progress": 40}`:                           "",
+		`{"status": 3}`:                              "",
+		`not json`:                                   "",
+	}
+	for payload, want := range tests {
+		if got := sessionEventStatus([]byte(payload)); got != want {
+			t.Errorf("sessionEventStatus(%s) = %q, want %q", payload, got, want)
+		}
+	}
+}
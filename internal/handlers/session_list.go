@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+)
+
+const (
+	sessionsDefaultPageSize = 20
+	sessionsMaxPageSize     = 100
+)
+
+var sessionSorts = []string{"newest", "oldest", "name_asc", "name_desc"}
+
+// sessionCursor is the sort value and id of the last session on a page, sent back as an opaque string.
+type sessionCursor struct {
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func encodeSessionCursor(session database.Session) string {
+	data, _ := json.Marshal(sessionCursor{CreatedAt: session.CreatedAt, Name: session.Name, ID: session.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSessionCursor(value string) (sessionCursor, error) {
+	cursor := sessionCursor{}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// GetSessions lists the user's personal sessions, or an organization's sessions with ?organization_id=.
+// Query params: q (full text search over name, job title and description), status, job_title,
+// created_from, created_to (YYYY-MM-DD or RFC3339), include_archived, sort (newest, oldest, name_asc,
+// name_desc), cursor (next_cursor of the previous page) and page_size.
+// status_counts ignores status so the UI can show a count on every tab.
+func (cfg *Config) GetSessions(w http.ResponseWriter, r *http.Request, user User) {
+	query := r.URL.Query()
+	params := database.ListSessionsParams{
+		UserID:   user.ID,
+		Status:   nullStringParam(query.Get("status")),
+		JobTitle: nullStringParam(query.Get("job_title")),
+		Search:   nullStringParam(query.Get("q")),
+		Sort:     "newest",
+		PageSize: sessionsDefaultPageSize,
+	}
+	if orgIDString := query.Get("organization_id"); orgIDString != "" {
+		orgID, err := uuid.Parse(orgIDString)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid organization_id")
+			return
+		}
+		role, err := cfg.organizationRole(r.Context(), orgID, user.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting membership. err: %v", err))
+			return
+		}
+		if role == "" && user.Role != "admin" {
+			helpers.RespondWithError(w, http.StatusNotFound, "organization not found")
+			return
+		}
+		params.OrganizationID = uuid.NullUUID{UUID: orgID, Valid: true}
+	}
+	var err error
+	params.CreatedFrom, err = nullTimeParam(query.Get("created_from"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid created_from, use YYYY-MM-DD or RFC3339")
+		return
+	}
+	params.CreatedTo, err = nullTimeEndParam(query.Get("created_to"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid created_to, use YYYY-MM-DD or RFC3339")
+		return
+	}
+	if includeArchived := query.Get("include_archived"); includeArchived != "" {
+		params.IncludeArchived, err = strconv.ParseBool(includeArchived)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, "include_archived must be true or false")
+			return
+		}
+	}
+	if params.Status.Valid && !isSessionStatus(params.Status.String) {
+		helpers.RespondWithError(w, http.StatusBadRequest, "status must be draft, uploaded, queued, processing, completed, failed or cancelled")
+		return
+	}
+	if sort := query.Get("sort"); sort != "" {
+		if !slices.Contains(sessionSorts, sort) {
+			helpers.RespondWithError(w, http.StatusBadRequest, "sort must be newest, oldest, name_asc or name_desc")
+			return
+		}
+		params.Sort = sort
+	}
+	if ps := query.Get("page_size"); ps != "" {
+		pageSize, err := strconv.Atoi(ps)
+		if err != nil || pageSize < 1 || pageSize > sessionsMaxPageSize {
+			helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("page_size must be between 1 and %d", sessionsMaxPageSize))
+			return
+		}
+		params.PageSize = int32(pageSize)
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeSessionCursor(value)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		// an empty name is a real sort value, as NULL the row comparison would end the list
+		params.CursorName = sql.NullString{String: cursor.Name, Valid: true}
+	}
+
+	// one extra row tells whether there is a next page
+	pageSize := params.PageSize
+	params.PageSize++
+	sessions, err := cfg.DB.ListSessions(r.Context(), params)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting sessions. err: %v", err))
+		return
+	}
+	nextCursor := ""
+	if len(sessions) > int(pageSize) {
+		sessions = sessions[:pageSize]
+		nextCursor = encodeSessionCursor(sessions[len(sessions)-1])
+	}
+
+	counts, err := cfg.DB.CountSessionsByStatus(r.Context(), database.CountSessionsByStatusParams{
+		OrganizationID:  params.OrganizationID,
+		UserID:          params.UserID,
+		IncludeArchived: params.IncludeArchived,
+		CreatedFrom:     params.CreatedFrom,
+		CreatedTo:       params.CreatedTo,
+		JobTitle:        params.JobTitle,
+		Search:          params.Search,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error counting sessions. err: %v", err))
+		return
+	}
+	statusCounts := map[string]int64{}
+	for _, count := range counts {
+		statusCounts[count.Status] = count.Count
+	}
+
+	response := struct {
+		Sessions     []Session        `json:"sessions"`
+		NextCursor   string           `json:"next_cursor,omitempty"`
+		StatusCounts map[string]int64 `json:"status_counts"`
+	}{
+		Sessions:     DbSessionsToModelSessions(sessions),
+		NextCursor:   nextCursor,
+		StatusCounts: statusCounts,
+	}
+	helpers.RespondWithJson(w, http.StatusOK, response)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+)
+
+// Session states. The worker moves queued sessions to processing and then completed or failed,
+// everything else goes through transitionSessionStatus. The sessions_status_transition trigger
+// enforces the same transitions for every writer.
+const (
+	SessionDraft      = "draft"
+	SessionUploaded   = "uploaded"
+	SessionQueued     = "queued"
+	SessionProcessing = "processing"
+	SessionCompleted  = "completed"
+	SessionFailed     = "failed"
+	SessionCancelled  = "cancelled"
+)
+
+// sessionTransitions lists the states each state can move to. Keep it in sync with
+// check_session_status_transition in sql/schema/036_session_status_trigger.sql.
+var sessionTransitions = map[string][]string{
+	SessionDraft:      {SessionUploaded, SessionCancelled},
+	SessionUploaded:   {SessionQueued, SessionCancelled},
+	SessionQueued:     {SessionProcessing, SessionFailed, SessionCancelled},
+	SessionProcessing: {SessionCompleted, SessionFailed, SessionCancelled},
+	// finished sessions can get more resumes or be analysed again
+	SessionCompleted: {SessionUploaded, SessionQueued},
+	SessionFailed:    {SessionUploaded, SessionQueued},
+	SessionCancelled: {SessionUploaded, SessionQueued},
+}
+
+var (
+	errInvalidSessionTransition = errors.New("invalid session status transition")
+	// the status changed between reading the session and updating it
+	errSessionStatusConflict = errors.New("session status changed, reload the session and try again")
+)
+
+func isSessionStatus(status string) bool {
+	_, ok := sessionTransitions[status]
+	return ok
+}
+
+func canTransitionSession(from, to string) bool {
+	return slices.Contains(sessionTransitions[from], to)
+}
+
+// isSessionFinished reports whether the session won't change until someone acts on it again.
+func isSessionFinished(status string) bool {
+	return status == SessionCompleted || status == SessionFailed || status == SessionCancelled
+}
+
+// transitionSessionStatus moves the session to status. The sessions_status_transition trigger records
+// the change in its history with the reason and changedBy set here, so run it in a transaction.
+// changedBy is null for changes the system makes.
+func (cfg *Config) transitionSessionStatus(ctx context.Context, q *database.Queries, session database.Session, status, reason string, changedBy uuid.NullUUID) (database.Session, error) {
+	if !canTransitionSession(session.Status, status) {
+		return database.Session{}, fmt.Errorf("%w: %s to %s", errInvalidSessionTransition, session.Status, status)
+	}
+	changeContext := database.SetSessionStatusChangeContextParams{Reason: reason}
+	if changedBy.Valid {
+		changeContext.ChangedBy = changedBy.UUID.String()
+	}
+	err := q.SetSessionStatusChangeContext(ctx, changeContext)
+	if err != nil {
+		return database.Session{}, err
+	}
+	updated, err := q.TransitionSessionStatus(ctx, database.TransitionSessionStatusParams{
+		ToStatus:   status,
+		ID:         session.ID,
+		FromStatus: session.Status,
+	})
+	if err == sql.ErrNoRows {
+		return database.Session{}, errSessionStatusConflict
+	}
+	if err != nil {
+		return database.Session{}, err
+	}
+	return updated, nil
+}
+
+// isSessionTransitionError reports whether err is the caller's fault, the handlers respond with a 409.
+func isSessionTransitionError(err error) bool {
+	return errors.Is(err, errInvalidSessionTransition) || errors.Is(err, errSessionStatusConflict)
+}
+
+// changeSessionStatus is transitionSessionStatus in its own transaction.
+func (cfg *Config) changeSessionStatus(ctx context.Context, session database.Session, status, reason string, changedBy uuid.NullUUID) (database.Session, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Session{}, err
+	}
+	defer tx.Rollback()
+	updated, err := cfg.transitionSessionStatus(ctx, cfg.DB.WithTx(tx), session, status, reason, changedBy)
+	if err != nil {
+		return database.Session{}, err
+	}
+	return updated, tx.Commit()
+}
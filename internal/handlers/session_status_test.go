@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestCanTransitionSession(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{SessionDraft, SessionUploaded, true},
+		{SessionDraft, SessionQueued, false},
+		{SessionUploaded, SessionQueued, true},
+		{SessionQueued, SessionProcessing, true},
+		{SessionQueued, SessionCompleted, false},
+		{SessionProcessing, SessionCompleted, true},
+		{SessionProcessing, SessionFailed, true},
+		{SessionCancelled, SessionProcessing, false},
+		{SessionCancelled, SessionCompleted, false},
+		{SessionCancelled, SessionQueued, true},
+		{SessionCompleted, SessionCancelled, false},
+		{SessionCompleted, SessionUploaded, true},
+		{"pending", SessionQueued, false},
+		{SessionDraft, "pending", false},
+	}
+	for _, tt := range tests {
+		if got := canTransitionSession(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransitionSession(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestSessionTransitionsTargetKnownStates(t *testing.T) {
+	for from, targets := range sessionTransitions {
+		for _, to := range targets {
+			if !isSessionStatus(to) {
+				t.Errorf("%s moves to unknown state %q", from, to)
+			}
+			if to == from {
+				t.Errorf("%s lists itself as a transition", from)
+			}
+		}
+	}
+}
+
+func TestIsSessionFinished(t *testing.T) {
+	for status := range sessionTransitions {
+		want := status == SessionCompleted || status == SessionFailed || status == SessionCancelled
+		if got := isSessionFinished(status); got != want {
+			t.Errorf("isSessionFinished(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+// The trigger enforces the transitions for the worker, it has to allow exactly what sessionTransitions does.
+func TestSessionStatusTriggerMatchesTransitions(t *testing.T) {
+	migration, err := os.ReadFile("../../sql/schema/036_session_status_trigger.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs := regexp.MustCompile(`\('(\w+)', '(\w+)'\)`).FindAllStringSubmatch(string(migration), -1)
+	inTrigger := map[[2]string]bool{}
+	for _, pair := range pairs {
+		inTrigger[[2]string{pair[1], pair[2]}] = true
+		if !canTransitionSession(pair[1], pair[2]) {
+			t.Errorf("the trigger allows %s to %s, sessionTransitions doesn't", pair[1], pair[2])
+		}
+	}
+	for from, targets := range sessionTransitions {
+		for _, to := range targets {
+			if !inTrigger[[2]string{from, to}] {
+				t.Errorf("sessionTransitions allows %s to %s, the trigger doesn't", from, to)
+			}
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/eventbus"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	sessionSocketAuthTimeout      = 10 * time.Second
+	sessionSocketHeartbeat        = 20 * time.Second
+	sessionSocketWriteTimeout     = 10 * time.Second
+	sessionSocketMaxSubscriptions = 50
+	// updates a slow socket can fall behind by before they are dropped
+	sessionSocketBuffer = 32
+)
+
+// sessionUpdate is a recorded session event from session_events.
+type sessionUpdate struct {
+	SessionID uuid.UUID
+	EventID   int64
+	Body      []byte
+}
+
+// sessionUpdatesHub fans the instance's one session_events consumer out to the sse streams and websockets
+// subscribed to a session.
+type sessionUpdatesHub struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan sessionUpdate]struct{}
+}
+
+var sessionUpdates = &sessionUpdatesHub{subscribers: map[uuid.UUID]map[chan sessionUpdate]struct{}{}}
+
+func (hub *sessionUpdatesHub) subscribe(sessionID uuid.UUID) chan sessionUpdate {
+	ch := make(chan sessionUpdate, sessionSocketBuffer)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.subscribers[sessionID] == nil {
+		hub.subscribers[sessionID] = map[chan sessionUpdate]struct{}{}
+	}
+	hub.subscribers[sessionID][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe closes ch.
+func (hub *sessionUpdatesHub) unsubscribe(sessionID uuid.UUID, ch chan sessionUpdate) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	delete(hub.subscribers[sessionID], ch)
+	if len(hub.subscribers[sessionID]) == 0 {
+		delete(hub.subscribers, sessionID)
+	}
+	close(ch)
+}
+
+func (hub *sessionUpdatesHub) publish(update sessionUpdate) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for ch := range hub.subscribers[update.SessionID] {
+		select {
+		case ch <- update:
+		default:
+			log.Printf("dropping update of session %s for a slow websocket", update.SessionID)
+		}
+	}
+}
+
+// RunSessionUpdatesHub subscribes to session_events once for the instance and passes the events to the
+// subscribed sse streams and websockets, until ctx is done.
+func (cfg *Config) RunSessionUpdatesHub(ctx context.Context) {
+	cfg.SessionUpdatesBus.Subscribe(ctx, sessionEventsTopic, "", func(ctx context.Context, msg eventbus.Message) error {
+		sessionID, err := sessionIDFromKey(msg.Key)
+		if err != nil {
+			return nil
+		}
+		event := recordedSessionEvent{}
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			return fmt.Errorf("malformed session event. err: %v", err)
+		}
+		sessionUpdates.publish(sessionUpdate{SessionID: sessionID, EventID: event.ID, Body: []byte(event.Payload)})
+		return nil
+	})
+}
+
+// sessionSocketMessage is what the client sends: auth first, then subscribe and unsubscribe.
+type sessionSocketMessage struct {
+	Type      string `json:"type"`
+	Token     string `json:"token,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// sessionSocketEvent is what the server sends. Data is the update as the worker published it.
+type sessionSocketEvent struct {
+	Type      string          `json:"type"`
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
+
+// SessionsWebSocketHandler streams updates of several sessions over one socket. The client authenticates
+// with {"type": "auth", "token": "<access token>"} as its first message, so the token stays out of the url,
+// then sends {"type": "subscribe", "session_id": "..."} and "unsubscribe" as it needs.
+// The server sends a ping every 20 seconds.
+func (cfg *Config) SessionsWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	server := websocket.Server{Handler: cfg.serveSessionsWebSocket}
+	server.ServeHTTP(w, r)
+}
+
+func (cfg *Config) serveSessionsWebSocket(ws *websocket.Conn) {
+	defer ws.Close()
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+	defer cancel()
+	send := func(event sessionSocketEvent) error {
+		ws.SetWriteDeadline(time.Now().Add(sessionSocketWriteTimeout))
+		return websocket.JSON.Send(ws, event)
+	}
+
+	user, ok := cfg.authenticateSessionsWebSocket(ws, send)
+	if !ok {
+		return
+	}
+
+	// a failed write cancels ctx, closing the socket ends the read loop too
+	go func() {
+		<-ctx.Done()
+		ws.Close()
+	}()
+	go func() {
+		ticker := time.NewTicker(sessionSocketHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := send(sessionSocketEvent{Type: "ping"}); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	subscriptions := map[uuid.UUID]chan sessionUpdate{}
+	defer func() {
+		for sessionID, ch := range subscriptions {
+			sessionUpdates.unsubscribe(sessionID, ch)
+		}
+	}()
+	for {
+		msg := sessionSocketMessage{}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+		if msg.Type != "subscribe" && msg.Type != "unsubscribe" {
+			send(sessionSocketEvent{Type: "error", Message: "type must be subscribe or unsubscribe"})
+			continue
+		}
+		sessionID, err := uuid.Parse(msg.SessionID)
+		if err != nil {
+			send(sessionSocketEvent{Type: "error", SessionID: msg.SessionID, Message: "invalid session_id"})
+			continue
+		}
+		switch msg.Type {
+		case "subscribe":
+			if _, ok := subscriptions[sessionID]; ok {
+				continue
+			}
+			if len(subscriptions) >= sessionSocketMaxSubscriptions {
+				send(sessionSocketEvent{Type: "error", SessionID: msg.SessionID, Message: "too many subscriptions on this socket"})
+				continue
+			}
+			session, err := cfg.DB.GetSession(ctx, sessionID)
+			allowed := err == nil
+			if allowed {
+				allowed, err = cfg.canAccessSession(ctx, user, session, SessionRead)
+			}
+			if err != nil || !allowed {
+				// the same answer for missing and foreign sessions, like the http routes
+				send(sessionSocketEvent{Type: "error", SessionID: msg.SessionID, Message: "session not found"})
+				continue
+			}
+			ch := sessionUpdates.subscribe(sessionID)
+			subscriptions[sessionID] = ch
+			go func() {
+				for update := range ch {
+					if err := send(sessionSocketEvent{Type: "update", ID: update.EventID, SessionID: update.SessionID.String(), Data: socketData(update.Body)}); err != nil {
+						cancel()
+					}
+				}
+			}()
+			send(sessionSocketEvent{Type: "subscribed", SessionID: msg.SessionID, Status: session.Status})
+		case "unsubscribe":
+			if ch, ok := subscriptions[sessionID]; ok {
+				sessionUpdates.unsubscribe(sessionID, ch)
+				delete(subscriptions, sessionID)
+			}
+			send(sessionSocketEvent{Type: "unsubscribed", SessionID: msg.SessionID})
+		}
+	}
+}
+
+// authenticateSessionsWebSocket waits for the auth message. Personal access tokens and impersonation
+// tokens aren't accepted, their route checks only run on http routes.
+func (cfg *Config) authenticateSessionsWebSocket(ws *websocket.Conn, send func(sessionSocketEvent) error) (User, bool) {
+	ws.SetReadDeadline(time.Now().Add(sessionSocketAuthTimeout))
+	msg := sessionSocketMessage{}
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		return User{}, false
+	}
+	ws.SetReadDeadline(time.Time{})
+	if msg.Type != "auth" || msg.Token == "" {
+		send(sessionSocketEvent{Type: "error", Message: "authenticate first with {\"type\": \"auth\", \"token\": \"<access token>\"}"})
+		return User{}, false
+	}
+	if isPersonalAccessToken(msg.Token) {
+		send(sessionSocketEvent{Type: "error", Message: "Personal access tokens can't be used on this route"})
+		return User{}, false
+	}
+	user, claims, code, errMsg := cfg.verifyAccessToken(ws.Request().Context(), msg.Token)
+	if code != 0 {
+		send(sessionSocketEvent{Type: "error", Message: errMsg})
+		return User{}, false
+	}
+	if claims.Act != nil {
+		send(sessionSocketEvent{Type: "error", Message: "This action isn't available while impersonating a user"})
+		return User{}, false
+	}
+	if user.SuspendedAt.Valid {
+		send(sessionSocketEvent{Type: "error", Message: "Account suspended"})
+		return User{}, false
+	}
+	send(sessionSocketEvent{Type: "authenticated"})
+	return DbUserToModelUser(user), true
+}
+
+// socketData sends json updates as they are and anything else as a json string.
+func socketData(body []byte) json.RawMessage {
+	if json.Valid(body) {
+		return body
+	}
+	data, _ := json.Marshal(string(body))
+	return data
+}
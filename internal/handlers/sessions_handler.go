@@ -1,14 +1,16 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/muhammadolammi/jobmatchapi/internal/database"
 	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
@@ -19,6 +21,8 @@ func (cfg *Config) CreateSession(w http.ResponseWriter, r *http.Request, user Us
 		Name           string `json:"name"`
 		JobTitle       string `json:"job_title"`
 		JobDescription string `json:"job_description"`
+		// optional, creates a session shared with the organization
+		OrganizationID *uuid.UUID `json:"organization_id"`
 	}{}
 	err := json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
@@ -39,12 +43,42 @@ func (cfg *Config) CreateSession(w http.ResponseWriter, r *http.Request, user Us
 		helpers.RespondWithError(w, http.StatusInternalServerError, "session job_description can't be empty")
 		return
 	}
-	session, err := cfg.DB.CreateSession(r.Context(), database.CreateSessionParams{
-		Name:           body.Name,
-		UserID:         user.ID,
-		JobTitle:       body.JobTitle,
-		JobDescription: body.JobDescription,
-	})
+	if body.OrganizationID != nil {
+		role, err := cfg.organizationRole(r.Context(), *body.OrganizationID, user.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting membership. err: %v", err))
+			return
+		}
+		if !hasOrgRole(role, orgSessionWriteRoles) {
+			helpers.RespondWithError(w, http.StatusForbidden, "Forbidden: only organization owners and recruiters can create sessions")
+			return
+		}
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	var session database.Session
+	if body.OrganizationID != nil {
+		session, err = q.CreateOrganizationSession(r.Context(), database.CreateOrganizationSessionParams{
+			Name:           body.Name,
+			UserID:         uuid.NullUUID{UUID: user.ID, Valid: true},
+			JobTitle:       body.JobTitle,
+			JobDescription: body.JobDescription,
+			OrganizationID: uuid.NullUUID{UUID: *body.OrganizationID, Valid: true},
+		})
+	} else {
+		session, err = q.CreateSession(r.Context(), database.CreateSessionParams{
+			Name:           body.Name,
+			UserID:         uuid.NullUUID{UUID: user.ID, Valid: true},
+			JobTitle:       body.JobTitle,
+			JobDescription: body.JobDescription,
+		})
+	}
 	if err != nil {
 		msg := fmt.Sprintf("error creating session. err: %v", err)
 		log.Println(msg)
@@ -52,23 +86,40 @@ func (cfg *Config) CreateSession(w http.ResponseWriter, r *http.Request, user Us
 		return
 
 	}
-	helpers.RespondWithJson(w, http.StatusOK, DbSessionToModelSession(session))
-}
-
-func (cfg *Config) GetSessions(w http.ResponseWriter, r *http.Request, user User) {
-	sessions, err := cfg.DB.GetUserSessions(r.Context(), user.ID)
+	err = q.CreateSessionStatusHistory(r.Context(), database.CreateSessionStatusHistoryParams{
+		SessionID: session.ID,
+		ToStatus:  session.Status,
+		Reason:    "session created",
+		ChangedBy: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
 	if err != nil {
-		msg := fmt.Sprintf("error getting sessions. err: %v", err)
-		log.Println(msg)
-		helpers.RespondWithError(w, http.StatusInternalServerError, msg)
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error recording session status. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
 		return
-
 	}
-	helpers.RespondWithJson(w, http.StatusOK, DbSessionsToModelSessions(sessions))
+	helpers.RespondWithJson(w, http.StatusOK, DbSessionToModelSession(session))
 }
 
-func (cfg *Config) HandleSessionUpdates(w http.ResponseWriter, r *http.Request, user User) {
-	sessionID := chi.URLParam(r, "id")
+// HandleSessionUpdates replays the session's recorded events and then streams new ones as they are recorded.
+// A reconnecting client sends the last id it got in the Last-Event-ID header (or ?last_event_id=) and only
+// gets what it missed. When the session is finished an end event is sent and the stream closes.
+func (cfg *Config) HandleSessionUpdates(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			helpers.RespondWithError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		lastID = id
+	}
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -79,102 +130,285 @@ func (cfg *Config) HandleSessionUpdates(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	// Consume RabbitMQ queue
-	ch, err := cfg.RabbitConn.Channel()
-	if err != nil {
-		http.Error(w, "Failed to connect to RabbitMQ", http.StatusInternalServerError)
-		return
-	}
-	// Declare topic exchange
-	err = ch.ExchangeDeclare(
-		"session_updates",
-		"topic",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		http.Error(w, "Failed to declare exchange", http.StatusInternalServerError)
-		return
-	}
-	// Create a temporary queue for this SSE connection
-	q, err := ch.QueueDeclare(
-		"",    // empty name = let RabbitMQ generate unique name
-		false, // durable
-		true,  // delete when unused
-		true,  // exclusive
-		false,
-		nil,
-	)
-	if err != nil {
-		http.Error(w, "Failed to declare queue", http.StatusInternalServerError)
-		return
+	// subscribe before replaying so nothing recorded in between is missed, the replay and the live
+	// feed can overlap and ids don't arrive in order, so skip by the ids already sent
+	updates := sessionUpdates.subscribe(session.ID)
+	defer sessionUpdates.unsubscribe(session.ID, updates)
+	sent := map[int64]bool{}
+	send := func(event database.SessionEvent) {
+		if sent[event.ID] {
+			return
+		}
+		sent[event.ID] = true
+		writeSessionEvent(w, event)
+	}
+	end := func(status string) {
+		fmt.Fprintf(w, "event: end\ndata: {\"status\": %q}\n\n", status)
+		flusher.Flush()
 	}
 
-	// Bind queue to this session's routing key
-	routingKey := fmt.Sprintf("session.%s", sessionID)
-	err = ch.QueueBind(q.Name, routingKey, "session_updates", false, nil)
+	ctx := r.Context()
+	// read the status before replaying, a session that was finished by then has recorded all its events
+	current, err := cfg.DB.GetSession(ctx, session.ID)
 	if err != nil {
-		http.Error(w, "Failed to bind queue", http.StatusInternalServerError)
+		log.Printf("error getting session %s status. err: %v", session.ID, err)
 		return
 	}
-
-	msgs, err := ch.Consume(
-		q.Name,
-		"",
-		true,  // auto-ack
-		false, // exclusive
-		false, // no-local
-		false, // no-wait
-		nil,
-	)
-	if err != nil {
-		http.Error(w, "Failed to consume queue", http.StatusInternalServerError)
+	for {
+		events, err := cfg.DB.GetSessionEventsAfter(ctx, database.GetSessionEventsAfterParams{
+			SessionID: session.ID,
+			AfterID:   lastID,
+			PageSize:  sessionEventsPageSize,
+		})
+		if err != nil {
+			log.Printf("error getting events of session %s. err: %v", session.ID, err)
+			return
+		}
+		for _, event := range events {
+			send(event)
+			lastID = event.ID
+		}
+		if len(events) < sessionEventsPageSize {
+			break
+		}
+	}
+	flusher.Flush()
+	if isSessionFinished(current.Status) {
+		end(current.Status)
 		return
 	}
 
-	// log.Println("logging sse")
-	ctx := r.Context()
 	ticker := time.NewTicker(20 * time.Second)
 	defer ticker.Stop()
+	finishedAtLastCheck := false
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			send(database.SessionEvent{ID: update.EventID, SessionID: session.ID, Payload: string(update.Body)})
+			flusher.Flush()
+			if status := sessionEventStatus(update.Body); isSessionFinished(status) {
+				end(status)
+				return
+			}
 		case <-ticker.C: // NEW: Send a heartbeat comment
 			fmt.Fprintf(w, ":keep-alive\n\n")
 			flusher.Flush()
-		case d := <-msgs:
-			// log.Println(string(d.Body))
-			fmt.Fprintf(w, "data: %s\n\n", d.Body)
-			flusher.Flush()
-			if strings.Contains(string(d.Body), "completed") {
+			// in case the final update was lost, end once the session has been finished for a whole
+			// heartbeat, its last update has arrived by then
+			current, err := cfg.DB.GetSession(ctx, session.ID)
+			if err != nil {
+				log.Printf("error getting session %s status. err: %v", session.ID, err)
 				return
 			}
+			finished := isSessionFinished(current.Status)
+			if finished && finishedAtLastCheck {
+				end(current.Status)
+				return
+			}
+			finishedAtLastCheck = finished
 		}
 	}
 }
 
-func (cfg *Config) GetSession(w http.ResponseWriter, r *http.Request, user User) {
-	sessionIDString := chi.URLParam(r, "id")
-	sessionID, err := uuid.Parse(sessionIDString)
+// sessionEventStatus reads the status from an update shaped like {"status": "..."}, it's empty otherwise.
+func sessionEventStatus(payload []byte) string {
+	update := struct {
+		Status string `json:"status"`
+	}{}
+	json.Unmarshal(payload, &update)
+	return update.Status
+}
+
+// GetSession responds with the session and its status history, oldest change first.
+func (cfg *Config) GetSession(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	history, err := cfg.DB.GetSessionStatusHistory(r.Context(), session.ID)
 	if err != nil {
-		msg := fmt.Sprintf("error parsing session id. err: %v", err)
-		log.Println(msg)
-		helpers.RespondWithError(w, http.StatusInternalServerError, msg)
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting session status history. err: %v", err))
 		return
+	}
+	modelSession := DbSessionToModelSession(session)
+	modelSession.StatusHistory = DbSessionStatusHistoryToModelSessionStatusChanges(history)
+	helpers.RespondWithJson(w, http.StatusOK, modelSession)
+}
 
+// UpdateSessionHandler renames a session or changes its job. Fields left out keep their value.
+// Changing the job of an analysed session marks its results stale.
+func (cfg *Config) UpdateSessionHandler(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	body := struct {
+		Name           *string `json:"name"`
+		JobTitle       *string `json:"job_title"`
+		JobDescription *string `json:"job_description"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	params := database.UpdateSessionDetailsParams{
+		Name:           session.Name,
+		JobTitle:       session.JobTitle,
+		JobDescription: session.JobDescription,
+		ID:             session.ID,
 	}
+	if body.Name != nil {
+		params.Name = strings.TrimSpace(*body.Name)
+		if params.Name == "" {
+			helpers.RespondWithError(w, http.StatusBadRequest, "session name can't be empty")
+			return
+		}
+	}
+	if body.JobTitle != nil {
+		params.JobTitle = strings.TrimSpace(*body.JobTitle)
+		if params.JobTitle == "" {
+			helpers.RespondWithError(w, http.StatusBadRequest, "session job_title can't be empty")
+			return
+		}
+	}
+	if body.JobDescription != nil {
+		params.JobDescription = strings.TrimSpace(*body.JobDescription)
+		if params.JobDescription == "" {
+			helpers.RespondWithError(w, http.StatusBadRequest, "session job_description can't be empty")
+			return
+		}
+	}
+	jobChanged := params.JobTitle != session.JobTitle || params.JobDescription != session.JobDescription
 
-	session, err := cfg.DB.GetSession(r.Context(), sessionID)
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
 	if err != nil {
-		msg := fmt.Sprintf("error getting session. err: %v", err)
-		log.Println(msg)
-		helpers.RespondWithError(w, http.StatusInternalServerError, msg)
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.DB.WithTx(tx)
+	updated, err := q.UpdateSessionDetails(r.Context(), params)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			helpers.RespondWithError(w, http.StatusConflict, "the session creator already has a session with this name")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating session. err: %v", err))
 		return
+	}
+	if jobChanged && session.Status == SessionCompleted {
+		err = q.MarkAnalysesResultsStale(r.Context(), session.ID)
+		if err != nil {
+			helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error marking results stale. err: %v", err))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing transaction. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbSessionToModelSession(updated))
+}
 
+// DeleteSessionHandler deletes the session with its uploads. Resumes and results go with the session row.
+func (cfg *Config) DeleteSessionHandler(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	// files first, if this fails the session is still there and the delete can be retried
+	err := cfg.deleteR2Prefix(r.Context(), sessionObjectPrefix(session.ID.String()))
+	if err != nil {
+		log.Printf("error deleting uploads of session %s. err: %v", session.ID, err)
+		helpers.RespondWithError(w, http.StatusInternalServerError, "error deleting the session files, try again later")
+		return
 	}
-	helpers.RespondWithJson(w, http.StatusOK, DbSessionToModelSession(session))
+	err = cfg.DB.DeleteSession(r.Context(), session.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting session. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, "session deleted")
+}
+
+func (cfg *Config) ArchiveSessionHandler(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	cfg.setSessionArchived(w, r, session, true)
+}
+
+func (cfg *Config) UnarchiveSessionHandler(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	cfg.setSessionArchived(w, r, session, false)
+}
+
+func (cfg *Config) setSessionArchived(w http.ResponseWriter, r *http.Request, session database.Session, archived bool) {
+	updated, err := cfg.DB.SetSessionArchived(r.Context(), database.SetSessionArchivedParams{
+		Archived: archived,
+		ID:       session.ID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating session. err: %v", err))
+		return
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbSessionToModelSession(updated))
+}
+
+// CancelSessionHandler stops the session's analysis. The worker gets a cancellation message, sse clients
+// get a final cancelled event, and the analysis is given back to the user who started it when it
+// produced no results.
+func (cfg *Config) CancelSessionHandler(w http.ResponseWriter, r *http.Request, user User, session database.Session) {
+	inFlight := session.Status == SessionQueued || session.Status == SessionProcessing
+	cancelled, err := cfg.changeSessionStatus(r.Context(), session, SessionCancelled, "cancelled by user", uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		if isSessionTransitionError(err) {
+			helpers.RespondWithError(w, http.StatusConflict, fmt.Sprintf("the session can't be cancelled. err: %v", err))
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error cancelling session. err: %v", err))
+		return
+	}
+	if inFlight {
+		// the session is already cancelled, failures below are logged so the user isn't told to retry
+		err = cfg.PublishSessionCancellation(session.ID)
+		if err != nil {
+			log.Printf("error publishing cancellation of session %s. err: %v", session.ID, err)
+		}
+		err = cfg.publishSessionUpdate(session.ID, map[string]string{
+			"session_id": session.ID.String(),
+			"status":     SessionCancelled,
+		})
+		if err != nil {
+			log.Printf("error sending cancelled event of session %s. err: %v", session.ID, err)
+		}
+		err = cfg.refundAnalysis(r.Context(), session.ID)
+		if err != nil {
+			log.Printf("error refunding cancelled analysis of session %s. err: %v", session.ID, err)
+		}
+	}
+	helpers.RespondWithJson(w, http.StatusOK, DbSessionToModelSession(cancelled))
+}
+
+// refundAnalysis gives the analysis back to whoever queued it, unless results were already written.
+func (cfg *Config) refundAnalysis(ctx context.Context, sessionID uuid.UUID) error {
+	queued, err := cfg.DB.GetLatestSessionStatusChangeTo(ctx, database.GetLatestSessionStatusChangeToParams{
+		SessionID: sessionID,
+		ToStatus:  SessionQueued,
+	})
+	if err == sql.ErrNoRows || (err == nil && !queued.ChangedBy.Valid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	produced, err := cfg.DB.AnalysesResultsWrittenSince(ctx, database.AnalysesResultsWrittenSinceParams{
+		SessionID: sessionID,
+		UpdatedAt: queued.CreatedAt,
+	})
+	if err != nil || produced {
+		return err
+	}
+	analyser, err := cfg.DB.GetUser(ctx, queued.ChangedBy.UUID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// queueSessionAnalysis doesn't count admin analyses
+	if analyser.Role == "admin" {
+		return nil
+	}
+	return cfg.DB.RefundUserUsage(ctx, analyser.ID)
 }
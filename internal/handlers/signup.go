@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+)
+
+// signupDetails are the role specific fields every new account needs, whether it signs up
+// with a password or through an oidc provider.
+type signupDetails struct {
+	Role            string `json:"role"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	CompanyName     string `json:"company_name"`
+	CompanyWebsite  string `json:"company_website"`
+	CompanySize     int32  `json:"company_size"`
+	CompanyIndustry string `json:"company_industry"`
+	ProfessionID    string `json:"profession_id"`
+}
+
+// validateSignupDetails returns the status code and message to respond with, or 0 when the details are valid.
+func (cfg *Config) validateSignupDetails(ctx context.Context, d signupDetails) (int, string) {
+	// avoid admin sign up early
+	if d.Role == "admin" {
+		return http.StatusUnauthorized, "Unauthorized signup"
+	}
+	// Validate role and role in enum
+	if d.Role == "" {
+		return http.StatusBadRequest, "Enter the user role."
+	}
+	if d.Role != "employer" && d.Role != "job_seeker" {
+		return http.StatusBadRequest, "User  role must be one of (employer, job_seeker"
+	}
+	//  Validate payload base on role.
+	if d.Role == "employer" {
+		if d.CompanyName == "" {
+			return http.StatusBadRequest, "Enter the employer company_name"
+		}
+		if d.CompanyIndustry == "" {
+			return http.StatusBadRequest, "Enter the employer company_industry"
+		}
+		if d.CompanySize == 0 {
+			return http.StatusBadRequest, "Enter the employer company_size"
+		}
+		if d.CompanyWebsite == "" {
+			return http.StatusBadRequest, "Enter the employer company_website"
+		}
+	}
+	if d.Role == "job_seeker" {
+		if d.FirstName == "" {
+			return http.StatusBadRequest, "Enter the user first name"
+		}
+		if d.LastName == "" {
+			return http.StatusBadRequest, "Enter the user last name"
+		}
+		if d.ProfessionID == "" {
+			return http.StatusBadRequest, "Enter the user profession_id"
+		}
+		professionIDUUID, err := uuid.Parse(d.ProfessionID)
+		if err != nil {
+			return http.StatusBadRequest, "error parsing profession_id to uuid. err: " + err.Error()
+		}
+		professionExist, err := cfg.DB.ProfessionExists(ctx, professionIDUUID)
+		if err != nil {
+			return http.StatusInternalServerError, "error validating profession_id. err: " + err.Error()
+		}
+		if !professionExist {
+			return http.StatusBadRequest, "no profession with this id exist"
+		}
+	}
+	return 0, ""
+}
+
+// createUserProfile creates the employer or job seeker details of a new user.
+// The details must have passed validateSignupDetails.
+func (cfg *Config) createUserProfile(ctx context.Context, user database.User, d signupDetails) error {
+	if user.Role == "employer" {
+		_, err := cfg.DB.CreateEmployer(ctx, database.CreateEmployerParams{
+			UserID:          user.ID,
+			CompanyName:     d.CompanyName,
+			CompanyWebsite:  d.CompanyWebsite,
+			CompanySize:     d.CompanySize,
+			CompanyIndustry: d.CompanyIndustry,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating employer details, kindly update your details with PUT /api/profile. err: %v", err)
+		}
+	}
+	if user.Role == "job_seeker" {
+		_, err := cfg.DB.CreateJobSeeker(ctx, database.CreateJobSeekerParams{
+			UserID:    user.ID,
+			LastName:  d.LastName,
+			FirstName: d.FirstName,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating job seeker details, kindly update your details with PUT /api/profile. err: %v", err)
+		}
+		_, err = cfg.DB.CreateUserProfession(ctx, database.CreateUserProfessionParams{
+			UserID:       user.ID,
+			ProfessionID: uuid.MustParse(d.ProfessionID),
+		})
+		if err != nil {
+			return fmt.Errorf("error creating user profession, kindly update your details with PUT /api/profile. err: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+)
+
+func (cfg *Config) r2Client() *s3.Client {
+	return s3.NewFromConfig(*cfg.AwsConfig, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.R2.AccountID))
+	})
+}
+
+// sessionObjectPrefix is where every upload of a session is stored.
+func sessionObjectPrefix(sessionID string) string {
+	return fmt.Sprintf("sessions/%s/", sessionID)
+}
+
+// isSessionObjectKey reports whether key is an object of the session. Keys come from clients, anything
+// else could be another user's file.
+func isSessionObjectKey(sessionID, key string) bool {
+	name, ok := strings.CutPrefix(key, sessionObjectPrefix(sessionID))
+	if !ok || name == "" {
+		return false
+	}
+	return !slices.Contains(strings.Split(name, "/"), "..")
+}
+
+// openR2Object returns the object body, the caller must close it.
+func (cfg *Config) openR2Object(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := cfg.r2Client().GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(cfg.R2.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// deleteR2Prefix deletes every object under the prefix, including files that never made it into the resumes table.
+func (cfg *Config) deleteR2Prefix(ctx context.Context, prefix string) error {
+	client := cfg.r2Client()
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(cfg.R2.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("error listing %s. err: %v", prefix, err)
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: object.Key})
+		}
+		out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(cfg.R2.Bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting objects under %s. err: %v", prefix, err)
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("error deleting %s. err: %s", aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+// deleteUserSessionUploads deletes the uploaded files of the user's personal sessions before the account goes,
+// organization sessions stay with the organization.
+func (cfg *Config) deleteUserSessionUploads(ctx context.Context, userID uuid.UUID) error {
+	sessions, err := cfg.DB.GetUserSessions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error getting sessions of user %s. err: %v", userID, err)
+	}
+	for _, session := range sessions {
+		if session.OrganizationID.Valid {
+			continue
+		}
+		err = cfg.deleteR2Prefix(ctx, sessionObjectPrefix(session.ID.String()))
+		if err != nil {
+			return fmt.Errorf("error deleting uploads of session %s. err: %v", session.ID, err)
+		}
+	}
+	return nil
+}
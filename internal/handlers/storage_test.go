@@ -0,0 +1,28 @@
+package handlers
+
+import "testing"
+
+func TestIsSessionObjectKey(t *testing.T) {
+	const session = "3f1c2a3e-0000-4000-8000-000000000001"
+	tests := []struct {
+		name string
+		key  string
+		want bool
+	}{
+		{"resume of the session", "sessions/" + session + "/resume.pdf", true},
+		{"nested file", "sessions/" + session + "/batch/cv.pdf", true},
+		{"prefix only", "sessions/" + session + "/", false},
+		{"other session", "sessions/3f1c2a3e-0000-4000-8000-000000000002/resume.pdf", false},
+		{"session id prefix", "sessions/" + session + "0/resume.pdf", false},
+		{"parent segment", "sessions/" + session + "/../other/resume.pdf", false},
+		{"outside sessions", "exports/resume.pdf", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSessionObjectKey(session, tt.key); got != tt.want {
+				t.Errorf("isSessionObjectKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -317,3 +318,35 @@ func (cfg *Config) PaystackWebhook(w http.ResponseWriter, r *http.Request) {
 	helpers.RespondWithJson(w, http.StatusOK, "")
 
 }
+
+// cancelPaystackSubscription stops paystack from charging the user again. Paystack needs the
+// subscription email token to disable it, so it is fetched first.
+func (cfg *Config) cancelPaystackSubscription(ctx context.Context, sub database.Subscription) error {
+	if !sub.PaystackSubCode.Valid || sub.PaystackSubCode.String == "" {
+		// never activated on paystack, nothing to cancel there
+		return nil
+	}
+	res, err := helpers.CallPaystack(cfg.PaystackApi+"/subscription/"+sub.PaystackSubCode.String, "GET", cfg.PaystackSecretKey, nil, cfg.HttpClient)
+	if err != nil {
+		return fmt.Errorf("error getting paystack subscription. err: %v", err)
+	}
+	data := struct {
+		Status     string `json:"status"`
+		EmailToken string `json:"email_token"`
+	}{}
+	err = json.Unmarshal(res.Data, &data)
+	if err != nil {
+		return fmt.Errorf("error decoding paystack subscription. err: %v", err)
+	}
+	if data.Status == "cancelled" || data.Status == "complete" {
+		return nil
+	}
+	_, err = helpers.CallPaystack(cfg.PaystackApi+"/subscription/disable", "POST", cfg.PaystackSecretKey, map[string]string{
+		"code":  sub.PaystackSubCode.String,
+		"token": data.EmailToken,
+	}, cfg.HttpClient)
+	if err != nil {
+		return fmt.Errorf("error disabling paystack subscription. err: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
+	"github.com/muhammadolammi/jobmatchapi/internal/database"
+	"github.com/muhammadolammi/jobmatchapi/internal/helpers"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer = "JobMatch"
+	// minutes a login challenge stays valid
+	twoFactorChallengeExpirationTime = 5
+	recoveryCodeCount                = 10
+)
+
+// EnrollTwoFactorHandler creates a new secret. 2fa stays off until the first code is confirmed.
+func (cfg *Config) EnrollTwoFactorHandler(w http.ResponseWriter, r *http.Request, user User) {
+	if user.TwoFactorEnabled {
+		helpers.RespondWithError(w, http.StatusBadRequest, "two factor authentication is already enabled")
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error generating secret. err: %v", err))
+		return
+	}
+	err = cfg.DB.SetUserTOTPSecret(r.Context(), database.SetUserTOTPSecretParams{
+		TotpSecret: sql.NullString{Valid: true, String: secret},
+		ID:         user.ID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error saving secret. err: %v", err))
+		return
+	}
+	response := struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	}
+	helpers.RespondWithJson(w, http.StatusOK, response)
+}
+
+// EnableTwoFactorHandler confirms the enrolment with a code and returns the recovery codes.
+// This is the only time the recovery codes are shown.
+func (cfg *Config) EnableTwoFactorHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Code string `json:"code"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if user.TwoFactorEnabled {
+		helpers.RespondWithError(w, http.StatusBadRequest, "two factor authentication is already enabled")
+		return
+	}
+	dbUser, err := cfg.DB.GetUser(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	if !dbUser.TotpSecret.Valid {
+		helpers.RespondWithError(w, http.StatusBadRequest, "start two factor enrolment first")
+		return
+	}
+	step, ok := auth.ValidateTOTP(dbUser.TotpSecret.String, body.Code, time.Now(), dbUser.TotpLastUsedStep)
+	if !ok {
+		helpers.RespondWithError(w, http.StatusBadRequest, "invalid code")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	err = qtx.EnableUserTOTP(r.Context(), database.EnableUserTOTPParams{
+		TotpLastUsedStep: step,
+		ID:               user.ID,
+	})
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error enabling two factor authentication. err: %v", err))
+		return
+	}
+	codes, err := replaceRecoveryCodes(r.Context(), qtx, user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing two factor enrolment. err: %v", err))
+		return
+	}
+	cfg.recordSecurityEvent(r, user.ID, "two_factor_enabled", "")
+
+	response := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	}
+	helpers.RespondWithJson(w, http.StatusOK, response)
+}
+
+// DisableTwoFactorHandler needs the password and a current code or recovery code.
+func (cfg *Config) DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if !user.TwoFactorEnabled {
+		helpers.RespondWithError(w, http.StatusBadRequest, "two factor authentication is not enabled")
+		return
+	}
+	dbUser, err := cfg.DB.GetUser(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(body.Password)) != nil {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "Wrong password.")
+		return
+	}
+	ok, err := cfg.verifySecondFactor(r.Context(), dbUser, body.Code)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "invalid code")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	err = qtx.DisableUserTOTP(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error disabling two factor authentication. err: %v", err))
+		return
+	}
+	err = qtx.DeleteUserTwoFactorRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error deleting recovery codes. err: %v", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing two factor removal. err: %v", err))
+		return
+	}
+	cfg.recordSecurityEvent(r, user.ID, "two_factor_disabled", "")
+	helpers.RespondWithJson(w, http.StatusOK, "two factor authentication disabled")
+}
+
+// RegenerateRecoveryCodesHandler replaces all recovery codes after checking a current code.
+func (cfg *Config) RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request, user User) {
+	body := struct {
+		Code string `json:"code"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if !user.TwoFactorEnabled {
+		helpers.RespondWithError(w, http.StatusBadRequest, "two factor authentication is not enabled")
+		return
+	}
+	dbUser, err := cfg.DB.GetUser(r.Context(), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	ok, err := cfg.verifySecondFactor(r.Context(), dbUser, body.Code)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "invalid code")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error starting transaction. err: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	codes, err := replaceRecoveryCodes(r.Context(), cfg.DB.WithTx(tx), user.ID)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error committing recovery codes. err: %v", err))
+		return
+	}
+	response := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	}
+	helpers.RespondWithJson(w, http.StatusOK, response)
+}
+
+// LoginTwoFactorHandler is the second login step. It exchanges the challenge from LoginHandler
+// and a code for the usual access and refresh tokens.
+func (cfg *Config) LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body. err: %v", err))
+		return
+	}
+	if body.ChallengeToken == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "missing challenge token")
+		return
+	}
+	if body.Code == "" {
+		helpers.RespondWithError(w, http.StatusBadRequest, "Enter a code.")
+		return
+	}
+	claims := &jwt.RegisteredClaims{}
+	_, err = cfg.JwtKeys.Parse(body.ChallengeToken, claims, jwt.WithSubject("2fa_challenge"))
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "invalid or expired challenge, log in again")
+		return
+	}
+	userId, err := uuid.Parse(claims.Issuer)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "invalid or expired challenge, log in again")
+		return
+	}
+	user, err := cfg.DB.GetUser(r.Context(), userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			helpers.RespondWithError(w, http.StatusUnauthorized, "invalid or expired challenge, log in again")
+			return
+		}
+		helpers.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error getting user. err: %v", err))
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		helpers.RespondWithError(w, http.StatusUnauthorized, "invalid or expired challenge, log in again")
+		return
+	}
+	// wrong codes count towards the same lockout as wrong passwords
+	if !cfg.checkLoginThrottle(w, r, user.Email) {
+		return
+	}
+	ok, err := cfg.verifySecondFactor(r.Context(), user, body.Code)
+	if err != nil {
+		helpers.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		cfg.recordFailedLogin(r, user.Email, &user.ID)
+		helpers.RespondWithError(w, http.StatusUnauthorized, "invalid code")
+		return
+	}
+	cfg.clearLoginFailures(r.Context(), user.Email)
+	cfg.issueLoginTokens(w, r, user)
+}
+
+// verifySecondFactor accepts a totp code or an unused recovery code. Both are single use.
+func (cfg *Config) verifySecondFactor(ctx context.Context, user database.User, code string) (bool, error) {
+	if !user.TotpSecret.Valid {
+		return false, nil
+	}
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now(), user.TotpLastUsedStep)
+	if ok {
+		// only one request can move the step forward, so a code can't be used twice concurrently
+		rows, err := cfg.DB.UseUserTOTPStep(ctx, database.UseUserTOTPStepParams{
+			TotpLastUsedStep: step,
+			ID:               user.ID,
+		})
+		if err != nil {
+			return false, fmt.Errorf("error using totp code. err: %v", err)
+		}
+		return rows > 0, nil
+	}
+	rows, err := cfg.DB.UseTwoFactorRecoveryCode(ctx, database.UseTwoFactorRecoveryCodeParams{
+		UserID:   user.ID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+	})
+	if err != nil {
+		return false, fmt.Errorf("error using recovery code. err: %v", err)
+	}
+	return rows > 0, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userId uuid.UUID) ([]string, error) {
+	err := q.DeleteUserTwoFactorRecoveryCodes(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting recovery codes. err: %v", err)
+	}
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, fmt.Errorf("error generating recovery codes. err: %v", err)
+	}
+	for _, code := range codes {
+		err = q.CreateTwoFactorRecoveryCode(ctx, database.CreateTwoFactorRecoveryCodeParams{
+			UserID:   userId,
+			CodeHash: auth.HashToken(code),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error saving recovery codes. err: %v", err)
+		}
+	}
+	return codes, nil
+}
+
+func (cfg *Config) recordSecurityEvent(r *http.Request, userId uuid.UUID, eventType, details string) {
+	_, err := cfg.DB.CreateSecurityEvent(r.Context(), database.CreateSecurityEventParams{
+		UserID:    userId,
+		EventType: eventType,
+		IpAddress: auth.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Details: sql.NullString{
+			Valid:  details != "",
+			String: details,
+		},
+	})
+	if err != nil {
+		log.Println("error recording security event. err: ", err)
+	}
+}
@@ -18,12 +18,17 @@ type PaystackResponse struct {
 }
 
 func CallPaystack(url, method, secret string, payload any, client *http.Client) (*PaystackResponse, error) {
-	payloadb, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("error marshalling payload: %v", err)
+	// GET requests have no payload
+	var reqBody io.Reader
+	if payload != nil {
+		payloadb, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling payload: %v", err)
+		}
+		reqBody = bytes.NewBuffer(payloadb)
 	}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(payloadb))
+	req, err := http.NewRequest(method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
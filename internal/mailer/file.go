@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer writes every message to an .eml file in Dir. Useful for local testing.
+type FileMailer struct {
+	From string
+	Dir  string
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating mail dir. err: %v", err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.NewString())
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, formatMessage(m.From, msg), 0o644); err != nil {
+		return fmt.Errorf("error writing mail file. err: %v", err)
+	}
+	log.Println("mail written to", path)
+	return nil
+}
+
+// LogMailer only logs the message. It is the default when no driver is configured.
+type LogMailer struct {
+	From string
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("mail from: %s to: %s subject: %s\n%s", m.From, msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional emails (password resets, verification links...).
+// Drivers are picked with MAIL_DRIVER so the flows can be exercised offline.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Config struct {
+	Driver   string // smtp, file or log
+	From     string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Dir      string // output directory for the file driver
+}
+
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.Host == "" || cfg.Port == "" {
+			return nil, fmt.Errorf("smtp mailer needs SMTP_HOST and SMTP_PORT")
+		}
+		// MAIL_FROM is a header value like "JobMatch <no-reply@...>", the envelope only takes the address
+		from, err := mail.ParseAddress(cfg.From)
+		if err != nil {
+			return nil, fmt.Errorf("smtp mailer needs a valid MAIL_FROM. err: %v", err)
+		}
+		return &SMTPMailer{
+			From:         cfg.From,
+			EnvelopeFrom: from.Address,
+			Host:         cfg.Host,
+			Port:         cfg.Port,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+		}, nil
+	case "file":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = "mails"
+		}
+		return &FileMailer{From: cfg.From, Dir: dir}, nil
+	case "log", "":
+		return &LogMailer{From: cfg.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
+
+func formatMessage(from string, msg Message) []byte {
+	return []byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\n%s\r\n",
+		from, msg.To, msg.Subject, msg.Body))
+}
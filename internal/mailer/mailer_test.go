@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{"default logs", Config{}, "*mailer.LogMailer", false},
+		{"log", Config{Driver: "log"}, "*mailer.LogMailer", false},
+		{"file", Config{Driver: "file"}, "*mailer.FileMailer", false},
+		{"smtp", Config{Driver: "smtp", From: "no-reply@gojobmatch.com", Host: "localhost", Port: "1025"}, "*mailer.SMTPMailer", false},
+		{"smtp without host", Config{Driver: "smtp", From: "no-reply@gojobmatch.com", Port: "1025"}, "", true},
+		{"smtp without from", Config{Driver: "smtp", Host: "localhost", Port: "1025"}, "", true},
+		{"smtp invalid from", Config{Driver: "smtp", From: "JobMatch <no-reply>", Host: "localhost", Port: "1025"}, "", true},
+		{"unknown driver", Config{Driver: "sendgrid"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := fmt.Sprintf("%T", m); got != tt.want {
+					t.Errorf("built %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewFileMailerDefaultDir(t *testing.T) {
+	m, err := New(Config{Driver: "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := m.(*FileMailer).Dir; dir != "mails" {
+		t.Errorf("dir = %q, want mails", dir)
+	}
+}
+
+func TestNewSMTPMailerEnvelopeFrom(t *testing.T) {
+	m, err := New(Config{Driver: "smtp", From: "JobMatch <no-reply@gojobmatch.com>", Host: "localhost", Port: "1025"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smtpMailer := m.(*SMTPMailer)
+	if smtpMailer.EnvelopeFrom != "no-reply@gojobmatch.com" {
+		t.Errorf("envelope from = %q, want no-reply@gojobmatch.com", smtpMailer.EnvelopeFrom)
+	}
+	if smtpMailer.From != "JobMatch <no-reply@gojobmatch.com>" {
+		t.Errorf("from = %q, want the full MAIL_FROM value", smtpMailer.From)
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	got := string(formatMessage("JobMatch <no-reply@gojobmatch.com>", Message{To: "a@b.c", Subject: "Hi", Body: "Body"}))
+	for _, want := range []string{"From: JobMatch <no-reply@gojobmatch.com>\r\n", "To: a@b.c\r\n", "Subject: Hi\r\n", "\r\n\r\nBody\r\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message %q is missing %q", got, want)
+		}
+	}
+}
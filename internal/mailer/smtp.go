@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+type SMTPMailer struct {
+	From         string // the From header
+	EnvelopeFrom string // the bare address sent in MAIL FROM
+	Host         string
+	Port         string
+	Username     string
+	Password     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, m.Port)
+
+	// net/smtp has no context support, so we run it in a goroutine and give up when ctx is done.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(addr, auth, m.EnvelopeFrom, []string{msg.To}, formatMessage(m.From, msg))
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("error sending mail via smtp. err: %v", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,239 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE against any
+// provider that publishes a discovery document, e.g. Google, LinkedIn or a local mock issuer.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// KnownIssuers lets a provider be configured with just its client credentials.
+var KnownIssuers = map[string]string{
+	"google":   "https://accounts.google.com",
+	"linkedin": "https://www.linkedin.com/oauth",
+}
+
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+type ProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Claims are the id token claims we use. email_verified is a string on some providers.
+type Claims struct {
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	GivenName     string   `json:"given_name"`
+	FamilyName    string   `json:"family_name"`
+	Nonce         string   `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	*b = flexBool(s == "true")
+	return nil
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is safe for concurrent use. Discovery and keys are fetched lazily and cached,
+// so a provider being down doesn't stop the api from starting.
+type Provider struct {
+	cfg    ProviderConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *discoveryDocument
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+func NewProvider(cfg ProviderConfig, client *http.Client) (*Provider, error) {
+	if cfg.IssuerURL == "" {
+		cfg.IssuerURL = KnownIssuers[cfg.Name]
+	}
+	if cfg.IssuerURL == "" {
+		return nil, fmt.Errorf("no issuer url for oidc provider %s", cfg.Name)
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("no client id for oidc provider %s", cfg.Name)
+	}
+	if cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("no redirect url for oidc provider %s", cfg.Name)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = DefaultScopes
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Provider{cfg: cfg, client: client}, nil
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	doc := &discoveryDocument{}
+	err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.IssuerURL, "/")+"/.well-known/openid-configuration", doc)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s discovery document. err: %v", p.cfg.Name, err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.cfg.IssuerURL, "/") {
+		return nil, fmt.Errorf("%s discovery document is for issuer %s", p.cfg.Name, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%s discovery document is missing endpoints", p.cfg.Name)
+	}
+	p.discovery = doc
+	return doc, nil
+}
+
+func (p *Provider) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  doc.AuthorizationEndpoint,
+			TokenURL: doc.TokenEndpoint,
+		},
+	}, nil
+}
+
+// AuthCodeURL is where the user is sent to sign in. The verifier and nonce must be kept
+// server side until the callback.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	conf, err := p.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(codeVerifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	), nil
+}
+
+// Exchange trades the callback code for tokens and returns the verified id token claims.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	conf, err := p.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging %s code. err: %v", p.cfg.Name, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%s returned no id token", p.cfg.Name)
+	}
+	return p.VerifyIDToken(ctx, rawIDToken, nonce)
+}
+
+// VerifyIDToken checks the signature against the provider jwks, then issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.getKey(ctx, doc.JWKSURI, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s id token. err: %v", p.cfg.Name, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%s id token has no subject", p.cfg.Name)
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("id token nonce doesn't match")
+	}
+	return claims, nil
+}
+
+// getKey returns the key for kid, refetching the jwks at most once a minute when the kid is unknown
+// so provider key rotation is picked up.
+func (p *Provider) getKey(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < time.Minute {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	jwks := &jsonWebKeySet{}
+	err := p.getJSON(ctx, jwksURI, jwks)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s jwks. err: %v", p.cfg.Name, err)
+	}
+	p.keys = jwks.publicKeys()
+	p.keysFetchedAt = time.Now()
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
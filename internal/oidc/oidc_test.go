@@ -0,0 +1,387 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "jobmatch-client"
+	testNonce    = "test-nonce"
+)
+
+// mockIssuer is a local OIDC provider serving discovery, jwks and a token endpoint.
+type mockIssuer struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	keys        map[string]crypto.Signer
+	jwksFetches int
+	idToken     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	m := &mockIssuer{keys: map[string]crypto.Signer{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.jwksFetches++
+		jwks := jsonWebKeySet{Keys: []jsonWebKey{}}
+		for kid, key := range m.keys {
+			jwks.Keys = append(jwks.Keys, publicJWK(kid, key.Public()))
+		}
+		json.NewEncoder(w).Encode(jwks)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || r.Form.Get("code_verifier") == "" {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     m.idToken,
+		})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func publicJWK(kid string, key crypto.PublicKey) jsonWebKey {
+	enc := base64.RawURLEncoding
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig", N: enc.EncodeToString(k.N.Bytes()), E: enc.EncodeToString(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return jsonWebKey{Kty: "EC", Kid: kid, Use: "sig", Crv: "P-256", X: enc.EncodeToString(k.X.Bytes()), Y: enc.EncodeToString(k.Y.Bytes())}
+	}
+	panic("unsupported key")
+}
+
+func (m *mockIssuer) addKey(t *testing.T, kid string, ec bool) {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	if ec {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[kid] = key
+}
+
+func (m *mockIssuer) fetches() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jwksFetches
+}
+
+// validClaims are claims VerifyIDToken accepts, tests change one thing at a time.
+func (m *mockIssuer) validClaims() *Claims {
+	now := time.Now()
+	return &Claims{
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		GivenName:     "Ada",
+		FamilyName:    "Lovelace",
+		Nonce:         testNonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.server.URL,
+			Subject:   "user-123",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func (m *mockIssuer) sign(t *testing.T, kid string, claims jwt.Claims) string {
+	t.Helper()
+	m.mu.Lock()
+	key := m.keys[kid]
+	m.mu.Unlock()
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (m *mockIssuer) provider(t *testing.T) *Provider {
+	t.Helper()
+	p, err := NewProvider(ProviderConfig{
+		Name:        "mock",
+		IssuerURL:   m.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/api/auth/oidc/mock/callback",
+	}, m.server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ProviderConfig
+		wantErr bool
+	}{
+		{"known issuer", ProviderConfig{Name: "google", ClientID: "id", RedirectURL: "http://x"}, false},
+		{"unknown issuer", ProviderConfig{Name: "acme", ClientID: "id", RedirectURL: "http://x"}, true},
+		{"no client id", ProviderConfig{Name: "google", RedirectURL: "http://x"}, true},
+		{"no redirect url", ProviderConfig{Name: "google", ClientID: "id"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProvider(tt.cfg, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(p.cfg.Scopes) != len(DefaultScopes) {
+				t.Errorf("scopes = %v, want the defaults", p.cfg.Scopes)
+			}
+		})
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider(t)
+	raw, err := p.AuthCodeURL(context.Background(), "state-1", testNonce, "verifier-verifier-verifier-verifier-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.server.URL+"/authorize" {
+		t.Errorf("auth url = %s, want the discovered authorization endpoint", got)
+	}
+	q := u.Query()
+	want := map[string]string{
+		"client_id":             testClientID,
+		"state":                 "state-1",
+		"nonce":                 testNonce,
+		"code_challenge_method": "S256",
+		"scope":                 "openid email profile",
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+	if q.Get("code_challenge") == "" {
+		t.Error("no pkce code challenge")
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockIssuer(t)
+	p, err := NewProvider(ProviderConfig{
+		Name:        "mock",
+		IssuerURL:   m.server.URL + "/other",
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost",
+	}, m.server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the discovery document lives under the configured issuer, serve it there with the real issuer
+	m.server.Config.Handler.(*http.ServeMux).HandleFunc("/other/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	if _, err := p.AuthCodeURL(context.Background(), "state", testNonce, "verifier"); err == nil {
+		t.Error("discovery document for another issuer accepted")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	m := newMockIssuer(t)
+	m.addKey(t, "rsa-1", false)
+	p := m.provider(t)
+	m.idToken = m.sign(t, "rsa-1", m.validClaims())
+
+	claims, err := p.Exchange(context.Background(), "good-code", "verifier", testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "ada@example.com" || !bool(claims.EmailVerified) {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := p.Exchange(context.Background(), "bad-code", "verifier", testNonce); err == nil {
+		t.Error("rejected code accepted")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	m := newMockIssuer(t)
+	m.addKey(t, "rsa-1", false)
+	m.addKey(t, "ec-1", true)
+	p := m.provider(t)
+
+	tests := []struct {
+		name    string
+		kid     string
+		change  func(c *Claims)
+		wantErr bool
+	}{
+		{"rsa key", "rsa-1", func(c *Claims) {}, false},
+		{"ec key", "ec-1", func(c *Claims) {}, false},
+		{"within leeway", "rsa-1", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Second)) }, false},
+		{"bad issuer", "rsa-1", func(c *Claims) { c.Issuer = "https://evil.example.com" }, true},
+		{"bad audience", "rsa-1", func(c *Claims) { c.Audience = jwt.ClaimStrings{"someone-else"} }, true},
+		{"bad nonce", "rsa-1", func(c *Claims) { c.Nonce = "other-nonce" }, true},
+		{"no nonce", "rsa-1", func(c *Claims) { c.Nonce = "" }, true},
+		{"expired", "rsa-1", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }, true},
+		{"no expiry", "rsa-1", func(c *Claims) { c.ExpiresAt = nil }, true},
+		{"no subject", "rsa-1", func(c *Claims) { c.Subject = "" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := m.validClaims()
+			tt.change(claims)
+			_, err := p.VerifyIDToken(context.Background(), m.sign(t, tt.kid, claims), testNonce)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsOtherSignatures(t *testing.T) {
+	m := newMockIssuer(t)
+	m.addKey(t, "rsa-1", false)
+	p := m.provider(t)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, m.validClaims())
+	hmac.Header["kid"] = "rsa-1"
+	hmacToken, err := hmac.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// signed with a key the issuer doesn't publish, under a kid it does
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, m.validClaims())
+	forged.Header["kid"] = "rsa-1"
+	forgedToken, err := forged.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"hs256": hmacToken, "foreign key": forgedToken, "garbage": "not.a.token"} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.VerifyIDToken(context.Background(), token, testNonce); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	m := newMockIssuer(t)
+	m.addKey(t, "rsa-1", false)
+	p := m.provider(t)
+	ctx := context.Background()
+
+	if _, err := p.VerifyIDToken(ctx, m.sign(t, "rsa-1", m.validClaims()), testNonce); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.fetches(); got != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", got)
+	}
+
+	// cached keys are reused
+	if _, err := p.VerifyIDToken(ctx, m.sign(t, "rsa-1", m.validClaims()), testNonce); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.fetches(); got != 1 {
+		t.Errorf("jwks fetched %d times for a known kid, want 1", got)
+	}
+
+	// the provider rotates, right after a fetch an unknown kid doesn't refetch
+	m.addKey(t, "rsa-2", false)
+	rotated := m.sign(t, "rsa-2", m.validClaims())
+	if _, err := p.VerifyIDToken(ctx, rotated, testNonce); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Errorf("err = %v, want unknown key id", err)
+	}
+	if got := m.fetches(); got != 1 {
+		t.Errorf("jwks fetched %d times within a minute, want 1", got)
+	}
+
+	// a minute later the unknown kid triggers a refetch that finds the new key
+	p.mu.Lock()
+	p.keysFetchedAt = time.Now().Add(-2 * time.Minute)
+	p.mu.Unlock()
+	if _, err := p.VerifyIDToken(ctx, rotated, testNonce); err != nil {
+		t.Fatalf("rotated key rejected. err: %v", err)
+	}
+	if got := m.fetches(); got != 2 {
+		t.Errorf("jwks fetched %d times, want 2", got)
+	}
+}
+
+func TestFlexBool(t *testing.T) {
+	tests := map[string]bool{`true`: true, `"true"`: true, `false`: false, `"false"`: false, `null`: false}
+	for in, want := range tests {
+		var b flexBool
+		if err := json.Unmarshal([]byte(in), &b); err != nil {
+			t.Fatal(err)
+		}
+		if bool(b) != want {
+			t.Errorf("%s = %v, want %v", in, b, want)
+		}
+	}
+}
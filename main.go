@@ -11,6 +11,7 @@ import (
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/muhammadolammi/jobmatchapi/infra"
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
 )
 
 func main() {
@@ -23,12 +24,20 @@ func main() {
 	defer cancel()
 
 	// Connect services in goroutines
-	go infra.ConnectRabbit(ctx, &cfg)
+	if cfg.AnalysisEventBus == "rabbitmq" || cfg.SessionUpdatesEventBus == "rabbitmq" {
+		go infra.ConnectRabbit(ctx, &cfg)
+	}
 	go infra.LoadAWSConfig(&cfg, cfg.R2)
-	go infra.ConnectPubSub(ctx, &cfg)
 
 	// Blocking DB connection (or just ensure connection pool)
 	infra.ConnectDB(ctx, &cfg)
+	if cfg.RevocationStore == nil {
+		cfg.RevocationStore = auth.NewPostgresRevocationStore(cfg.DB)
+	}
+	infra.ConnectEventBuses(ctx, &cfg)
+	go cfg.RecordSessionEvents(ctx)
+	go cfg.RunSessionUpdatesHub(ctx)
+	go cfg.RunOutboxRelay(ctx)
 
 	// Start your server in goroutine
 	go func() {
@@ -51,6 +60,8 @@ func main() {
 		log.Println("Postgres connection closed")
 	}
 
+	cfg.AnalysisBus.Close()
+	cfg.SessionUpdatesBus.Close()
 	if cfg.RabbitChan != nil {
 		cfg.RabbitChan.Close()
 		log.Println("RabbitMQ channel closed")
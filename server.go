@@ -17,7 +17,7 @@ func server(apiConfig *handlers.Config) {
 	corsOptions := cors.Options{
 		AllowedOrigins: []string{"http://localhost:3000", "http://localhost:8081", "https://gojobmatch.com", "https://jobmatch-backend-755404739186.us-east1.run.app"}, // You can customize this based on your needs
 
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{
 			"Content-Type",
 			"Authorization",
@@ -46,21 +46,66 @@ func server(apiConfig *handlers.Config) {
 	apiRoute.Get("/error", handlers.ErrorReady)
 	// auth
 	apiRoute.Get("/me", apiConfig.AuthMiddleware(apiConfig.GetUserHandler))
+	apiRoute.Get("/me/export", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.ExportAccountHandler)))
+	apiRoute.Delete("/me", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.DeleteAccountHandler)))
+	apiRoute.Get("/profile", apiConfig.AuthMiddleware(apiConfig.GetProfileHandler))
+	apiRoute.Put("/profile", apiConfig.AuthMiddleware(apiConfig.UpdateProfileHandler))
 	apiRoute.Post("/login", apiConfig.LoginHandler)
+	apiRoute.Post("/login/2fa", apiConfig.LoginTwoFactorHandler)
 	apiRoute.Post("/register", apiConfig.RegisterHandler)
 	apiRoute.Post("/refresh", apiConfig.RefreshTokens)
+	apiRoute.Post("/password/forgot", apiConfig.ForgotPasswordHandler)
+	apiRoute.Post("/password/reset", apiConfig.ResetPasswordHandler)
+	apiRoute.Post("/logout", apiConfig.LogoutHandler)
+	apiRoute.Post("/logout-all", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.LogoutAllHandler)))
+	apiRoute.Get("/auth/sessions", apiConfig.AuthMiddleware(apiConfig.GetAuthSessionsHandler))
+	apiRoute.Delete("/auth/sessions/{id}", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.DeleteAuthSessionHandler)))
+	apiRoute.Get("/auth/security-events", apiConfig.AuthMiddleware(apiConfig.GetSecurityEventsHandler))
+	apiRoute.Get("/verify-email", apiConfig.VerifyEmailHandler)
+	apiRoute.Post("/verify-email/resend", apiConfig.VerificationEmailRateLimiter(apiConfig.ResendVerificationEmailHandler))
+	apiRoute.Get("/oauth/providers", apiConfig.GetOAuthProvidersHandler)
+	apiRoute.Get("/oauth/{provider}/authorize", apiConfig.OAuthAuthorizeHandler)
+	apiRoute.Get("/oauth/{provider}/callback", apiConfig.OAuthCallbackHandler)
+	apiRoute.Post("/oauth/complete-signup", apiConfig.OAuthCompleteSignupHandler)
+	apiRoute.Post("/2fa/enroll", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.EnrollTwoFactorHandler)))
+	apiRoute.Post("/2fa/enable", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.EnableTwoFactorHandler)))
+	apiRoute.Post("/2fa/disable", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.DisableTwoFactorHandler)))
+	apiRoute.Post("/2fa/recovery-codes", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.RegenerateRecoveryCodesHandler)))
+	apiRoute.Get("/me/tokens", apiConfig.AuthMiddleware(apiConfig.GetPersonalAccessTokensHandler))
+	apiRoute.Post("/me/tokens", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.CreatePersonalAccessTokenHandler)))
+	apiRoute.Delete("/me/tokens/{id}", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.RevokePersonalAccessTokenHandler)))
 
 	// session
-	apiRoute.Post("/sessions", apiConfig.AuthMiddleware(apiConfig.CreateSession))
-	apiRoute.Post("/sessions/{id}/presign", apiConfig.AuthMiddleware(apiConfig.PresignUploadHandler))
-	apiRoute.Get("/sessions", apiConfig.AuthMiddleware(apiConfig.GetSessions))
-	apiRoute.Get("/sessions/{id}", apiConfig.AuthMiddleware(apiConfig.GetSession))
-
-	apiRoute.Get("/sessions/sse/{id}/updates", apiConfig.AuthMiddleware(apiConfig.HandleSessionUpdates))
+	apiRoute.Post("/sessions", apiConfig.RequireScope(handlers.ScopeSessionsWrite, apiConfig.AuthMiddleware(apiConfig.CreateSession)))
+	apiRoute.Post("/sessions/{sessionID}/presign", apiConfig.RequireScope(handlers.ScopeResumesWrite, apiConfig.SessionMiddleware(handlers.SessionWrite, apiConfig.PresignUploadHandler)))
+	apiRoute.Get("/sessions", apiConfig.RequireScope(handlers.ScopeSessionsRead, apiConfig.AuthMiddleware(apiConfig.GetSessions)))
+	apiRoute.Get("/sessions/{sessionID}", apiConfig.RequireScope(handlers.ScopeSessionsRead, apiConfig.SessionMiddleware(handlers.SessionRead, apiConfig.GetSession)))
+	apiRoute.Patch("/sessions/{sessionID}", apiConfig.RequireScope(handlers.ScopeSessionsWrite, apiConfig.SessionMiddleware(handlers.SessionWrite, apiConfig.UpdateSessionHandler)))
+	apiRoute.Delete("/sessions/{sessionID}", apiConfig.RequireScope(handlers.ScopeSessionsWrite, apiConfig.SessionMiddleware(handlers.SessionWrite, apiConfig.DeleteSessionHandler)))
+	apiRoute.Post("/sessions/{sessionID}/archive", apiConfig.RequireScope(handlers.ScopeSessionsWrite, apiConfig.SessionMiddleware(handlers.SessionWrite, apiConfig.ArchiveSessionHandler)))
+	apiRoute.Post("/sessions/{sessionID}/unarchive", apiConfig.RequireScope(handlers.ScopeSessionsWrite, apiConfig.SessionMiddleware(handlers.SessionWrite, apiConfig.UnarchiveSessionHandler)))
+	apiRoute.Post("/sessions/{sessionID}/cancel", apiConfig.RequireScope(handlers.ScopeAnalysisWrite, apiConfig.SessionMiddleware(handlers.SessionWrite, apiConfig.CancelSessionHandler)))
+
+	apiRoute.Get("/sessions/sse/{sessionID}/updates", apiConfig.RequireScope(handlers.ScopeSessionsRead, apiConfig.SessionMiddleware(handlers.SessionRead, apiConfig.HandleSessionUpdates)))
+	apiRoute.Get("/sessions/ws", apiConfig.SessionsWebSocketHandler)
+
+	// organizations
+	apiRoute.Post("/organizations", apiConfig.RoleMiddleware([]string{"employer", "admin"}, apiConfig.CreateOrganizationHandler))
+	apiRoute.Get("/organizations", apiConfig.RequireScope(handlers.ScopeOrganizationsRead, apiConfig.AuthMiddleware(apiConfig.GetOrganizationsHandler)))
+	apiRoute.Post("/organizations/invitations/accept", apiConfig.BlockImpersonation(apiConfig.AuthMiddleware(apiConfig.AcceptOrganizationInvitationHandler)))
+	apiRoute.Get("/organizations/{orgID}", apiConfig.RequireScope(handlers.ScopeOrganizationsRead, apiConfig.OrgRoleMiddleware([]string{"owner", "recruiter", "viewer"}, apiConfig.GetOrganizationHandler)))
+	apiRoute.Put("/organizations/{orgID}", apiConfig.OrgRoleMiddleware([]string{"owner"}, apiConfig.UpdateOrganizationHandler))
+	apiRoute.Delete("/organizations/{orgID}", apiConfig.OrgRoleMiddleware([]string{"owner"}, apiConfig.DeleteOrganizationHandler))
+	apiRoute.Get("/organizations/{orgID}/members", apiConfig.OrgRoleMiddleware([]string{"owner", "recruiter", "viewer"}, apiConfig.GetOrganizationMembersHandler))
+	apiRoute.Put("/organizations/{orgID}/members/{userID}", apiConfig.OrgRoleMiddleware([]string{"owner"}, apiConfig.UpdateOrganizationMemberRoleHandler))
+	apiRoute.Delete("/organizations/{orgID}/members/{userID}", apiConfig.OrgRoleMiddleware([]string{"owner", "recruiter", "viewer"}, apiConfig.RemoveOrganizationMemberHandler))
+	apiRoute.Post("/organizations/{orgID}/invitations", apiConfig.OrgRoleMiddleware([]string{"owner"}, apiConfig.CreateOrganizationInvitationHandler))
+	apiRoute.Get("/organizations/{orgID}/invitations", apiConfig.OrgRoleMiddleware([]string{"owner"}, apiConfig.GetOrganizationInvitationsHandler))
+	apiRoute.Delete("/organizations/{orgID}/invitations/{invitationID}", apiConfig.OrgRoleMiddleware([]string{"owner"}, apiConfig.DeleteOrganizationInvitationHandler))
 
 	// analyze
-	apiRoute.Post("/uploads/complete", apiConfig.AuthMiddleware(apiConfig.UploadCompleteHandler))
-	apiRoute.Post("/analyze", apiConfig.AnalyzeRateLimiter(apiConfig.AnalyzeHandler))
+	apiRoute.Post("/uploads/complete", apiConfig.RequireScope(handlers.ScopeResumesWrite, apiConfig.AuthMiddleware(apiConfig.UploadCompleteHandler)))
+	apiRoute.Post("/analyze", apiConfig.RequireScope(handlers.ScopeAnalysisWrite, apiConfig.AnalyzeRateLimiter(apiConfig.AnalyzeHandler)))
 
 	// plans & subscription
 	apiRoute.Post("/plans", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.PostPlanHandler))
@@ -68,8 +113,27 @@ func server(apiConfig *handlers.Config) {
 
 	apiRoute.Get("/plans", apiConfig.GetPlansHandler)
 
-	apiRoute.Post("/subscribe", apiConfig.AuthMiddleware(apiConfig.PostSubscribe))
-	apiRoute.Get("/subscription/me", apiConfig.AuthMiddleware(apiConfig.HandleGetMySubscription))
+	// admin
+	apiRoute.Get("/admin/login-locks", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.GetLoginLocksHandler))
+	apiRoute.Post("/admin/login-locks/unlock", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.UnlockLoginHandler))
+	apiRoute.Get("/admin/users", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.GetAdminUsersHandler))
+	apiRoute.Get("/admin/users/{id}", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.GetAdminUserHandler))
+	apiRoute.Put("/admin/users/{id}/role", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.UpdateAdminUserRoleHandler))
+	apiRoute.Post("/admin/users/{id}/suspend", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.SuspendUserHandler))
+	apiRoute.Post("/admin/users/{id}/unsuspend", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.UnsuspendUserHandler))
+	apiRoute.Delete("/admin/users/{id}", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.DeleteAdminUserHandler))
+
+	apiRoute.Get("/admin/api-clients", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.GetAPIClientsHandler))
+	apiRoute.Post("/admin/api-clients", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.CreateAPIClientHandler))
+	apiRoute.Put("/admin/api-clients/{id}/origins", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.UpdateAPIClientOriginsHandler))
+	apiRoute.Post("/admin/api-clients/{id}/rotate", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.RotateAPIClientHandler))
+	apiRoute.Post("/admin/api-clients/{id}/revoke", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.RevokeAPIClientHandler))
+	apiRoute.Get("/admin/metrics", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.MetricsHandler))
+	apiRoute.Post("/admin/impersonate/{userID}", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.ImpersonateUserHandler))
+	apiRoute.Get("/admin/impersonation-audit", apiConfig.RoleMiddleware([]string{"admin"}, apiConfig.GetImpersonationAuditHandler))
+
+	apiRoute.Post("/subscribe", apiConfig.BlockImpersonation(apiConfig.VerifiedAuthMiddleware(apiConfig.PostSubscribe)))
+	apiRoute.Get("/subscription/me", apiConfig.VerifiedAuthMiddleware(apiConfig.HandleGetMySubscription))
 
 	// webhooks
 	apiRoute.Post("/webhook/paystack", apiConfig.PaystackWebhook)
@@ -86,7 +150,8 @@ func server(apiConfig *handlers.Config) {
 	apiRoute.Get("/contact-departments", apiConfig.GetContactDepartmentsHandler)
 	apiRoute.Post("/contact", apiConfig.ContactRateLimiter(apiConfig.PostContactMessagesHandler))
 
-	apiRoute.Get("/results/{sessionID}", apiConfig.GetResultHandler)
+	apiRoute.Get("/results/{sessionID}", apiConfig.RequireScope(handlers.ScopeResultsRead, apiConfig.SessionMiddleware(handlers.SessionRead, apiConfig.GetResultHandler)))
+	router.Get("/.well-known/jwks.json", apiConfig.JWKSHandler)
 	router.Mount("/api", apiRoute)
 	srv := &http.Server{
 		Addr:              ":" + apiConfig.Port,
@@ -0,0 +1,66 @@
+-- name: AdminListUsers :many
+-- users without a subscription are on the free plan
+SELECT
+  sqlc.embed(users),
+  COALESCE(plans.name, 'free')::text AS plan_name,
+  COALESCE(subscriptions.status, 'free')::text AS subscription_status
+FROM users
+LEFT JOIN subscriptions ON subscriptions.user_id = users.id
+LEFT JOIN plans ON plans.id = subscriptions.plan_id
+LEFT JOIN job_seeker_profiles ON job_seeker_profiles.user_id = users.id
+LEFT JOIN employer_profiles ON employer_profiles.user_id = users.id
+WHERE
+  (sqlc.narg(search)::text IS NULL
+    OR users.email ILIKE '%' || sqlc.narg(search) || '%'
+    OR job_seeker_profiles.first_name || ' ' || job_seeker_profiles.last_name ILIKE '%' || sqlc.narg(search) || '%'
+    OR employer_profiles.company_name ILIKE '%' || sqlc.narg(search) || '%')
+  AND (sqlc.narg(role)::text IS NULL OR users.role = sqlc.narg(role))
+  AND (sqlc.narg(plan)::text IS NULL OR COALESCE(plans.name, 'free') = sqlc.narg(plan))
+  AND (sqlc.narg(created_from)::timestamp IS NULL OR users.created_at >= sqlc.narg(created_from))
+  AND (sqlc.narg(created_to)::timestamp IS NULL OR users.created_at < sqlc.narg(created_to))
+  AND (sqlc.narg(suspended)::boolean IS NULL OR (users.suspended_at IS NOT NULL) = sqlc.narg(suspended))
+ORDER BY users.created_at DESC, users.id
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: AdminCountUsers :one
+SELECT COUNT(*)
+FROM users
+LEFT JOIN subscriptions ON subscriptions.user_id = users.id
+LEFT JOIN plans ON plans.id = subscriptions.plan_id
+LEFT JOIN job_seeker_profiles ON job_seeker_profiles.user_id = users.id
+LEFT JOIN employer_profiles ON employer_profiles.user_id = users.id
+WHERE
+  (sqlc.narg(search)::text IS NULL
+    OR users.email ILIKE '%' || sqlc.narg(search) || '%'
+    OR job_seeker_profiles.first_name || ' ' || job_seeker_profiles.last_name ILIKE '%' || sqlc.narg(search) || '%'
+    OR employer_profiles.company_name ILIKE '%' || sqlc.narg(search) || '%')
+  AND (sqlc.narg(role)::text IS NULL OR users.role = sqlc.narg(role))
+  AND (sqlc.narg(plan)::text IS NULL OR COALESCE(plans.name, 'free') = sqlc.narg(plan))
+  AND (sqlc.narg(created_from)::timestamp IS NULL OR users.created_at >= sqlc.narg(created_from))
+  AND (sqlc.narg(created_to)::timestamp IS NULL OR users.created_at < sqlc.narg(created_to))
+  AND (sqlc.narg(suspended)::boolean IS NULL OR (users.suspended_at IS NOT NULL) = sqlc.narg(suspended));
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET 
+  role = $1
+WHERE id = $2
+RETURNING *;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET 
+  suspended_at = NOW(),
+  suspended_reason = $1
+WHERE id = $2;
+
+-- name: UnsuspendUser :exec
+UPDATE users
+SET 
+  suspended_at = NULL,
+  suspended_reason = NULL
+WHERE id = $1;
+
+-- name: CountUserSessions :one
+SELECT COUNT(*) FROM sessions
+WHERE user_id = $1::uuid;
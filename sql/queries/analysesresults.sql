@@ -8,8 +8,18 @@ VALUES ( $1, $2)
 ON CONFLICT (session_id)
 DO UPDATE SET
     results = EXCLUDED.results,
+    stale = FALSE,
     updated_at = CURRENT_TIMESTAMP
 ;
 
 -- name: GetAnalysesResultsBySession :one 
-SELECT * FROM analyses_results WHERE session_id=$1;
\ No newline at end of file
+SELECT * FROM analyses_results WHERE session_id=$1;
+
+-- name: MarkAnalysesResultsStale :exec
+UPDATE analyses_results SET stale = TRUE WHERE session_id = $1;
+
+-- name: AnalysesResultsWrittenSince :one
+SELECT EXISTS (
+    SELECT 1 FROM analyses_results
+    WHERE session_id = $1 AND updated_at >= $2
+);
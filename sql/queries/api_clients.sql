@@ -0,0 +1,52 @@
+-- name: CreateAPIClient :one
+INSERT INTO api_clients (
+name, key_hash, key_prefix, allowed_origins )
+VALUES ( $1, $2, $3, $4 )
+RETURNING *;
+
+-- name: GetAPIClient :one
+SELECT * FROM api_clients WHERE id = $1;
+
+-- name: GetAPIClients :many
+SELECT * FROM api_clients
+ORDER BY created_at;
+
+-- name: GetActiveAPIClientByKeyHash :one
+SELECT * FROM api_clients
+WHERE status = 'active'
+  AND (key_hash = $1 OR (previous_key_hash = $1 AND previous_key_expires_at > NOW()));
+
+-- name: RotateAPIClientKey :one
+UPDATE api_clients
+SET
+  previous_key_hash = key_hash,
+  previous_key_expires_at = sqlc.arg(previous_key_expires_at),
+  key_hash = sqlc.arg(key_hash),
+  key_prefix = sqlc.arg(key_prefix),
+  updated_at = NOW()
+WHERE id = sqlc.arg(id) AND status = 'active'
+RETURNING *;
+
+-- name: UpdateAPIClientOrigins :one
+UPDATE api_clients
+SET
+  allowed_origins = $1,
+  updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: RevokeAPIClient :execrows
+UPDATE api_clients
+SET
+  status = 'revoked',
+  previous_key_hash = NULL,
+  previous_key_expires_at = NULL,
+  revoked_at = NOW(),
+  updated_at = NOW()
+WHERE id = $1 AND status = 'active';
+
+-- name: TouchAPIClient :exec
+-- written at most once a minute per client so busy clients don't turn every request into a write
+UPDATE api_clients
+SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
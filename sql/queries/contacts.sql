@@ -20,4 +20,12 @@ AND created_at >= NOW() - INTERVAL '1 hour';
 SELECT COUNT(*)
 FROM contact_messages
 WHERE email = $1
-AND created_at >= NOW() - INTERVAL '24 hours';
\ No newline at end of file
+AND created_at >= NOW() - INTERVAL '24 hours';
+-- name: GetContactMessagesByEmail :many
+SELECT * FROM contact_messages
+WHERE email = $1
+ORDER BY created_at;
+
+-- name: DeleteContactMessagesByEmail :exec
+DELETE FROM contact_messages
+WHERE email = $1;
@@ -0,0 +1,16 @@
+-- name: CreateEmailVerificationSend :exec
+INSERT INTO email_verification_sends (
+user_id )
+VALUES ( $1 );
+
+-- name: GetUserLastHourEmailVerificationSends :one
+SELECT COUNT(*)
+FROM email_verification_sends
+WHERE user_id = $1
+AND created_at >= NOW() - INTERVAL '1 hour';
+
+-- name: GetUserLastMinuteEmailVerificationSends :one
+SELECT COUNT(*)
+FROM email_verification_sends
+WHERE user_id = $1
+AND created_at >= NOW() - INTERVAL '1 minute';
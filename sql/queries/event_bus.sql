@@ -0,0 +1,11 @@
+-- name: PublishNotification :exec
+SELECT pg_notify(sqlc.arg(channel)::text, sqlc.arg(payload)::text);
+
+-- name: ClaimEventBusMessage :execrows
+INSERT INTO event_bus_claims (message_id, consumer_group)
+VALUES ($1, $2)
+ON CONFLICT (message_id, consumer_group) DO NOTHING;
+
+-- name: DeleteEventBusClaimsBefore :exec
+DELETE FROM event_bus_claims
+WHERE claimed_at < $1;
@@ -0,0 +1,11 @@
+-- name: CreateImpersonationAuditEntry :exec
+INSERT INTO impersonation_audit_log (
+admin_id, target_user_id, token_id, method, path, status_code, reason, ip_address, user_agent )
+VALUES ( $1, $2, $3, $4, $5, $6, $7, $8, $9 );
+
+-- name: GetImpersonationAuditLog :many
+SELECT * FROM impersonation_audit_log
+WHERE (sqlc.narg(admin_id)::uuid IS NULL OR admin_id = sqlc.narg(admin_id))
+  AND (sqlc.narg(target_user_id)::uuid IS NULL OR target_user_id = sqlc.narg(target_user_id))
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,36 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE scope = $1 AND key = $2;
+
+-- name: RecordLoginFailure :one
+-- failures older than the window start a new count
+INSERT INTO login_throttles (
+scope, key, failed_attempts, last_failed_at )
+VALUES ( $1, $2, 1, NOW() )
+ON CONFLICT (scope, key) DO UPDATE
+SET 
+  failed_attempts = CASE
+    WHEN login_throttles.last_failed_at < sqlc.arg(window_start) THEN 1
+    ELSE login_throttles.failed_attempts + 1
+  END,
+  last_failed_at = NOW()
+RETURNING *;
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+SET 
+  locked_until = $3
+WHERE scope = $1 AND key = $2;
+
+-- name: ClearLoginThrottle :execrows
+DELETE FROM login_throttles
+WHERE scope = $1 AND key = $2;
+
+-- name: GetLockedLoginThrottles :many
+SELECT * FROM login_throttles
+WHERE locked_until > NOW()
+ORDER BY locked_until DESC;
+
+-- name: DeleteStaleLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < NOW());
@@ -0,0 +1,35 @@
+-- name: CreateOAuthState :exec
+INSERT INTO oauth_states (
+state_hash, provider, code_verifier, nonce, expires_at )
+VALUES ( $1, $2, $3, $4, $5 );
+
+-- name: ConsumeOAuthState :one
+DELETE FROM oauth_states
+WHERE state_hash = $1
+RETURNING *;
+
+-- name: DeleteExpiredOAuthStates :exec
+DELETE FROM oauth_states
+WHERE expires_at < NOW();
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (
+user_id, provider, subject, email )
+VALUES ( $1, $2, $3, $4 )
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET 
+  last_login_at = NOW(),
+  email = $1
+WHERE id = $2;
+
+-- name: GetUserIdentities :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY created_at;
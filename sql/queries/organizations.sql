@@ -0,0 +1,110 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (
+name, created_by )
+VALUES ( $1, $2 )
+RETURNING *;
+
+-- name: GetOrganization :one
+SELECT * FROM organizations WHERE id = $1;
+
+-- name: UpdateOrganizationName :one
+UPDATE organizations
+SET 
+  name = $1,
+  updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: DeleteOrganization :exec
+DELETE FROM organizations
+WHERE id = $1;
+
+-- name: GetUserOrganizations :many
+SELECT organizations.*, organization_members.role AS member_role
+FROM organizations
+JOIN organization_members ON organization_members.organization_id = organizations.id
+WHERE organization_members.user_id = $1
+ORDER BY organizations.created_at;
+
+-- name: CreateOrganizationMember :one
+INSERT INTO organization_members (
+organization_id, user_id, role )
+VALUES ( $1, $2, $3 )
+RETURNING *;
+
+-- name: GetOrganizationMember :one
+SELECT * FROM organization_members
+WHERE organization_id = $1 AND user_id = $2;
+
+-- name: GetOrganizationMembers :many
+SELECT organization_members.*, users.email
+FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.organization_id = $1
+ORDER BY organization_members.created_at;
+
+-- name: UpdateOrganizationMemberRole :one
+UPDATE organization_members
+SET 
+  role = $1
+WHERE organization_id = $2 AND user_id = $3
+RETURNING *;
+
+-- name: DeleteOrganizationMember :execrows
+DELETE FROM organization_members
+WHERE organization_id = $1 AND user_id = $2;
+
+-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members
+WHERE organization_id = $1 AND role = 'owner';
+
+-- name: LockOrganizationOwners :many
+-- locks the owner rows so concurrent demotions and removals see each other's changes
+SELECT user_id FROM organization_members
+WHERE organization_id = $1 AND role = 'owner'
+FOR UPDATE;
+
+-- name: CreateOrganizationInvitation :one
+INSERT INTO organization_invitations (
+organization_id, email, role, token_hash, invited_by, expires_at )
+VALUES ( $1, $2, $3, $4, $5, $6 )
+RETURNING *;
+
+-- name: GetOrganizationInvitationByHash :one
+SELECT * FROM organization_invitations
+WHERE token_hash = $1;
+
+-- name: GetPendingOrganizationInvitations :many
+SELECT * FROM organization_invitations
+WHERE organization_id = $1 AND accepted_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: AcceptOrganizationInvitation :execrows
+UPDATE organization_invitations
+SET 
+  accepted_at = NOW()
+WHERE id = $1 AND accepted_at IS NULL;
+
+-- name: DeleteOrganizationInvitation :execrows
+DELETE FROM organization_invitations
+WHERE id = $1 AND organization_id = $2 AND accepted_at IS NULL;
+
+-- name: DeleteExpiredOrganizationInvitations :exec
+DELETE FROM organization_invitations
+WHERE organization_id = $1 AND accepted_at IS NULL AND expires_at < NOW();
+
+-- name: CountOrganizationMembers :one
+SELECT COUNT(*) FROM organization_members
+WHERE organization_id = $1;
+
+-- name: DeleteSoleMemberOrganizations :exec
+-- organizations where the user is the only member go with the account
+DELETE FROM organizations
+WHERE organizations.id IN (
+    SELECT members.organization_id FROM organization_members AS members
+    WHERE members.user_id = sqlc.arg(user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM organization_members AS others
+    WHERE others.organization_id = organizations.id AND others.user_id <> sqlc.arg(user_id)
+);
@@ -0,0 +1,49 @@
+-- name: CreateOutboxMessage :one
+INSERT INTO outbox (topic, message_key, payload)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: ClaimOutboxMessages :many
+-- leases the due messages to this relay by pushing available_at past the lease, other relays skip them
+-- until it runs out. A relay that dies mid batch leaves its messages to be retried after the lease.
+UPDATE outbox
+SET available_at = sqlc.arg(leased_until)
+WHERE id IN (
+    SELECT id FROM outbox
+    WHERE published_at IS NULL AND dead_at IS NULL AND available_at <= NOW()
+    ORDER BY id
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkOutboxMessagePublished :exec
+UPDATE outbox
+SET published_at = NOW(), attempts = attempts + 1
+WHERE id = $1;
+
+-- name: SkipOutboxMessage :exec
+-- marks a message that isn't needed anymore as done without publishing it
+UPDATE outbox
+SET published_at = NOW(), last_error = $2
+WHERE id = $1;
+
+-- name: MarkOutboxMessageFailed :exec
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    available_at = sqlc.arg(available_at),
+    dead_at = CASE WHEN sqlc.arg(dead)::boolean THEN NOW() ELSE NULL END
+WHERE id = sqlc.arg(id);
+
+-- name: GetOutboxStats :one
+-- lag is how long the oldest undelivered message has been waiting
+SELECT
+    COUNT(*) FILTER (WHERE published_at IS NULL AND dead_at IS NULL) AS pending,
+    COUNT(*) FILTER (WHERE dead_at IS NOT NULL) AS dead,
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at) FILTER (WHERE published_at IS NULL AND dead_at IS NULL)), 0)::float8 AS lag_seconds
+FROM outbox;
+
+-- name: DeletePublishedOutboxMessagesBefore :exec
+DELETE FROM outbox
+WHERE published_at < $1;
@@ -0,0 +1,44 @@
+-- name: UpsertEmployerProfile :one
+-- users created through oidc or by an admin may not have a profile yet
+INSERT INTO employer_profiles (
+user_id, company_name, company_website, company_size, company_industry )
+VALUES ( $1, $2, $3, $4, $5 )
+ON CONFLICT (user_id) DO UPDATE
+SET
+  company_name = EXCLUDED.company_name,
+  company_website = EXCLUDED.company_website,
+  company_size = EXCLUDED.company_size,
+  company_industry = EXCLUDED.company_industry
+RETURNING *;
+
+-- name: UpsertJobSeekerProfile :one
+INSERT INTO job_seeker_profiles (
+user_id, first_name, last_name )
+VALUES ( $1, $2, $3 )
+ON CONFLICT (user_id) DO UPDATE
+SET
+  first_name = EXCLUDED.first_name,
+  last_name = EXCLUDED.last_name
+RETURNING *;
+
+-- name: EmployerCompanyNameTaken :one
+SELECT EXISTS (
+    SELECT 1 FROM employer_profiles
+    WHERE LOWER(company_name) = LOWER(sqlc.arg(company_name)) AND user_id <> sqlc.arg(user_id)
+);
+
+-- name: EmployerCompanyWebsiteTaken :one
+SELECT EXISTS (
+    SELECT 1 FROM employer_profiles
+    WHERE LOWER(company_website) = LOWER(sqlc.arg(company_website)) AND user_id <> sqlc.arg(user_id)
+);
+
+-- name: GetUserProfessionDetails :many
+SELECT professions.* FROM professions
+JOIN user_professions ON user_professions.profession_id = professions.id
+WHERE user_professions.user_id = $1
+ORDER BY professions.name;
+
+-- name: DeleteUserProfessions :exec
+DELETE FROM user_professions
+WHERE user_id = $1;
@@ -1,26 +1,42 @@
 -- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (
 user_id, expires_at,
-token  )
-VALUES ( $1, $2, $3)
+token_hash, user_agent, ip_address,
+family_id, family_created_at, parent_id  )
+VALUES ( $1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
 
--- name: UpdateRefreshToken :exec
+-- name: MarkRefreshTokenRotated :execrows
 UPDATE refresh_tokens
 SET 
-  token = $1,
-  expires_at = $2
-WHERE user_id = $3;
+  rotated_at = NOW(),
+  last_used_at = NOW()
+WHERE id = $1 AND rotated_at IS NULL;
 
 
--- name: RefreshTokenExists :one
-SELECT EXISTS (
-    SELECT 1
-    FROM refresh_tokens
-    WHERE token = $1
-);
+-- name: GetRefreshTokenByHash :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
 
--- name: DeleteRefreshToken :exec
+-- name: GetUserRefreshTokens :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1 AND rotated_at IS NULL AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: DeleteRefreshTokenFamily :exec
+DELETE FROM refresh_tokens
+WHERE family_id=$1;
+
+
+-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens
+WHERE user_id=$1;
+
+-- name: DeleteUserRefreshTokenFamily :execrows
+DELETE FROM refresh_tokens
+WHERE family_id=$1 AND user_id=$2;
+
+-- name: DeleteExpiredUserRefreshTokens :exec
 DELETE FROM refresh_tokens
-WHERE token=$1;
\ No newline at end of file
+WHERE user_id=$1 AND expires_at < NOW();
@@ -0,0 +1,11 @@
+-- name: CreateSecurityEvent :one
+INSERT INTO security_events (
+user_id, event_type, ip_address, user_agent, details )
+VALUES ( $1, $2, $3, $4, $5 )
+RETURNING *;
+
+-- name: GetUserSecurityEvents :many
+SELECT * FROM security_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
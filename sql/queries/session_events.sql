@@ -0,0 +1,10 @@
+-- name: CreateSessionEvent :one
+INSERT INTO session_events (session_id, payload)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: GetSessionEventsAfter :many
+SELECT * FROM session_events
+WHERE session_id = $1 AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,27 @@
+-- name: TransitionSessionStatus :one
+-- only moves the session if nothing changed its status since it was read
+UPDATE sessions
+SET status = sqlc.arg(to_status), updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(from_status)
+RETURNING *;
+
+-- name: CreateSessionStatusHistory :exec
+INSERT INTO session_status_history (session_id, from_status, to_status, reason, changed_by)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: SetSessionStatusChangeContext :exec
+-- read by the sessions_status_transition trigger for the history row, until the transaction ends
+SELECT
+    set_config('jobmatch.status_reason', sqlc.arg(reason)::text, true),
+    set_config('jobmatch.status_changed_by', sqlc.arg(changed_by)::text, true);
+
+-- name: GetSessionStatusHistory :many
+SELECT * FROM session_status_history
+WHERE session_id = $1
+ORDER BY created_at, id;
+
+-- name: GetLatestSessionStatusChangeTo :one
+SELECT * FROM session_status_history
+WHERE session_id = $1 AND to_status = $2
+ORDER BY created_at DESC, id DESC
+LIMIT 1;
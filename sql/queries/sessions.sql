@@ -6,14 +6,76 @@ RETURNING *;
 
 -- name: GetUserSessions :many
 SELECT * FROM sessions 
-WHERE user_id = $1
+WHERE user_id = $1::uuid
 ORDER BY created_at DESC;
 
 -- name: GetSession :one 
 SELECT * FROM sessions 
 WHERE id = $1;
 
--- name: UpdateSessionStatus :exec
-UPDATE sessions 
-SET status=$1
-WHERE id=$2;
+-- name: CreateOrganizationSession :one
+INSERT INTO sessions (
+name, user_id, job_title, job_description, organization_id )
+VALUES ( $1, $2, $3, $4, $5 )
+RETURNING *;
+
+-- name: UpdateSessionDetails :one
+UPDATE sessions
+SET name = $1, job_title = $2, job_description = $3, updated_at = CURRENT_TIMESTAMP
+WHERE id = $4
+RETURNING *;
+
+-- name: SetSessionArchived :one
+UPDATE sessions
+SET archived_at = CASE WHEN sqlc.arg(archived)::boolean THEN COALESCE(archived_at, CURRENT_TIMESTAMP) ELSE NULL END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions WHERE id = $1;
+
+-- name: ListSessions :many
+-- the user's personal sessions, or an organization's when organization_id is set. Filters are optional,
+-- the cursor is the sort value and id of the last session on the previous page.
+SELECT * FROM sessions
+WHERE (
+    (sqlc.narg(organization_id)::uuid IS NULL AND user_id = sqlc.arg(user_id)::uuid AND organization_id IS NULL)
+    OR organization_id = sqlc.narg(organization_id)::uuid
+)
+AND (sqlc.arg(include_archived)::boolean OR archived_at IS NULL)
+AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+AND (sqlc.narg(created_from)::timestamp IS NULL OR created_at >= sqlc.narg(created_from)::timestamp)
+AND (sqlc.narg(created_to)::timestamp IS NULL OR created_at < sqlc.narg(created_to)::timestamp)
+AND (sqlc.narg(job_title)::text IS NULL OR job_title ILIKE '%' || sqlc.narg(job_title)::text || '%')
+AND (sqlc.narg(search)::text IS NULL
+    OR search_vector @@ websearch_to_tsquery('english', sqlc.narg(search)::text))
+AND (sqlc.narg(cursor_id)::uuid IS NULL
+    OR (sqlc.arg(sort)::text = 'newest' AND (created_at, id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+    OR (sqlc.arg(sort)::text = 'oldest' AND (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+    OR (sqlc.arg(sort)::text = 'name_asc' AND (name, id) > (sqlc.narg(cursor_name)::text, sqlc.narg(cursor_id)::uuid))
+    OR (sqlc.arg(sort)::text = 'name_desc' AND (name, id) < (sqlc.narg(cursor_name)::text, sqlc.narg(cursor_id)::uuid))
+)
+ORDER BY
+    CASE WHEN sqlc.arg(sort)::text = 'newest' THEN created_at END DESC,
+    CASE WHEN sqlc.arg(sort)::text = 'oldest' THEN created_at END ASC,
+    CASE WHEN sqlc.arg(sort)::text = 'name_asc' THEN name END ASC,
+    CASE WHEN sqlc.arg(sort)::text = 'name_desc' THEN name END DESC,
+    CASE WHEN sqlc.arg(sort)::text IN ('newest', 'name_desc') THEN id END DESC,
+    CASE WHEN sqlc.arg(sort)::text IN ('oldest', 'name_asc') THEN id END ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: CountSessionsByStatus :many
+-- ListSessions filters without status and the cursor, for the status tabs
+SELECT status, COUNT(*) AS count FROM sessions
+WHERE (
+    (sqlc.narg(organization_id)::uuid IS NULL AND user_id = sqlc.arg(user_id)::uuid AND organization_id IS NULL)
+    OR organization_id = sqlc.narg(organization_id)::uuid
+)
+AND (sqlc.arg(include_archived)::boolean OR archived_at IS NULL)
+AND (sqlc.narg(created_from)::timestamp IS NULL OR created_at >= sqlc.narg(created_from)::timestamp)
+AND (sqlc.narg(created_to)::timestamp IS NULL OR created_at < sqlc.narg(created_to)::timestamp)
+AND (sqlc.narg(job_title)::text IS NULL OR job_title ILIKE '%' || sqlc.narg(job_title)::text || '%')
+AND (sqlc.narg(search)::text IS NULL
+    OR search_vector @@ websearch_to_tsquery('english', sqlc.narg(search)::text))
+GROUP BY status;
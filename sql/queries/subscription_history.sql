@@ -2,4 +2,8 @@
 INSERT INTO subscription_history (
  user_id, subscription_id,event_type, old_value, new_value, event_source )
 VALUES ( $1, $2,$3,$4,$5,$6)
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+-- name: GetUserSubscriptionHistory :many
+SELECT * FROM subscription_history
+WHERE user_id = $1
+ORDER BY created_at;
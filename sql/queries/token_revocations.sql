@@ -0,0 +1,27 @@
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (
+jti, expires_at )
+VALUES ( $1, $2 )
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: DeleteExpiredRevokedAccessTokens :exec
+DELETE FROM revoked_access_tokens
+WHERE expires_at < NOW();
+
+-- name: RevokeUserAccessTokens :exec
+INSERT INTO user_token_revocations (
+user_id, revoked_before )
+VALUES ( $1, $2 )
+ON CONFLICT (user_id)
+DO UPDATE SET
+    revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before),
+    updated_at = NOW();
+
+-- name: IsAccessTokenRevoked :one
+SELECT (
+    EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = sqlc.arg(jti))
+    OR EXISTS (
+        SELECT 1 FROM user_token_revocations
+        WHERE user_id = sqlc.arg(user_id) AND revoked_before > sqlc.arg(issued_at)
+    )
+)::boolean AS revoked;
@@ -0,0 +1,47 @@
+-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET 
+  totp_secret = $1,
+  totp_enabled_at = NULL,
+  totp_last_used_step = 0
+WHERE id = $2;
+
+-- name: EnableUserTOTP :exec
+UPDATE users
+SET 
+  totp_enabled_at = NOW(),
+  totp_last_used_step = $1
+WHERE id = $2;
+
+-- name: DisableUserTOTP :exec
+UPDATE users
+SET 
+  totp_secret = NULL,
+  totp_enabled_at = NULL,
+  totp_last_used_step = 0
+WHERE id = $1;
+
+-- name: UseUserTOTPStep :execrows
+UPDATE users
+SET 
+  totp_last_used_step = $1
+WHERE id = $2 AND totp_last_used_step < $1;
+
+-- name: CreateTwoFactorRecoveryCode :exec
+INSERT INTO two_factor_recovery_codes (
+user_id, code_hash )
+VALUES ( $1, $2 );
+
+-- name: DeleteUserTwoFactorRecoveryCodes :exec
+DELETE FROM two_factor_recovery_codes
+WHERE user_id = $1;
+
+-- name: UseTwoFactorRecoveryCode :execrows
+UPDATE two_factor_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedTwoFactorRecoveryCodes :one
+SELECT COUNT(*)
+FROM two_factor_recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
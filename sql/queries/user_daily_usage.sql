@@ -15,4 +15,21 @@ WHERE user_id = $1;
 INSERT INTO user_daily_usages (user_id, max_daily, count, last_used_at)
 VALUES ($1, $2, 0, NOW())
 ON CONFLICT (user_id)
-DO UPDATE SET max_daily = $2;
\ No newline at end of file
+DO UPDATE SET max_daily = $2;
+-- name: RefundUserUsage :exec
+-- gives back one analysis, unless the usage window it was counted in is over
+UPDATE user_daily_usages
+SET count = GREATEST(count - 1, 0)
+WHERE user_id = $1 AND last_used_at > NOW() - INTERVAL '24 hours';
+
+-- name: ConsumeUserUsage :execrows
+-- counts one analysis, a use more than 24 hours after the last one starts a new window.
+-- No row is written when the limit of the current window is reached.
+INSERT INTO user_daily_usages (user_id, max_daily, count, last_used_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(max_daily), 1, sqlc.arg(now)::timestamp)
+ON CONFLICT (user_id)
+DO UPDATE SET
+    count = CASE WHEN user_daily_usages.last_used_at <= sqlc.arg(now)::timestamp - INTERVAL '24 hours' THEN 1 ELSE user_daily_usages.count + 1 END,
+    last_used_at = sqlc.arg(now)::timestamp
+WHERE user_daily_usages.last_used_at <= sqlc.arg(now)::timestamp - INTERVAL '24 hours'
+    OR user_daily_usages.count < user_daily_usages.max_daily;
@@ -51,4 +51,15 @@ RETURNING *;
 -- name: GetJobSeekerProfileByUserID :one
 SELECT * FROM job_seeker_profiles WHERE $1=user_id;
 -- name: GetEmployerProfileByUserID :one
-SELECT * FROM employer_profiles WHERE $1=user_id;
\ No newline at end of file
+SELECT * FROM employer_profiles WHERE $1=user_id;
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET 
+  password = $1
+WHERE id = $2;
+
+-- name: MarkUserEmailVerified :exec
+UPDATE users
+SET 
+  email_verified_at = NOW()
+WHERE id = $1 AND email_verified_at IS NULL;
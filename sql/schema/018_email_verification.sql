@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+-- accounts from before verification existed count as verified, they'd be locked out of the verified
+-- only routes otherwise and lose their password on their first social login
+UPDATE users SET email_verified_at = created_at;
+
+-- every verification mail we send, used to rate limit resends
+CREATE TABLE email_verification_sends (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_email_verification_sends_users
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_email_verification_sends_user_created_at
+ON email_verification_sends (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE email_verification_sends;
+ALTER TABLE users DROP COLUMN email_verified_at;
@@ -0,0 +1,49 @@
+-- +goose Up
+-- every login starts a token family, each rotation adds a child pointing at its parent.
+ALTER TABLE refresh_tokens ADD COLUMN family_id UUID;
+ALTER TABLE refresh_tokens ADD COLUMN family_created_at TIMESTAMP;
+UPDATE refresh_tokens SET family_id = id, family_created_at = created_at;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_created_at SET NOT NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_created_at SET DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE refresh_tokens ADD COLUMN parent_id UUID;
+ALTER TABLE refresh_tokens ADD CONSTRAINT fk_refresh_tokens_parent
+    FOREIGN KEY (parent_id)
+    REFERENCES refresh_tokens(id)
+    ON DELETE SET NULL;
+ALTER TABLE refresh_tokens ADD COLUMN rotated_at TIMESTAMP;
+
+-- only store a sha256 of the token
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+CREATE TABLE security_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    event_type TEXT NOT NULL,     -- refresh_token_reuse, ...
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    details TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_security_events_users
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_security_events_user_created_at ON security_events(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE security_events;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+-- raw tokens can't be recovered from the hashes
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
+ALTER TABLE refresh_tokens DROP COLUMN rotated_at;
+ALTER TABLE refresh_tokens DROP CONSTRAINT fk_refresh_tokens_parent;
+ALTER TABLE refresh_tokens DROP COLUMN parent_id;
+ALTER TABLE refresh_tokens DROP COLUMN family_created_at;
+ALTER TABLE refresh_tokens DROP COLUMN family_id;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- failed login attempts per account email and per client ip, shared by every instance
+CREATE TABLE login_throttles (
+    scope TEXT NOT NULL,                 -- email or ip
+    key TEXT NOT NULL,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idx_login_throttles_last_failed_at ON login_throttles(last_failed_at);
+
+-- +goose Down
+DROP TABLE login_throttles;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN suspended_reason TEXT;
+
+CREATE INDEX idx_users_created_at ON users(created_at);
+
+-- +goose Down
+DROP INDEX idx_users_created_at;
+ALTER TABLE users DROP COLUMN suspended_reason;
+ALTER TABLE users DROP COLUMN suspended_at;
@@ -0,0 +1,70 @@
+-- +goose Up
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    created_by UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_organizations_users
+        FOREIGN KEY (created_by)
+        REFERENCES users(id)
+        ON DELETE SET NULL
+);
+
+CREATE TABLE organization_members (
+    organization_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    role TEXT NOT NULL,           -- owner, recruiter, viewer
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id),
+    CONSTRAINT organization_members_role_check CHECK (role IN ('owner', 'recruiter', 'viewer')),
+    CONSTRAINT fk_organization_members_organizations
+        FOREIGN KEY (organization_id)
+        REFERENCES organizations(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_organization_members_users
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+CREATE TABLE organization_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL,
+    email TEXT NOT NULL,
+    role TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    invited_by UUID,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT organization_invitations_role_check CHECK (role IN ('owner', 'recruiter', 'viewer')),
+    CONSTRAINT fk_organization_invitations_organizations
+        FOREIGN KEY (organization_id)
+        REFERENCES organizations(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_organization_invitations_users
+        FOREIGN KEY (invited_by)
+        REFERENCES users(id)
+        ON DELETE SET NULL
+);
+
+-- one open invitation per email and organization
+CREATE UNIQUE INDEX idx_organization_invitations_pending
+    ON organization_invitations(organization_id, email)
+    WHERE accepted_at IS NULL;
+
+-- sessions without an organization stay personal to their creator.
+-- if the organization is deleted its sessions fall back to their creators.
+ALTER TABLE sessions ADD COLUMN organization_id UUID
+    REFERENCES organizations(id) ON DELETE SET NULL;
+CREATE INDEX idx_sessions_organization_id ON sessions(organization_id);
+
+-- +goose Down
+DROP INDEX idx_sessions_organization_id;
+ALTER TABLE sessions DROP COLUMN organization_id;
+DROP TABLE organization_invitations;
+DROP TABLE organization_members;
+DROP TABLE organizations;
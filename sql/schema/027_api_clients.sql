@@ -0,0 +1,20 @@
+-- +goose Up
+-- frontends and integrations calling the api, each with its own client-api-key
+CREATE TABLE api_clients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT UNIQUE NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    key_prefix TEXT NOT NULL,            -- first characters of the key so admins can tell keys apart
+    -- the key replaced by the last rotation keeps working until previous_key_expires_at
+    previous_key_hash TEXT UNIQUE,
+    previous_key_expires_at TIMESTAMP,
+    allowed_origins TEXT[] NOT NULL DEFAULT '{}', -- empty means any origin
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'revoked')),
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE api_clients;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- every request made with an impersonation token, and the token being issued
+CREATE TABLE impersonation_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    admin_id UUID NOT NULL,              -- no foreign keys, the trail must outlive deleted accounts
+    target_user_id UUID NOT NULL,
+    token_id TEXT NOT NULL,              -- jti of the impersonation token, groups a support session
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    status_code INT NOT NULL,
+    reason TEXT,                         -- only on the row for the token being issued
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_impersonation_audit_log_admin_id ON impersonation_audit_log(admin_id, created_at DESC);
+CREATE INDEX idx_impersonation_audit_log_target_user_id ON impersonation_audit_log(target_user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE impersonation_audit_log;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- archived sessions are hidden from the session list unless asked for
+ALTER TABLE sessions ADD COLUMN archived_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- results are marked stale when the job description they were scored against changes
+ALTER TABLE analyses_results ADD COLUMN stale BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE analyses_results DROP COLUMN stale;
+ALTER TABLE sessions DROP COLUMN updated_at;
+ALTER TABLE sessions DROP COLUMN archived_at;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- full text search over the session list. ListSessions must use the same expression for the index to apply.
+CREATE INDEX idx_sessions_search ON sessions
+    USING GIN (to_tsvector('english', name || ' ' || job_title || ' ' || job_description));
+
+-- keyset pagination of the personal and organization lists
+CREATE INDEX idx_sessions_user_created ON sessions(user_id, created_at DESC, id DESC);
+CREATE INDEX idx_sessions_organization_created ON sessions(organization_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX idx_sessions_organization_created;
+DROP INDEX idx_sessions_user_created;
+DROP INDEX idx_sessions_search;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- sessions had a free text status defaulting to 'pending', move them to the explicit states
+UPDATE sessions SET status = CASE
+    WHEN status IN ('draft', 'uploaded', 'queued', 'processing', 'completed', 'failed', 'cancelled') THEN status
+    WHEN EXISTS (SELECT 1 FROM analyses_results WHERE analyses_results.session_id = sessions.id) THEN 'completed'
+    WHEN EXISTS (SELECT 1 FROM resumes WHERE resumes.session_id = sessions.id) THEN 'uploaded'
+    ELSE 'draft'
+END;
+ALTER TABLE sessions ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE sessions ADD CONSTRAINT sessions_status_check
+    CHECK (status IN ('draft', 'uploaded', 'queued', 'processing', 'completed', 'failed', 'cancelled'));
+
+CREATE TABLE session_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    from_status TEXT,              -- null for the first entry of a session
+    to_status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL, -- null when the system changed it
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_session_status_history_session_id ON session_status_history(session_id, created_at);
+
+-- +goose Down
+DROP TABLE session_status_history;
+ALTER TABLE sessions DROP CONSTRAINT sessions_status_check;
+ALTER TABLE sessions ALTER COLUMN status SET DEFAULT 'pending';
@@ -0,0 +1,14 @@
+-- +goose Up
+-- every message published for a session on the session_updates exchange, so sse clients can
+-- replay what they missed. id is the sse event id, it only grows.
+CREATE TABLE session_events (
+    id BIGSERIAL PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_session_events_session_id ON session_events(session_id, id);
+
+-- +goose Down
+DROP TABLE session_events;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- postgres NOTIFY reaches every listener, the subscribers of a consumer group claim a message
+-- here first so only one of them handles it.
+CREATE TABLE event_bus_claims (
+    message_id UUID NOT NULL,
+    consumer_group TEXT NOT NULL,
+    claimed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, consumer_group)
+);
+
+CREATE INDEX idx_event_bus_claims_claimed_at ON event_bus_claims(claimed_at);
+
+-- +goose Down
+DROP TABLE event_bus_claims;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- messages for the event buses, written in the transaction of the change they announce so they can't
+-- get lost or go out for a change that was rolled back. The outbox relay publishes them.
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic TEXT NOT NULL,
+    message_key TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    available_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP,
+    -- set when the message failed too many times, the relay leaves it alone after that
+    dead_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_pending ON outbox(available_at, id) WHERE published_at IS NULL AND dead_at IS NULL;
+CREATE INDEX idx_outbox_published_at ON outbox(published_at) WHERE published_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE outbox;
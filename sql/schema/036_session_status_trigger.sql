@@ -0,0 +1,44 @@
+-- +goose Up
+-- the worker writes processing, completed and failed straight to sessions.status. Check every status
+-- change here so no writer can skip the state machine, e.g. overwrite a cancelled session, and record
+-- it in session_status_history. Keep the transitions in sync with sessionTransitions in session_status.go.
+-- The api sets jobmatch.status_reason and jobmatch.status_changed_by in its transaction first.
+-- +goose StatementBegin
+CREATE FUNCTION check_session_status_transition() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status = OLD.status THEN
+        RETURN NEW;
+    END IF;
+    IF (OLD.status, NEW.status) NOT IN (
+        ('draft', 'uploaded'), ('draft', 'cancelled'),
+        ('uploaded', 'queued'), ('uploaded', 'cancelled'),
+        ('queued', 'processing'), ('queued', 'failed'), ('queued', 'cancelled'),
+        ('processing', 'completed'), ('processing', 'failed'), ('processing', 'cancelled'),
+        ('completed', 'uploaded'), ('completed', 'queued'),
+        ('failed', 'uploaded'), ('failed', 'queued'),
+        ('cancelled', 'uploaded'), ('cancelled', 'queued')
+    ) THEN
+        RAISE EXCEPTION 'invalid session status transition: % to %', OLD.status, NEW.status
+            USING ERRCODE = 'check_violation';
+    END IF;
+    NEW.updated_at := CURRENT_TIMESTAMP;
+    INSERT INTO session_status_history (session_id, from_status, to_status, reason, changed_by)
+    VALUES (
+        NEW.id,
+        OLD.status,
+        NEW.status,
+        COALESCE(NULLIF(current_setting('jobmatch.status_reason', true), ''), 'changed by the worker'),
+        NULLIF(current_setting('jobmatch.status_changed_by', true), '')::uuid
+    );
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER sessions_status_transition
+BEFORE UPDATE OF status ON sessions
+FOR EACH ROW EXECUTE FUNCTION check_session_status_transition();
+
+-- +goose Down
+DROP TRIGGER sessions_status_transition ON sessions;
+DROP FUNCTION check_session_status_transition();
@@ -0,0 +1,56 @@
+-- +goose Up
+-- organization sessions belong to the organization. When the member who created one is deleted it stays
+-- with user_id NULL, personal sessions are still deleted with their user.
+ALTER TABLE sessions ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE sessions DROP CONSTRAINT fk_sessions_users;
+ALTER TABLE sessions ADD CONSTRAINT fk_sessions_users
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE sessions ADD CONSTRAINT sessions_owner_check
+    CHECK (user_id IS NOT NULL OR organization_id IS NOT NULL);
+
+-- +goose StatementBegin
+CREATE FUNCTION delete_personal_sessions() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM sessions WHERE user_id = OLD.id AND organization_id IS NULL;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER users_delete_personal_sessions
+    BEFORE DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION delete_personal_sessions();
+
+-- a deleted organization's sessions fall back to their creators, the ones without a creator go with it
+-- +goose StatementBegin
+CREATE FUNCTION delete_orphaned_organization_sessions() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM sessions WHERE organization_id = OLD.id AND user_id IS NULL;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER organizations_delete_orphaned_sessions
+    BEFORE DELETE ON organizations
+    FOR EACH ROW EXECUTE FUNCTION delete_orphaned_organization_sessions();
+
+-- names are unique per owner, the organization for organization sessions
+ALTER TABLE sessions DROP CONSTRAINT unique_user_session_name;
+CREATE UNIQUE INDEX unique_user_session_name ON sessions(user_id, name) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX unique_organization_session_name ON sessions(organization_id, name) WHERE organization_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX unique_organization_session_name;
+DROP INDEX unique_user_session_name;
+ALTER TABLE sessions ADD CONSTRAINT unique_user_session_name UNIQUE (user_id, name);
+DROP TRIGGER organizations_delete_orphaned_sessions ON organizations;
+DROP FUNCTION delete_orphaned_organization_sessions();
+DROP TRIGGER users_delete_personal_sessions ON users;
+DROP FUNCTION delete_personal_sessions();
+DELETE FROM sessions WHERE user_id IS NULL;
+ALTER TABLE sessions DROP CONSTRAINT sessions_owner_check;
+ALTER TABLE sessions DROP CONSTRAINT fk_sessions_users;
+ALTER TABLE sessions ADD CONSTRAINT fk_sessions_users
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE sessions ALTER COLUMN user_id SET NOT NULL;
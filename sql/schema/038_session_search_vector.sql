@@ -0,0 +1,15 @@
+-- +goose Up
+-- the searched text as a stored column, so ListSessions and CountSessionsByStatus always query exactly what
+-- is indexed. coalesce keeps a missing field from blanking out the whole document.
+DROP INDEX idx_sessions_search;
+ALTER TABLE sessions ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        to_tsvector('english', coalesce(name, '') || ' ' || coalesce(job_title, '') || ' ' || coalesce(job_description, ''))
+    ) STORED;
+CREATE INDEX idx_sessions_search ON sessions USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX idx_sessions_search;
+ALTER TABLE sessions DROP COLUMN search_vector;
+CREATE INDEX idx_sessions_search ON sessions
+    USING GIN (to_tsvector('english', name || ' ' || job_title || ' ' || job_description));
@@ -4,9 +4,13 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/muhammadolammi/jobmatchapi/internal/auth"
 	"github.com/muhammadolammi/jobmatchapi/internal/handlers"
+	"github.com/muhammadolammi/jobmatchapi/internal/mailer"
+	"github.com/muhammadolammi/jobmatchapi/internal/oidc"
 )
 
 func buildConfig() handlers.Config {
@@ -39,6 +43,18 @@ func buildConfig() handlers.Config {
 		log.Println("empty RABBITMQ_URL in env")
 	}
 
+	// pubsub, rabbitmq, postgres or memory. The worker has to use the same backends, memory only works
+	// when the api runs alone. The server doesn't start when a configured backend can't be set up, set
+	// memory in development without pub/sub credentials.
+	analysisEventBus := os.Getenv("ANALYSIS_EVENT_BUS")
+	if analysisEventBus == "" {
+		analysisEventBus = "pubsub"
+	}
+	sessionUpdatesEventBus := os.Getenv("SESSION_UPDATES_EVENT_BUS")
+	if sessionUpdatesEventBus == "" {
+		sessionUpdatesEventBus = "rabbitmq"
+	}
+
 	r2AccountId := os.Getenv("R2_ACCOUNT_ID")
 	if r2AccountId == "" {
 		// log.Fatal("empty R2_ACCOUNT_ID in environment")
@@ -71,21 +87,102 @@ func buildConfig() handlers.Config {
 	if port == "" {
 		port = "8080"
 	}
+	// clients have their own keys in api_clients, CLIENT_API_KEY is only kept working for frontends
+	// that haven't moved to one yet
 	clientApiKey := os.Getenv("CLIENT_API_KEY")
 	if clientApiKey == "" {
-		// log.Fatal("empty CLIENT_API_KEY in environment")
-		log.Println("empty CLIENT_API_KEY in environment")
+		log.Println("empty CLIENT_API_KEY in environment, only api_clients keys are accepted")
 	}
 	jwtKey := os.Getenv("JWT_KEY")
 	if jwtKey == "" {
 		// log.Fatal("empty JWT_KEY in environment")
 		log.Println("empty JWT_KEY in environment")
 	}
+	// asymmetric signing lets other services verify our tokens with the jwks instead of JWT_KEY
+	jwtPrivateKey := []byte(os.Getenv("JWT_PRIVATE_KEY"))
+	if jwtPrivateKeyFile := os.Getenv("JWT_PRIVATE_KEY_FILE"); jwtPrivateKeyFile != "" {
+		data, err := os.ReadFile(jwtPrivateKeyFile)
+		if err != nil {
+			log.Fatal("error reading JWT_PRIVATE_KEY_FILE. err: ", err)
+		}
+		jwtPrivateKey = data
+	}
+	jwtKeys, err := auth.NewKeySet(auth.KeySetConfig{
+		Algorithm:     os.Getenv("JWT_ALGORITHM"),
+		KeyID:         os.Getenv("JWT_KEY_ID"),
+		PrivateKeyPEM: jwtPrivateKey,
+		VerifyKeysDir: os.Getenv("JWT_VERIFY_KEYS_DIR"),
+		HMACSecret:    []byte(jwtKey),
+		AcceptHMAC:    os.Getenv("JWT_ACCEPT_HS256") == "true",
+	})
+	if err != nil {
+		// signing with JWT_KEY instead would break every service verifying with the jwks
+		log.Fatal("error loading jwt signing keys. err: ", err)
+	}
 	paystackSecretKey := os.Getenv("PAYSTACK_SECRET_KEY")
 	if paystackSecretKey == "" {
 		// log.Fatal("empty PAYSTACK_SECRET_KEY in environment")
 		log.Println("empty PAYSTACK_SECRET_KEY in environment")
 	}
+	appUrl := os.Getenv("APP_URL")
+	if appUrl == "" {
+		appUrl = "https://gojobmatch.com"
+		log.Println("empty APP_URL in environment, using ", appUrl)
+	}
+	mailDriver := os.Getenv("MAIL_DRIVER")
+	if mailDriver == "" {
+		log.Println("empty MAIL_DRIVER in environment, mails will only be logged")
+	}
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "JobMatch <no-reply@gojobmatch.com>"
+	}
+	appMailer, err := mailer.New(mailer.Config{
+		Driver:   mailDriver,
+		From:     mailFrom,
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Dir:      os.Getenv("MAIL_DIR"),
+	})
+	if err != nil {
+		// logging the mails instead would put live reset and verification links in the logs
+		log.Fatal("error creating mailer. err: ", err)
+	}
+	// the postgres store is set once the db is connected
+	var revocationStore auth.RevocationStore
+	if os.Getenv("REVOCATION_STORE") == "memory" {
+		log.Println("using in-memory token revocation store, revocations won't be shared between instances")
+		revocationStore = auth.NewMemoryRevocationStore()
+	}
+	// OIDC_PROVIDERS=google,linkedin then OIDC_<NAME>_CLIENT_ID, _CLIENT_SECRET and _REDIRECT_URL for each.
+	// _ISSUER is only needed for providers other than google and linkedin, e.g. a local mock issuer.
+	oidcProviders := map[string]*oidc.Provider{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		var scopes []string
+		if s := os.Getenv(prefix + "SCOPES"); s != "" {
+			scopes = strings.Fields(strings.ReplaceAll(s, ",", " "))
+		}
+		provider, err := oidc.NewProvider(oidc.ProviderConfig{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       scopes,
+		}, &http.Client{Timeout: 30 * time.Second})
+		if err != nil {
+			log.Println("error configuring oidc provider, skipping it. err: ", err)
+			continue
+		}
+		oidcProviders[name] = provider
+	}
 	// workerApi := os.Getenv("WORKER_API")
 	// if workerApi == "" {
 	// 	// log.Fatal("empty WORKER_API in environment")
@@ -103,6 +200,7 @@ func buildConfig() handlers.Config {
 		Port:         port,
 		ClientApiKey: clientApiKey,
 		JwtKey:       jwtKey,
+		JwtKeys:      jwtKeys,
 		R2:           &r2Config,
 		// AwsConfig:                  &awsConfig,
 		RefreshTokenEXpirationTime: 60 * 24 * 7, //7 days
@@ -113,6 +211,16 @@ func buildConfig() handlers.Config {
 		HttpClient:        &httpClient,
 		PaystackSecretKey: paystackSecretKey,
 		ENV:               environment,
+		AppURL:            appUrl,
+		Mailer:            appMailer,
+		RevocationStore:   revocationStore,
+		OIDCProviders:     oidcProviders,
+
+		PasswordResetTokenExpirationTime:     60,
+		EmailVerificationTokenExpirationTime: 60 * 24, // 1 day
+		RequireVerifiedEmail:                 os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		AnalysisEventBus:                     analysisEventBus,
+		SessionUpdatesEventBus:               sessionUpdatesEventBus,
 		// WorkerApi:         workerApi,
 	}
 	return apiConfig